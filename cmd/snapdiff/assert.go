@@ -7,10 +7,12 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/rom8726/snapdiff/internal/assert"
 	"github.com/rom8726/snapdiff/internal/diff"
 )
 
 var assertOpts diff.Options
+var assertKeys, assertRowIdentity, assertTableMap []string
 var expectedFile string
 
 func newAssertCmd() *cobra.Command {
@@ -25,8 +27,14 @@ func newAssertCmd() *cobra.Command {
 	cmd.Flags().StringVar(&expectedFile, "expected", "", "Expected changes file (required)")
 	cmd.Flags().StringSliceVar(&assertOpts.Tables, "table", nil, "Filter by tables (comma-separated)")
 	cmd.Flags().StringSliceVar(&assertOpts.IgnoreColumns, "ignore-columns", nil, "Columns to ignore")
+	cmd.Flags().StringVar(&assertOpts.CompareRulesFile, "compare-rules", "", "YAML file of per-column comparison rules, e.g. array modes and tolerances")
+	cmd.Flags().StringArrayVar(&assertTableMap, "map-table", nil, "Table of the 'from' snapshot renamed in the 'to' snapshot, as old=new")
+	cmd.Flags().StringArrayVar(&assertKeys, "key", nil, "Key columns of a table without a key constraint, as table=column[,column...]")
+	cmd.Flags().StringArrayVar(&assertRowIdentity, "row-identity", nil, "Columns that identify the rows of a table across key changes, as table=column[,column...]")
+	cmd.Flags().Float64Var(&assertOpts.MatchSimilar, "match-similar", 0, "Report deleted and inserted rows of keyless tables with at least this fraction of equal columns as updates")
 	cmd.Flags().BoolVar(&assertOpts.OnlyChanged, "only-changed", false, "Show only changed tables")
 	cmd.Flags().StringVar(&assertOpts.BaseDir, "base-dir", ".snapdiff", "Base directory for snapshots")
+	cmd.Flags().StringVar(&assertOpts.Identity, "identity", "", "age identity file for encrypted snapshots (default: $SNAPDIFF_IDENTITY)")
 
 	return cmd
 }
@@ -40,6 +48,26 @@ func runAssertCmd(cmd *cobra.Command, _ []string) error {
 		return fmt.Errorf("--expected is required")
 	}
 
+	assertOpts.BaseDir = storeLocation(assertOpts.BaseDir)
+
+	keys, err := parseTableColumns("key", assertKeys)
+	if err != nil {
+		return err
+	}
+	assertOpts.Keys = keys
+
+	rowIdentity, err := parseTableColumns("row-identity", assertRowIdentity)
+	if err != nil {
+		return err
+	}
+	assertOpts.RowIdentity = rowIdentity
+
+	tableMap, err := parseTableMap(assertTableMap)
+	if err != nil {
+		return err
+	}
+	assertOpts.TableMap = tableMap
+
 	result, err := diff.Run(cmd.Context(), assertOpts)
 	if err != nil {
 		return fmt.Errorf("failed to run diff: %w", err)
@@ -55,38 +83,8 @@ func runAssertCmd(cmd *cobra.Command, _ []string) error {
 		return fmt.Errorf("failed to parse expected file: %w", err)
 	}
 
-	resultMap := make(map[string]any)
-	for tableName, tableDiff := range result.Tables {
-		tableMap := make(map[string]any)
-
-		if len(tableDiff.Inserted) > 0 {
-			tableMap["inserted"] = tableDiff.Inserted
-		}
-
-		if len(tableDiff.Updated) > 0 {
-			updatedRows := make([]map[string]any, 0, len(tableDiff.Updated))
-			for _, row := range tableDiff.Updated {
-				updatedRow := map[string]any{
-					"primary_key": row.PrimaryKey,
-					"before":      row.Before,
-					"after":       row.After,
-				}
-				updatedRows = append(updatedRows, updatedRow)
-			}
-			tableMap["updated"] = updatedRows
-		}
-
-		if len(tableDiff.Deleted) > 0 {
-			tableMap["deleted"] = tableDiff.Deleted
-		}
-
-		if len(tableMap) > 0 {
-			resultMap[tableName] = tableMap
-		}
-	}
-
-	if !compareJSON(expected, resultMap) {
-		resultJSON, _ := json.MarshalIndent(resultMap, "", "  ")
+	if !assert.Match(expected, result) {
+		resultJSON, _ := json.MarshalIndent(assert.ResultMap(result), "", "  ")
 
 		return fmt.Errorf("diff does not match expected changes:\nExpected:\n%s\n\nActual:\n%s", expectedData, resultJSON)
 	}
@@ -95,18 +93,3 @@ func runAssertCmd(cmd *cobra.Command, _ []string) error {
 
 	return nil
 }
-
-// compareJSON compares two JSON objects for equality
-func compareJSON(expected, actual any) bool {
-	expectedJSON, err := json.Marshal(expected)
-	if err != nil {
-		return false
-	}
-
-	actualJSON, err := json.Marshal(actual)
-	if err != nil {
-		return false
-	}
-
-	return string(expectedJSON) == string(actualJSON)
-}
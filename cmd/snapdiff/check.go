@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rom8726/snapdiff/internal/check"
+)
+
+var checkOpts check.Options
+var checkKeys, checkRowIdentity, checkTableMap []string
+
+func newCheckCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Check invariant rules against the diff of two snapshots",
+		RunE:  runCheckCmd,
+	}
+
+	cmd.Flags().StringVar(&checkOpts.From, "from", "", "Source snapshot label (required)")
+	cmd.Flags().StringVar(&checkOpts.To, "to", "", "Target snapshot label (required)")
+	cmd.Flags().StringVar(&checkOpts.RulesFile, "rules", "", "Rules file (required)")
+	cmd.Flags().StringSliceVar(&checkOpts.Tables, "table", nil, "Filter by tables (comma-separated)")
+	cmd.Flags().StringSliceVar(&checkOpts.IgnoreColumns, "ignore-columns", nil, "Columns to ignore")
+	cmd.Flags().StringVar(&checkOpts.CompareRulesFile, "compare-rules", "", "YAML file of per-column comparison rules, e.g. array modes and tolerances")
+	cmd.Flags().StringArrayVar(&checkTableMap, "map-table", nil, "Table of the 'from' snapshot renamed in the 'to' snapshot, as old=new")
+	cmd.Flags().StringArrayVar(&checkKeys, "key", nil, "Key columns of a table without a key constraint, as table=column[,column...]")
+	cmd.Flags().StringArrayVar(&checkRowIdentity, "row-identity", nil, "Columns that identify the rows of a table across key changes, as table=column[,column...]")
+	cmd.Flags().Float64Var(&checkOpts.MatchSimilar, "match-similar", 0, "Report deleted and inserted rows of keyless tables with at least this fraction of equal columns as updates")
+	cmd.Flags().StringVar(&checkOpts.BaseDir, "base-dir", ".snapdiff", "Base directory for snapshots")
+	cmd.Flags().StringVar(&checkOpts.Identity, "identity", "", "age identity file for encrypted snapshots (default: $SNAPDIFF_IDENTITY)")
+
+	return cmd
+}
+
+func runCheckCmd(cmd *cobra.Command, _ []string) error {
+	if checkOpts.From == "" || checkOpts.To == "" {
+		return fmt.Errorf("both --from and --to are required")
+	}
+
+	if checkOpts.RulesFile == "" {
+		return fmt.Errorf("--rules is required")
+	}
+
+	checkOpts.BaseDir = storeLocation(checkOpts.BaseDir)
+
+	keys, err := parseTableColumns("key", checkKeys)
+	if err != nil {
+		return err
+	}
+	checkOpts.Keys = keys
+
+	rowIdentity, err := parseTableColumns("row-identity", checkRowIdentity)
+	if err != nil {
+		return err
+	}
+	checkOpts.RowIdentity = rowIdentity
+
+	tableMap, err := parseTableMap(checkTableMap)
+	if err != nil {
+		return err
+	}
+	checkOpts.TableMap = tableMap
+
+	report, err := check.Run(cmd.Context(), checkOpts)
+	if err != nil {
+		return fmt.Errorf("failed to run check: %w", err)
+	}
+
+	failed := 0
+	for _, res := range report.Results {
+		if res.Passed {
+			fmt.Printf("✅ %s\n", res.Rule.DisplayName())
+
+			continue
+		}
+
+		failed++
+		fmt.Printf("❌ %s (%d violations)\n", res.Rule.DisplayName(), res.Total)
+		for _, violation := range res.Violations {
+			fmt.Printf("      - %s\n", violation)
+		}
+
+		if res.Total > len(res.Violations) {
+			fmt.Printf("      ... and %d more\n", res.Total-len(res.Violations))
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d rules failed", failed, len(report.Results))
+	}
+
+	fmt.Printf("\nAll %d rules passed.\n", len(report.Results))
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rom8726/snapdiff/internal/storage"
+)
+
+func newCpCmd() *cobra.Command {
+	var baseDir string
+
+	cmd := &cobra.Command{
+		Use:   "cp [snapshot-label] [new-label]",
+		Short: "Copy a snapshot",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			from, to := args[0], args[1]
+
+			store, err := storage.NewStorage(storeLocation(baseDir))
+			if err != nil {
+				return fmt.Errorf("failed to create storage: %w", err)
+			}
+
+			if err := store.CopySnapshot(cmd.Context(), from, to); err != nil {
+				return fmt.Errorf("failed to copy snapshot '%s': %w", from, err)
+			}
+
+			fmt.Printf("Snapshot '%s' copied to '%s'.\n", from, to)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&baseDir, "base-dir", ".snapdiff", "Base directory for snapshots")
+
+	return cmd
+}
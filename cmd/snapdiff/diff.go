@@ -10,8 +10,11 @@ import (
 )
 
 var diffOpts diff.Options
+var diffKeys, diffRowIdentity, diffTableMap []string
 var formatOpts formatter.Options
 var formatStr string
+var diffSummary bool
+var diffTop int
 
 func newDiffCmd() *cobra.Command {
 	cmd := &cobra.Command{
@@ -25,12 +28,22 @@ func newDiffCmd() *cobra.Command {
 	cmd.Flags().StringVar(&diffOpts.To, "to", "", "Target snapshot label (required)")
 	cmd.Flags().StringSliceVar(&diffOpts.Tables, "table", nil, "Filter by tables (comma-separated)")
 	cmd.Flags().StringSliceVar(&diffOpts.IgnoreColumns, "ignore-columns", nil, "Columns to ignore")
+	cmd.Flags().StringVar(&diffOpts.CompareRulesFile, "compare-rules", "", "YAML file of per-column comparison rules, e.g. array modes and tolerances")
+	cmd.Flags().StringArrayVar(&diffTableMap, "map-table", nil, "Table of the 'from' snapshot renamed in the 'to' snapshot, as old=new")
+	cmd.Flags().StringArrayVar(&diffKeys, "key", nil, "Key columns of a table without a key constraint, as table=column[,column...]")
+	cmd.Flags().StringArrayVar(&diffRowIdentity, "row-identity", nil, "Columns that identify the rows of a table across key changes, as table=column[,column...]")
+	cmd.Flags().Float64Var(&diffOpts.MatchSimilar, "match-similar", 0, "Report deleted and inserted rows of keyless tables with at least this fraction of equal columns as updates")
 	cmd.Flags().BoolVar(&diffOpts.OnlyChanged, "only-changed", false, "Show only changed tables")
-	cmd.Flags().StringVar(&formatStr, "format", "cli", "Output format (cli, yaml, markdown)")
+	cmd.Flags().StringVar(&formatStr, "format", "cli", "Output format (cli, yaml, markdown, json)")
+	cmd.Flags().BoolVar(&formatOpts.GroupByFK, "group-by-fk", false, "Group related changes into aggregates around root rows by foreign keys")
+	cmd.Flags().BoolVar(&diffOpts.CheckForeignKeys, "check-fk", false, "Report rows of the 'to' snapshot that reference a missing row (implied by --group-by-fk)")
+	cmd.Flags().BoolVar(&diffSummary, "summary", false, "Summarize the changes by table and column instead of listing rows")
+	cmd.Flags().IntVar(&diffTop, "top", 10, "Number of most frequent value changes per column in the summary")
 	cmd.Flags().StringVar(&formatOpts.OutputFile, "out", "", "Output file (stdout if not specified)")
 	cmd.Flags().BoolVar(&formatOpts.SortKeys, "sort-keys", false, "Sort keys in output")
 	cmd.Flags().IntVar(&formatOpts.Limit, "limit", 0, "Limit the number of rows in output")
 	cmd.Flags().StringVar(&diffOpts.BaseDir, "base-dir", ".snapdiff", "Base directory for snapshots")
+	cmd.Flags().StringVar(&diffOpts.Identity, "identity", "", "age identity file for encrypted snapshots (default: $SNAPDIFF_IDENTITY)")
 
 	return cmd
 }
@@ -47,15 +60,50 @@ func runDiffCmd(cmd *cobra.Command, _ []string) error {
 		formatOpts.Format = formatter.FormatYAML
 	case "markdown":
 		formatOpts.Format = formatter.FormatMarkdown
+	case "json":
+		formatOpts.Format = formatter.FormatJSON
 	default:
 		return fmt.Errorf("unsupported format: %s", formatStr)
 	}
 
+	if diffSummary && formatOpts.GroupByFK {
+		return fmt.Errorf("--summary and --group-by-fk cannot be used together")
+	}
+
+	diffOpts.BaseDir = storeLocation(diffOpts.BaseDir)
+	diffOpts.CheckForeignKeys = diffOpts.CheckForeignKeys || formatOpts.GroupByFK
+
+	keys, err := parseTableColumns("key", diffKeys)
+	if err != nil {
+		return err
+	}
+	diffOpts.Keys = keys
+
+	rowIdentity, err := parseTableColumns("row-identity", diffRowIdentity)
+	if err != nil {
+		return err
+	}
+	diffOpts.RowIdentity = rowIdentity
+
+	tableMap, err := parseTableMap(diffTableMap)
+	if err != nil {
+		return err
+	}
+	diffOpts.TableMap = tableMap
+
 	result, err := diff.Run(cmd.Context(), diffOpts)
 	if err != nil {
 		return fmt.Errorf("failed to run diff: %w", err)
 	}
 
+	if diffSummary {
+		if err := formatter.FormatSummary(result.Summarize(diffTop), formatOpts); err != nil {
+			return fmt.Errorf("failed to format summary: %w", err)
+		}
+
+		return nil
+	}
+
 	if err := formatter.FormatDiff(result, formatOpts); err != nil {
 		return fmt.Errorf("failed to format diff: %w", err)
 	}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rom8726/snapdiff/internal/storage"
+)
+
+func newExportCmd() *cobra.Command {
+	var (
+		baseDir string
+		out     string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export [snapshot-label]",
+		Short: "Export a snapshot as a portable archive",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			label := args[0]
+			if out == "" {
+				out = label + ".tar.zst"
+			}
+
+			store, err := storage.NewStorage(storeLocation(baseDir))
+			if err != nil {
+				return fmt.Errorf("failed to create storage: %w", err)
+			}
+
+			file, err := os.Create(out)
+			if err != nil {
+				return fmt.Errorf("failed to create archive: %w", err)
+			}
+
+			if err := store.ExportSnapshot(cmd.Context(), label, file, storage.ArchiveCompression(out)); err != nil {
+				_ = file.Close()
+				_ = os.Remove(out)
+
+				return fmt.Errorf("failed to export snapshot '%s': %w", label, err)
+			}
+
+			if err := file.Close(); err != nil {
+				return fmt.Errorf("failed to write archive: %w", err)
+			}
+
+			fmt.Printf("Snapshot '%s' exported to %s.\n", label, out)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&out, "out", "o", "", "Archive file (.tar, .tar.gz or .tar.zst; default <label>.tar.zst)")
+	cmd.Flags().StringVar(&baseDir, "base-dir", ".snapdiff", "Base directory for snapshots")
+
+	return cmd
+}
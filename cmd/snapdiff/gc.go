@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rom8726/snapdiff/internal/storage"
+)
+
+func newGCCmd() *cobra.Command {
+	var (
+		baseDir   string
+		olderThan string
+		opts      storage.GCOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Remove old snapshots and unreferenced snapshot data",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if olderThan != "" {
+				d, err := parseAge(olderThan)
+				if err != nil {
+					return err
+				}
+				opts.OlderThan = d
+			}
+
+			store, err := storage.NewStorage(storeLocation(baseDir))
+			if err != nil {
+				return fmt.Errorf("failed to create storage: %w", err)
+			}
+
+			report, err := store.GC(cmd.Context(), opts)
+			if err != nil {
+				return fmt.Errorf("failed to collect garbage: %w", err)
+			}
+
+			verb := "Removed"
+			if opts.DryRun {
+				verb = "Would remove"
+			}
+
+			for _, label := range report.Snapshots {
+				fmt.Printf("  🗑  %s\n", label)
+			}
+
+			for _, label := range report.Staging {
+				fmt.Printf("  🗑  %s (incomplete)\n", label)
+			}
+
+			fmt.Printf("%s %d snapshots, %d incomplete snapshots and %d objects, freeing %s.\n",
+				verb, len(report.Snapshots), len(report.Staging), report.Objects, formatBytes(report.Bytes))
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&baseDir, "base-dir", ".snapdiff", "Base directory for snapshots")
+	cmd.Flags().IntVar(&opts.KeepLast, "keep-last", 0, "Keep only the N most recent snapshots")
+	cmd.Flags().StringVar(&olderThan, "older-than", "", "Remove snapshots older than a duration (e.g. 72h, 30d)")
+	cmd.Flags().BoolVar(&opts.KeepTagged, "keep-tagged", false, "Never remove tagged snapshots")
+	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "Only show what would be removed")
+
+	return cmd
+}
+
+// parseAge parses a duration, additionally accepting whole days such as "30d"
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration: %s", s)
+		}
+
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration: %s", s)
+	}
+
+	return d, nil
+}
+
+// formatBytes formats a size in bytes for humans
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    time.Duration
+		wantErr bool
+	}{
+		{s: "30d", want: 30 * 24 * time.Hour},
+		{s: "0d", want: 0},
+		{s: "12h", want: 12 * time.Hour},
+		{s: "1h30m", want: 90 * time.Minute},
+		{s: "-1d", wantErr: true},
+		{s: "d", wantErr: true},
+		{s: "1.5d", wantErr: true},
+		{s: "week", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseAge(tt.s)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseAge(%q) = %v, %v, want %v, error %v", tt.s, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{n: 0, want: "0 B"},
+		{n: 1023, want: "1023 B"},
+		{n: 1024, want: "1.0 KiB"},
+		{n: 1536, want: "1.5 KiB"},
+		{n: 5 << 20, want: "5.0 MiB"},
+		{n: 3 << 30, want: "3.0 GiB"},
+	}
+
+	for _, tt := range tests {
+		if got := formatBytes(tt.n); got != tt.want {
+			t.Errorf("formatBytes(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
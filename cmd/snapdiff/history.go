@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rom8726/snapdiff/internal/diff"
+	"github.com/rom8726/snapdiff/internal/formatter"
+)
+
+func newHistoryCmd() *cobra.Command {
+	var (
+		opts     diff.HistoryOptions
+		format   string
+		sortKeys bool
+		out      string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show the changes of a row across a series of snapshots",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if opts.Table == "" || opts.PrimaryKey == "" {
+				return fmt.Errorf("both --table and --pk are required")
+			}
+
+			formatType, err := parseRowFormat(format)
+			if err != nil {
+				return err
+			}
+
+			opts.BaseDir = storeLocation(opts.BaseDir)
+
+			history, err := diff.RunHistory(cmd.Context(), opts)
+			if err != nil {
+				return fmt.Errorf("failed to trace row: %w", err)
+			}
+
+			formatOpts := formatter.Options{Format: formatType, OutputFile: out, SortKeys: sortKeys}
+			if err := formatter.FormatHistory(history, formatOpts); err != nil {
+				return fmt.Errorf("failed to format history: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Table, "table", "", "Table of the row (required)")
+	cmd.Flags().StringVar(&opts.PrimaryKey, "pk", "", "Key of the row: a key value, or col=value pairs (required)")
+	cmd.Flags().StringSliceVar(&opts.Labels, "labels", nil, "Snapshot labels or first..last ranges (default: all snapshots in chronological order)")
+	cmd.Flags().StringSliceVar(&opts.IgnoreColumns, "ignore-columns", nil, "Columns to ignore")
+	cmd.Flags().StringVar(&opts.CompareRulesFile, "compare-rules", "", "YAML file of per-column comparison rules, e.g. array modes and tolerances")
+	cmd.Flags().StringVar(&format, "format", "cli", "Output format (cli, yaml, markdown, json)")
+	cmd.Flags().StringVar(&out, "out", "", "Output file (stdout if not specified)")
+	cmd.Flags().BoolVar(&sortKeys, "sort-keys", false, "Sort keys in output")
+	cmd.Flags().StringVar(&opts.BaseDir, "base-dir", ".snapdiff", "Base directory for snapshots")
+	cmd.Flags().StringVar(&opts.Identity, "identity", "", "age identity file for encrypted snapshots (default: $SNAPDIFF_IDENTITY)")
+
+	return cmd
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rom8726/snapdiff/internal/storage"
+)
+
+func newImportCmd() *cobra.Command {
+	var (
+		baseDir string
+		as      string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "import [archive]",
+		Short: "Import a snapshot from an archive created by export",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			archive := args[0]
+
+			store, err := storage.NewStorage(storeLocation(baseDir))
+			if err != nil {
+				return fmt.Errorf("failed to create storage: %w", err)
+			}
+
+			file, err := os.Open(archive)
+			if err != nil {
+				return fmt.Errorf("failed to open archive: %w", err)
+			}
+			defer file.Close()
+
+			manifest, err := store.ImportSnapshot(cmd.Context(), file, storage.ArchiveCompression(archive), as)
+			if err != nil {
+				return fmt.Errorf("failed to import %s: %w", archive, err)
+			}
+
+			fmt.Printf("Snapshot '%s' with %d tables imported successfully.\n", manifest.Label, manifest.Info(nil).Tables)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&as, "as", "", "Label to import the snapshot as (default: its original label)")
+	cmd.Flags().StringVar(&baseDir, "base-dir", ".snapdiff", "Base directory for snapshots")
+
+	return cmd
+}
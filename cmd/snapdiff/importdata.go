@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rom8726/snapdiff/internal/fixtures"
+)
+
+func newImportDataCmd() *cobra.Command {
+	var opts fixtures.Options
+
+	cmd := &cobra.Command{
+		Use:   "import-data",
+		Short: "Create a snapshot from CSV, JSON or YAML fixtures or a pg_dump file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.Label == "" {
+				return fmt.Errorf("--label is required")
+			}
+			if (opts.FromDir == "") == (opts.FromPgDump == "") {
+				return fmt.Errorf("exactly one of --from-dir and --from-pgdump is required")
+			}
+
+			opts.OutputDir = storeLocation(opts.OutputDir)
+
+			return fixtures.Run(cmd.Context(), opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Label, "label", "", "Snapshot label (required)")
+	cmd.Flags().StringVar(&opts.FromDir, "from-dir", "", "Directory with one <table>.csv, .json or .yaml file per table")
+	cmd.Flags().StringVar(&opts.FromPgDump, "from-pgdump", "", "Plain-format pg_dump file to read COPY data from")
+	cmd.Flags().StringVar(&opts.TypesFrom, "types-from", "",
+		"Snapshot whose column types are used to convert text values (default: infer types)")
+	cmd.Flags().StringSliceVar(&opts.Tables, "table", nil, "Filter by tables (comma-separated)")
+	cmd.Flags().StringVar(&opts.OutputDir, "output-dir", ".snapdiff", "Snapshot output directory")
+	cmd.Flags().StringVar(&opts.Compression, "compress", "none", "Compress table files (none, gzip, zstd)")
+
+	return cmd
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rom8726/snapdiff/internal/storage"
+)
+
+func newKeygenCmd() *cobra.Command {
+	var out string
+
+	cmd := &cobra.Command{
+		Use:   "keygen",
+		Short: "Generate a key pair for signing snapshots",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if err := storage.GenerateSigningKey(out); err != nil {
+				return err
+			}
+
+			fmt.Printf("Private key written to %s, public key to %s.pub\n", out, out)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&out, "out", "o", "snapdiff.key", "Private key file; the public key is written next to it with a .pub suffix")
+
+	return cmd
+}
@@ -1,14 +1,27 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/rom8726/snapdiff/internal/storage"
 )
 
-var listBaseDir string
+var (
+	listBaseDir string
+	listSort    string
+	listReverse bool
+	listFormat  string
+)
 
 func newListCmd() *cobra.Command {
 	cmd := &cobra.Command{
@@ -18,41 +31,75 @@ func newListCmd() *cobra.Command {
 	}
 
 	cmd.Flags().StringVar(&listBaseDir, "base-dir", ".snapdiff", "Base directory for snapshots")
+	cmd.Flags().StringVar(&listSort, "sort", "name", "Sort by name, created, rows or size")
+	cmd.Flags().BoolVar(&listReverse, "reverse", false, "Reverse the sort order")
+	cmd.Flags().StringVar(&listFormat, "format", "text", "Output format (text, json)")
 
 	return cmd
 }
 
-func runListCmd(*cobra.Command, []string) error {
-	store, err := storage.NewStorage(listBaseDir)
+func runListCmd(cmd *cobra.Command, _ []string) error {
+	less, ok := snapshotSorts[listSort]
+	if !ok {
+		return fmt.Errorf("unsupported sort: %s", listSort)
+	}
+
+	if listFormat != "text" && listFormat != "json" {
+		return fmt.Errorf("unsupported format: %s", listFormat)
+	}
+
+	store, err := storage.NewStorage(storeLocation(listBaseDir))
 	if err != nil {
 		return fmt.Errorf("failed to create storage: %w", err)
 	}
 
-	snapshots, err := store.ListSnapshots()
+	snapshots, err := store.ListSnapshotInfo(cmd.Context())
 	if err != nil {
 		return fmt.Errorf("failed to list snapshots: %w", err)
 	}
 
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return less(snapshots[i], snapshots[j])
+	})
+
+	if listReverse {
+		slices.Reverse(snapshots)
+	}
+
+	if listFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(snapshots)
+	}
+
 	if len(snapshots) == 0 {
 		fmt.Println("No snapshots found.")
 
 		return nil
 	}
 
-	fmt.Println("Available snapshots:")
-	for _, snapshot := range snapshots {
-		tables, err := store.ListSnapshotTables(snapshot)
-		if err != nil {
-			return fmt.Errorf("failed to list tables for snapshot %s: %w", snapshot, err)
-		}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "LABEL\tCREATED\tSOURCE\tTABLES\tROWS\tSIZE\tTAGS")
 
-		fmt.Printf("  📦 %s (%d tables)\n", snapshot, len(tables))
-		for _, table := range tables {
-			fmt.Printf("    - %s\n", table)
+	for _, s := range snapshots {
+		rows := "?"
+		if s.Rows >= 0 {
+			rows = strconv.Itoa(s.Rows)
 		}
 
-		fmt.Println()
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\t%s\n",
+			s.Label, s.CreatedAt.Local().Format(time.DateTime), s.Source, s.Tables, rows,
+			formatBytes(s.Size), strings.Join(s.Tags, ","))
 	}
 
-	return nil
+	return tw.Flush()
+}
+
+// snapshotSorts are the orderings supported by list
+var snapshotSorts = map[string]func(a, b storage.SnapshotInfo) bool{
+	"name":    func(a, b storage.SnapshotInfo) bool { return a.Label < b.Label },
+	"created": func(a, b storage.SnapshotInfo) bool { return a.CreatedAt.Before(b.CreatedAt) },
+	"rows":    func(a, b storage.SnapshotInfo) bool { return a.Rows < b.Rows },
+	"size":    func(a, b storage.SnapshotInfo) bool { return a.Size < b.Size },
 }
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rom8726/snapdiff/internal/storage"
+)
+
+func newNoteCmd() *cobra.Command {
+	var baseDir string
+
+	cmd := &cobra.Command{
+		Use:   "note [snapshot-label] [text]",
+		Short: "Set the note of a snapshot, or show it",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			label := args[0]
+
+			store, err := storage.NewStorage(storeLocation(baseDir))
+			if err != nil {
+				return fmt.Errorf("failed to create storage: %w", err)
+			}
+
+			if len(args) == 1 {
+				manifest, err := store.LoadMetadata(cmd.Context(), label)
+				if err != nil {
+					return fmt.Errorf("snapshot '%s' not found: %w", label, err)
+				}
+
+				fmt.Println(manifest.Note)
+
+				return nil
+			}
+
+			err = store.UpdateManifest(cmd.Context(), label, func(m *storage.Manifest) error {
+				m.Note = args[1]
+
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("failed to update note of snapshot '%s': %w", label, err)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&baseDir, "base-dir", ".snapdiff", "Base directory for snapshots")
+
+	return cmd
+}
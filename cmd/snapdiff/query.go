@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rom8726/snapdiff/internal/formatter"
+	"github.com/rom8726/snapdiff/internal/query"
+)
+
+func newQueryCmd() *cobra.Command {
+	var (
+		opts   query.Options
+		format string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "query [snapshot-label] [sql]",
+		Short: "Query snapshots with SQL",
+		Long: `Load the tables of a snapshot into an in-memory SQLite database and run an SQL query on them.
+
+With --from and --to, the tables of both snapshots are loaded into the schemas
+"before" and "after", e.g. SELECT * FROM after.orders o JOIN before.orders p USING (id).`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 2 {
+				opts.Label = args[0]
+			} else if opts.From == "" && opts.To == "" {
+				return fmt.Errorf("a snapshot label or --from and --to are required")
+			}
+			opts.SQL = args[len(args)-1]
+
+			formatType, err := parseRowFormat(format)
+			if err != nil {
+				return err
+			}
+
+			opts.BaseDir = storeLocation(opts.BaseDir)
+
+			result, err := query.Run(cmd.Context(), opts)
+			if err != nil {
+				return fmt.Errorf("failed to run query: %w", err)
+			}
+
+			if err := formatter.FormatRows(result.Columns, result.Rows, formatter.Options{Format: formatType}); err != nil {
+				return fmt.Errorf("failed to format rows: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.From, "from", "", "Snapshot to load into the \"before\" schema")
+	cmd.Flags().StringVar(&opts.To, "to", "", "Snapshot to load into the \"after\" schema")
+	cmd.Flags().StringVar(&format, "format", "cli", "Output format (cli, yaml, markdown, json)")
+	cmd.Flags().StringVar(&opts.BaseDir, "base-dir", ".snapdiff", "Base directory for snapshots")
+	cmd.Flags().StringVar(&opts.Identity, "identity", "", "age identity file for encrypted snapshots (default: $SNAPDIFF_IDENTITY)")
+
+	return cmd
+}
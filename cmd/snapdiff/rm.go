@@ -18,21 +18,21 @@ func newRmCmd() *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			label := args[0]
 
-			store, err := storage.NewStorage(baseDir)
+			store, err := storage.NewStorage(storeLocation(baseDir))
 			if err != nil {
 				return fmt.Errorf("failed to create storage: %w", err)
 			}
 
-			tables, err := store.ListSnapshotTables(label)
+			manifest, err := store.LoadMetadata(cmd.Context(), label)
 			if err != nil {
 				return fmt.Errorf("snapshot '%s' not found: %w", label, err)
 			}
 
-			if err := store.DeleteSnapshot(label); err != nil {
+			if err := store.DeleteSnapshot(cmd.Context(), label); err != nil {
 				return fmt.Errorf("failed to delete snapshot '%s': %w", label, err)
 			}
 
-			fmt.Printf("Snapshot '%s' with %d tables deleted successfully.\n", label, len(tables))
+			fmt.Printf("Snapshot '%s' with %d tables deleted successfully.\n", label, manifest.Info(nil).Tables)
 
 			return nil
 		},
@@ -1,9 +1,16 @@
 package main
 
 import (
+	"fmt"
+	"os"
+	"strings"
+
 	"github.com/spf13/cobra"
 )
 
+// storeURL is the snapshot store location; it overrides --base-dir when set
+var storeURL string
+
 func newRootCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "snapdiff",
@@ -18,12 +25,68 @@ Useful for:
 - CI/CD change analysis`,
 	}
 
+	cmd.PersistentFlags().StringVar(&storeURL, "store", os.Getenv("SNAPDIFF_STORE"),
+		"Snapshot store: local directory or s3://bucket/prefix (overrides --base-dir)")
+
 	// Add commands
 	cmd.AddCommand(newSnapshotCmd())
 	cmd.AddCommand(newDiffCmd())
 	cmd.AddCommand(newListCmd())
+	cmd.AddCommand(newShowCmd())
+	cmd.AddCommand(newHistoryCmd())
+	cmd.AddCommand(newQueryCmd())
 	cmd.AddCommand(newRmCmd())
+	cmd.AddCommand(newMvCmd())
+	cmd.AddCommand(newCpCmd())
+	cmd.AddCommand(newTagCmd())
+	cmd.AddCommand(newNoteCmd())
+	cmd.AddCommand(newGCCmd())
+	cmd.AddCommand(newExportCmd())
+	cmd.AddCommand(newImportCmd())
+	cmd.AddCommand(newImportDataCmd())
 	cmd.AddCommand(newAssertCmd())
+	cmd.AddCommand(newCheckCmd())
+	cmd.AddCommand(newVerifyCmd())
+	cmd.AddCommand(newKeygenCmd())
 
 	return cmd
 }
+
+// storeLocation returns the snapshot store location, preferring --store over baseDir
+func storeLocation(baseDir string) string {
+	if storeURL != "" {
+		return storeURL
+	}
+
+	return baseDir
+}
+
+// parseTableColumns parses flag values of the form table=col1,col2 into columns by table
+func parseTableColumns(flag string, specs []string) (map[string][]string, error) {
+	keys := make(map[string][]string, len(specs))
+	for _, spec := range specs {
+		table, columns, ok := strings.Cut(spec, "=")
+		if !ok || table == "" || columns == "" {
+			return nil, fmt.Errorf("invalid --%s value %q, expected table=column[,column...]", flag, spec)
+		}
+
+		keys[table] = strings.Split(columns, ",")
+	}
+
+	return keys, nil
+}
+
+// parseTableMap parses --map-table values of the form old=new into new table names by old name
+func parseTableMap(specs []string) (map[string]string, error) {
+	tableMap := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		oldName, newName, ok := strings.Cut(spec, "=")
+		if !ok || oldName == "" || newName == "" || oldName == newName {
+			return nil, fmt.Errorf("invalid --map-table value %q, expected old=new", spec)
+		}
+
+		tableMap[oldName] = newName
+	}
+
+	return tableMap, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTableColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []string
+		want    map[string][]string
+		wantErr bool
+	}{
+		{name: "none", want: map[string][]string{}},
+		{
+			name:  "several tables",
+			specs: []string{"events=id", "order_items=order_id,line"},
+			want:  map[string][]string{"events": {"id"}, "order_items": {"order_id", "line"}},
+		},
+		{name: "no columns", specs: []string{"events="}, wantErr: true},
+		{name: "no table", specs: []string{"=id"}, wantErr: true},
+		{name: "no separator", specs: []string{"events"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTableColumns("key", tt.specs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTableColumns = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTableMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "none", want: map[string]string{}},
+		{
+			name:  "several tables",
+			specs: []string{"purchases=orders", "public.users=accounts.users"},
+			want:  map[string]string{"purchases": "orders", "public.users": "accounts.users"},
+		},
+		{name: "same name", specs: []string{"orders=orders"}, wantErr: true},
+		{name: "no new name", specs: []string{"orders="}, wantErr: true},
+		{name: "no separator", specs: []string{"orders"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTableMap(tt.specs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTableMap = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rom8726/snapdiff/internal/formatter"
+	"github.com/rom8726/snapdiff/internal/show"
+	"github.com/rom8726/snapdiff/internal/storage"
+)
+
+func newShowCmd() *cobra.Command {
+	var (
+		opts   show.Options
+		format string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "show [snapshot-label] [table]",
+		Short: "Show the tables of a snapshot or the rows of a table",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Label = args[0]
+			opts.BaseDir = storeLocation(opts.BaseDir)
+
+			if len(args) == 1 {
+				return showSnapshot(cmd, opts)
+			}
+
+			opts.Table = args[1]
+
+			formatType, err := parseRowFormat(format)
+			if err != nil {
+				return err
+			}
+
+			page, err := show.Run(cmd.Context(), opts)
+			if err != nil {
+				return fmt.Errorf("failed to show table %s: %w", opts.Table, err)
+			}
+
+			if err := formatter.FormatRows(page.Columns, page.Rows, formatter.Options{Format: formatType}); err != nil {
+				return fmt.Errorf("failed to format rows: %w", err)
+			}
+
+			if formatType == formatter.FormatCLI || formatType == formatter.FormatMarkdown {
+				if len(page.Rows) == 0 {
+					fmt.Printf("\nNo rows (%d matching).\n", page.Total)
+				} else {
+					fmt.Printf("\nRows %d-%d of %d matching.\n", page.Offset+1, page.Offset+len(page.Rows), page.Total)
+				}
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&opts.Where, "where", nil, "Filter rows by col=value (repeatable)")
+	cmd.Flags().StringVar(&opts.PrimaryKey, "pk", "", "Look up a row by id, or by col=value pairs for other keys")
+	cmd.Flags().StringSliceVar(&opts.Columns, "columns", nil, "Columns to show (comma-separated)")
+	cmd.Flags().IntVar(&opts.Limit, "limit", 50, "Maximum number of rows to show (0 for all)")
+	cmd.Flags().IntVar(&opts.Offset, "offset", 0, "Number of matching rows to skip")
+	cmd.Flags().StringVar(&format, "format", "cli", "Output format (cli, yaml, markdown, json)")
+	cmd.Flags().StringVar(&opts.BaseDir, "base-dir", ".snapdiff", "Base directory for snapshots")
+	cmd.Flags().StringVar(&opts.Identity, "identity", "", "age identity file for encrypted snapshots (default: $SNAPDIFF_IDENTITY)")
+
+	return cmd
+}
+
+// showSnapshot prints the metadata and tables of a snapshot
+func showSnapshot(cmd *cobra.Command, opts show.Options) error {
+	store, err := storage.NewStorage(opts.BaseDir)
+	if err != nil {
+		return fmt.Errorf("failed to create storage: %w", err)
+	}
+
+	if err := store.UseIdentity(opts.Identity); err != nil {
+		return err
+	}
+
+	manifest, err := store.LoadManifest(cmd.Context(), opts.Label)
+	if errors.Is(err, storage.ErrNoIdentity) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("snapshot '%s' not found: %w", opts.Label, err)
+	}
+
+	sizes, err := store.TableSizes(cmd.Context(), manifest)
+	if err != nil {
+		return err
+	}
+
+	info := manifest.Info(sizes)
+
+	fmt.Printf("📦 %s\n", info.Label)
+	fmt.Printf("  Created:     %s\n", info.CreatedAt.Local().Format(time.DateTime))
+	if info.Source != "" {
+		fmt.Printf("  Source:      %s\n", info.Source)
+	}
+	fmt.Printf("  Compression: %s\n", manifest.Compression)
+	if len(info.Tags) > 0 {
+		fmt.Printf("  Tags:        %s\n", strings.Join(info.Tags, ", "))
+	}
+	if info.Note != "" {
+		fmt.Printf("  Note:        %s\n", info.Note)
+	}
+	if manifest.Signature != nil {
+		fmt.Printf("  Signed by:   %s\n", manifest.Signature.KeyID)
+	}
+	if manifest.Encryption != nil {
+		fmt.Printf("  Encrypted:   %s\n", strings.Join(manifest.Encryption.Recipients, ", "))
+	}
+	if manifest.Masking != nil {
+		masked := fmt.Sprintf("%d rules", len(manifest.Masking.Rules))
+		if manifest.Masking.KeyID != "" {
+			masked += fmt.Sprintf(", key %s", manifest.Masking.KeyID)
+		}
+		fmt.Printf("  Masked:      %s\n", masked)
+	}
+	fmt.Printf("  Size:        %s\n\n", formatBytes(info.Size))
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "TABLE\tROWS\tSIZE")
+
+	for _, table := range manifest.Tables {
+		rows := "?"
+		if table.Rows >= 0 {
+			rows = strconv.Itoa(table.Rows)
+		}
+
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", table.Name, rows, formatBytes(sizes[table.Object]))
+	}
+
+	return tw.Flush()
+}
+
+// parseRowFormat parses the output format of commands that print rows
+func parseRowFormat(format string) (formatter.FormatType, error) {
+	switch formatter.FormatType(format) {
+	case formatter.FormatCLI, formatter.FormatYAML, formatter.FormatMarkdown, formatter.FormatJSON:
+		return formatter.FormatType(format), nil
+	default:
+		return "", fmt.Errorf("unsupported format: %s", format)
+	}
+}
@@ -19,6 +19,8 @@ func newSnapshotCmd() *cobra.Command {
 				return fmt.Errorf("both --dsn and --label are required")
 			}
 
+			opts.OutputDir = storeLocation(opts.OutputDir)
+
 			return snapshot.Run(cmd.Context(), opts)
 		},
 	}
@@ -29,6 +31,18 @@ func newSnapshotCmd() *cobra.Command {
 	cmd.Flags().StringSliceVar(&opts.IgnoreColumns, "ignore-columns", nil, "Columns to ignore")
 	cmd.Flags().BoolVar(&opts.SortKeys, "sort-keys", false, "Sort keys in YAML output")
 	cmd.Flags().StringVar(&opts.OutputDir, "output-dir", ".snapdiff", "Snapshot output directory")
+	cmd.Flags().StringVar(&opts.Compression, "compress", "none", "Compress table files (none, gzip, zstd)")
+	cmd.Flags().StringVar(&opts.Incremental, "incremental", "",
+		"Reuse tables whose database fingerprint is unchanged (hash, stats)")
+	cmd.Flags().StringVar(&opts.Base, "base", "", "Snapshot to reuse unchanged tables from (default: most recent)")
+	cmd.Flags().StringSliceVar(&opts.Tags, "tag", nil, "Tags to attach to the snapshot")
+	cmd.Flags().StringVar(&opts.Note, "note", "", "Note to attach to the snapshot")
+	cmd.Flags().StringVar(&opts.MaskRules, "mask", "", "YAML file with masking rules for sensitive columns")
+	cmd.Flags().StringVar(&opts.MaskKeyFile, "mask-key-file", "", "File with the key for hash and fake masking (default: $SNAPDIFF_MASK_KEY)")
+	cmd.Flags().StringSliceVar(&opts.Encrypt, "encrypt", nil,
+		"Encrypt to age recipients (age1...), recipient files, or \"passphrase\" for $SNAPDIFF_PASSPHRASE")
+	cmd.Flags().StringVar(&opts.Identity, "identity", "", "age identity file to read an encrypted base snapshot with")
+	cmd.Flags().StringVar(&opts.SignKey, "sign-key", "", "Ed25519 private key to sign the snapshot with (see keygen)")
 
 	return cmd
 }
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rom8726/snapdiff/internal/storage"
+)
+
+func newTagCmd() *cobra.Command {
+	var (
+		baseDir string
+		remove  bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "tag [snapshot-label] [tag...]",
+		Short: "Add tags to a snapshot, or show its tags",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			label, tags := args[0], args[1:]
+
+			store, err := storage.NewStorage(storeLocation(baseDir))
+			if err != nil {
+				return fmt.Errorf("failed to create storage: %w", err)
+			}
+
+			if len(tags) == 0 {
+				manifest, err := store.LoadMetadata(cmd.Context(), label)
+				if err != nil {
+					return fmt.Errorf("snapshot '%s' not found: %w", label, err)
+				}
+
+				fmt.Println(strings.Join(manifest.Tags, "\n"))
+
+				return nil
+			}
+
+			err = store.UpdateManifest(cmd.Context(), label, func(m *storage.Manifest) error {
+				if remove {
+					m.RemoveTags(tags...)
+				} else {
+					m.AddTags(tags...)
+				}
+
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("failed to update tags of snapshot '%s': %w", label, err)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&baseDir, "base-dir", ".snapdiff", "Base directory for snapshots")
+	cmd.Flags().BoolVar(&remove, "remove", false, "Remove the tags instead of adding them")
+
+	return cmd
+}
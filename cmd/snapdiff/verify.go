@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rom8726/snapdiff/internal/storage"
+)
+
+func newVerifyCmd() *cobra.Command {
+	var (
+		baseDir  string
+		pubKey   string
+		identity string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "verify [snapshot-label]",
+		Short: "Verify the integrity of a snapshot",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			label := args[0]
+
+			store, err := storage.NewStorage(storeLocation(baseDir))
+			if err != nil {
+				return fmt.Errorf("failed to create storage: %w", err)
+			}
+
+			if err := store.UseIdentity(identity); err != nil {
+				return err
+			}
+
+			manifest, checks, err := store.VerifySnapshot(cmd.Context(), label)
+			if err != nil {
+				return fmt.Errorf("failed to verify snapshot '%s': %w", label, err)
+			}
+
+			failed := 0
+			for _, check := range checks {
+				if check.Err != nil {
+					failed++
+					fmt.Printf("❌ %s: %v\n", check.Table, check.Err)
+
+					continue
+				}
+
+				fmt.Printf("✅ %s (%d rows)\n", check.Table, check.Rows)
+			}
+
+			if pubKey != "" {
+				key, err := storage.LoadVerifyKey(pubKey)
+				if err != nil {
+					return err
+				}
+
+				if err := manifest.VerifySignature(key); err != nil {
+					failed++
+					fmt.Printf("❌ signature: %v\n", err)
+				} else {
+					fmt.Printf("✅ signature (key %s)\n", manifest.Signature.KeyID)
+				}
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("snapshot '%s' failed verification with %d errors", label, failed)
+			}
+
+			fmt.Printf("\nSnapshot '%s' is intact.\n", label)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&baseDir, "base-dir", ".snapdiff", "Base directory for snapshots")
+	cmd.Flags().StringVar(&pubKey, "pub-key", "", "Ed25519 public key to check the snapshot signature with")
+	cmd.Flags().StringVar(&identity, "identity", "", "age identity file for encrypted snapshots (default: $SNAPDIFF_IDENTITY)")
+
+	return cmd
+}
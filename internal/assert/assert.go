@@ -0,0 +1,346 @@
+// Package assert compares diff results with expected changes
+package assert
+
+import (
+	"encoding/json"
+
+	"github.com/rom8726/snapdiff/internal/diff"
+)
+
+// ResultMap converts a diff result to the structure used by expected changes files
+func ResultMap(result *diff.Result) map[string]any {
+	resultMap := make(map[string]any)
+	for tableName, tableDiff := range result.Tables {
+		tableMap := make(map[string]any)
+
+		if tableDiff.RenamedFrom != "" {
+			tableMap["renamed_from"] = tableDiff.RenamedFrom
+		}
+
+		if len(tableDiff.Inserted) > 0 {
+			tableMap["inserted"] = tableDiff.Inserted
+		}
+
+		if len(tableDiff.Updated) > 0 {
+			updatedRows := make([]map[string]any, 0, len(tableDiff.Updated))
+			for _, row := range tableDiff.Updated {
+				updatedRows = append(updatedRows, updatedRowMap(row))
+			}
+			tableMap["updated"] = updatedRows
+		}
+
+		if len(tableDiff.Deleted) > 0 {
+			tableMap["deleted"] = tableDiff.Deleted
+		}
+
+		if len(tableDiff.Rekeyed) > 0 {
+			rekeyedRows := make([]map[string]any, 0, len(tableDiff.Rekeyed))
+			for _, row := range tableDiff.Rekeyed {
+				rekeyedRows = append(rekeyedRows, rekeyedRowMap(row))
+			}
+			tableMap["rekeyed"] = rekeyedRows
+		}
+
+		if len(tableMap) > 0 {
+			resultMap[tableName] = tableMap
+		}
+	}
+
+	return resultMap
+}
+
+// updatedRowMap converts an updated row to the structure used by expected changes files
+func updatedRowMap(row diff.UpdatedRow) map[string]any {
+	return map[string]any{
+		"primary_key": row.PrimaryKey,
+		"before":      row.Before,
+		"after":       row.After,
+	}
+}
+
+// rekeyedRowMap converts a rekeyed row to the structure used by expected changes files
+func rekeyedRowMap(row diff.RekeyedRow) map[string]any {
+	return map[string]any{
+		"old_key": row.OldKey,
+		"new_key": row.NewKey,
+		"before":  row.Before,
+		"after":   row.After,
+	}
+}
+
+// Match reports whether the diff result matches the expected changes.
+// Values are compared with the comparison rules of the result, so that
+// e.g. a tolerance applies to expected values as it does to the diff.
+// Instead of its full before and after rows, an expected updated row may
+// list its "changes" by column or JSON path, e.g.
+// {"primary_key": {"id": 1}, "changes": {"payload.items[3].qty": {"before": 1, "after": 2}}}.
+// The listed paths must be exactly the changed ones; "before" and "after"
+// are optional. Several changes at one path, such as the elements added to
+// an unordered array at "tags[]", are listed as an array. An expected
+// rekeyed row needs only its "old_key" and "new_key".
+func Match(expected any, result *diff.Result) bool {
+	expectedTables, ok := expected.(map[string]any)
+	if !ok {
+		return compareJSON(expected, ResultMap(result))
+	}
+
+	actualTables := ResultMap(result)
+	if len(expectedTables) != len(actualTables) {
+		return false
+	}
+
+	for tableName, expectedTable := range expectedTables {
+		actualTable, ok := actualTables[tableName].(map[string]any)
+		if !ok {
+			return false
+		}
+
+		m := matcher{result: result, table: tableName}
+		if !m.matchTable(expectedTable, actualTable) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matcher matches the changes of a table with the expected ones
+type matcher struct {
+	result *diff.Result
+	table  string
+}
+
+// matchTable reports whether the changes of the table match the expected ones
+func (m matcher) matchTable(expected any, actual map[string]any) bool {
+	expectedTable, ok := expected.(map[string]any)
+	if !ok || len(expectedTable) != len(actual) {
+		return false
+	}
+
+	for kind, expectedRows := range expectedTable {
+		var matched bool
+		switch kind {
+		case "updated":
+			matched = m.matchUpdated(expectedRows, m.result.Tables[m.table].Updated)
+		case "rekeyed":
+			matched = m.matchRekeyed(expectedRows, m.result.Tables[m.table].Rekeyed)
+		case "renamed_from":
+			matched = compareJSON(expectedRows, actual[kind])
+		case "inserted", "deleted":
+			actualRows, _ := actual[kind].([]map[string]any)
+			matched = m.matchRows(expectedRows, actualRows)
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchRows reports whether rows match the expected ones
+func (m matcher) matchRows(expected any, rows []map[string]any) bool {
+	expectedRows, ok := expected.([]any)
+	if !ok || len(expectedRows) != len(rows) {
+		return false
+	}
+
+	for i, row := range rows {
+		if !m.matchRow(expectedRows[i], row) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchRow reports whether a row matches the expected one, column by column
+func (m matcher) matchRow(expected any, row map[string]any) bool {
+	expectedRow, ok := expected.(map[string]any)
+	if !ok || len(expectedRow) != len(row) {
+		return false
+	}
+
+	for col, value := range expectedRow {
+		actual, ok := row[col]
+		if !ok || !m.matchValue(col, value, actual) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchValue reports whether a value of a column or path matches the expected one
+func (m matcher) matchValue(path string, expected, actual any) bool {
+	return compareJSON(expected, actual) || m.result.ValuesEqual(m.table, path, expected, actual)
+}
+
+// matchUpdated reports whether the updated rows of the table match the expected ones
+func (m matcher) matchUpdated(expected any, rows []diff.UpdatedRow) bool {
+	expectedRows, ok := expected.([]any)
+	if !ok || len(expectedRows) != len(rows) {
+		return false
+	}
+
+	for i, row := range rows {
+		expectedRow, ok := expectedRows[i].(map[string]any)
+		if !ok {
+			return false
+		}
+
+		var matched bool
+		if _, ok := expectedRow["changes"]; ok {
+			matched = m.matchChanges(expectedRow, row)
+		} else {
+			matched = m.matchUpdatedRow(expectedRow, row)
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchRekeyed reports whether the rekeyed rows of the table match the
+// expected ones by their old and new keys, and by their before and after
+// values if given
+func (m matcher) matchRekeyed(expected any, rows []diff.RekeyedRow) bool {
+	expectedRows, ok := expected.([]any)
+	if !ok || len(expectedRows) != len(rows) {
+		return false
+	}
+
+	for i, row := range rows {
+		expectedRow, ok := expectedRows[i].(map[string]any)
+		if !ok {
+			return false
+		}
+
+		actual := rekeyedRowMap(row)
+		for key, value := range expectedRow {
+			switch key {
+			case "old_key", "new_key", "before", "after":
+				if !m.matchRow(value, actual[key].(map[string]any)) {
+					return false
+				}
+			default:
+				return false
+			}
+		}
+
+		_, hasOld := expectedRow["old_key"]
+		_, hasNew := expectedRow["new_key"]
+		if !hasOld || !hasNew {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchUpdatedRow reports whether an updated row matches an expected row
+// with its full before and after values
+func (m matcher) matchUpdatedRow(expected map[string]any, row diff.UpdatedRow) bool {
+	if len(expected) != len(updatedRowMap(row)) {
+		return false
+	}
+
+	return compareJSON(expected["primary_key"], row.PrimaryKey) &&
+		m.matchRow(expected["before"], row.Before) &&
+		m.matchRow(expected["after"], row.After)
+}
+
+// matchChanges reports whether the changes of an updated row match an
+// expected row listing its changes by path
+func (m matcher) matchChanges(expected map[string]any, row diff.UpdatedRow) bool {
+	for key := range expected {
+		if key != "primary_key" && key != "changes" {
+			return false
+		}
+	}
+
+	if pk, ok := expected["primary_key"]; ok && !compareJSON(pk, row.PrimaryKey) {
+		return false
+	}
+
+	changes, ok := expected["changes"].(map[string]any)
+	if !ok {
+		return false
+	}
+
+	// Elements added to or removed from unordered arrays share a path
+	byPath := make(map[string][]diff.Change)
+	for _, change := range row.Changes {
+		byPath[change.Path] = append(byPath[change.Path], change)
+	}
+
+	if len(changes) != len(byPath) {
+		return false
+	}
+
+	for path, actual := range byPath {
+		spec, ok := changes[path]
+		if !ok {
+			return false
+		}
+
+		specs, ok := spec.([]any)
+		if !ok {
+			specs = []any{spec}
+		}
+
+		if len(specs) != len(actual) {
+			return false
+		}
+
+		for i, change := range actual {
+			if !m.matchChange(specs[i], change) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// matchChange reports whether a change matches an expected {"before", "after"}
+// object; null matches any change
+func (m matcher) matchChange(spec any, change diff.Change) bool {
+	if spec == nil {
+		return true
+	}
+
+	values, ok := spec.(map[string]any)
+	if !ok {
+		return false
+	}
+
+	if before, ok := values["before"]; ok && !m.matchValue(change.Path, before, change.Before) {
+		return false
+	}
+
+	if after, ok := values["after"]; ok && !m.matchValue(change.Path, after, change.After) {
+		return false
+	}
+
+	return true
+}
+
+// compareJSON compares two JSON objects for equality
+func compareJSON(expected, actual any) bool {
+	expectedJSON, err := json.Marshal(expected)
+	if err != nil {
+		return false
+	}
+
+	actualJSON, err := json.Marshal(actual)
+	if err != nil {
+		return false
+	}
+
+	return string(expectedJSON) == string(actualJSON)
+}
@@ -0,0 +1,137 @@
+package assert
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rom8726/snapdiff/internal/diff"
+)
+
+// testResult returns a diff of an orders table with one change of each kind
+func testResult(rules ...diff.CompareRule) *diff.Result {
+	return &diff.Result{
+		CompareRules: rules,
+		Tables: map[string]*diff.TableDiff{
+			"orders": {
+				TableName: "orders",
+				Types:     map[string]string{"id": "integer", "amount": "numeric", "payload": "jsonb", "tags": "text[]"},
+				Inserted:  []map[string]any{{"id": 3.0, "amount": 10.0}},
+				Deleted:   []map[string]any{{"id": 4.0, "amount": 5.0}},
+				Updated: []diff.UpdatedRow{{
+					PrimaryKey: map[string]any{"id": 1.0},
+					Before:     map[string]any{"id": 1.0, "payload": map[string]any{"items": []any{map[string]any{"qty": 1.0}}}, "tags": []any{"a"}},
+					After:      map[string]any{"id": 1.0, "payload": map[string]any{"items": []any{map[string]any{"qty": 2.0}}}, "tags": []any{"a", "b", "c"}},
+					Changes: []diff.Change{
+						{Path: "payload.items[0].qty", Before: 1.0, After: 2.0},
+						{Path: "tags[]", After: "b"},
+						{Path: "tags[]", After: "c"},
+					},
+				}},
+				Rekeyed: []diff.RekeyedRow{{
+					OldKey: map[string]any{"id": 2.0},
+					NewKey: map[string]any{"id": 20.0},
+					Before: map[string]any{"id": 2.0, "amount": 7.0},
+					After:  map[string]any{"id": 20.0, "amount": 7.0},
+				}},
+			},
+			"customers": {TableName: "customers", RenamedFrom: "clients"},
+		},
+	}
+}
+
+// fullUpdated is the updated row of testResult with its full before and after values
+const fullUpdated = `{"primary_key": {"id": 1}, "before": {"id": 1, "payload": {"items": [{"qty": 1}]}, "tags": ["a"]}, "after": {"id": 1, "payload": {"items": [{"qty": 2}]}, "tags": ["a", "b", "c"]}}`
+
+// byPath is the updated row of testResult listed by its changes
+const byPath = `{"primary_key": {"id": 1}, "changes": {"payload.items[0].qty": {"before": 1, "after": 2}, "tags[]": [{"after": "b"}, {"after": "c"}]}}`
+
+// expectedOrders returns the expected changes with the given orders table
+func expectedOrders(orders string) string {
+	return `{"customers": {"renamed_from": "clients"}, "orders": ` + orders + `}`
+}
+
+func TestMatch(t *testing.T) {
+	rows := `"inserted": [{"id": 3, "amount": 10}], "deleted": [{"id": 4, "amount": 5}], "rekeyed": [{"old_key": {"id": 2}, "new_key": {"id": 20}}]`
+	tolerance := []diff.CompareRule{{Column: "amount", Tolerance: 0.01}}
+
+	tests := []struct {
+		name     string
+		expected string
+		rules    []diff.CompareRule
+		want     bool
+	}{
+		{name: "full rows", expected: expectedOrders(`{` + rows + `, "updated": [` + fullUpdated + `]}`), want: true},
+		{name: "changes by path", expected: expectedOrders(`{` + rows + `, "updated": [` + byPath + `]}`), want: true},
+		{
+			name:     "any change at a path",
+			expected: expectedOrders(`{` + rows + `, "updated": [{"changes": {"payload.items[0].qty": null, "tags[]": [null, null]}}]}`),
+			want:     true,
+		},
+		{
+			name:     "wrong value at a path",
+			expected: expectedOrders(`{` + rows + `, "updated": [{"changes": {"payload.items[0].qty": {"after": 3}, "tags[]": [null, null]}}]}`),
+		},
+		{
+			name:     "missing path",
+			expected: expectedOrders(`{` + rows + `, "updated": [{"changes": {"payload.items[0].qty": null}}]}`),
+		},
+		{
+			name:     "too few changes at a path",
+			expected: expectedOrders(`{` + rows + `, "updated": [{"changes": {"payload.items[0].qty": null, "tags[]": null}}]}`),
+		},
+		{
+			name:     "rekeyed row without its new key",
+			expected: expectedOrders(`{"inserted": [{"id": 3, "amount": 10}], "deleted": [{"id": 4, "amount": 5}], "rekeyed": [{"old_key": {"id": 2}}], "updated": [` + byPath + `]}`),
+		},
+		{
+			name:     "rekeyed row with its values",
+			expected: expectedOrders(`{"inserted": [{"id": 3, "amount": 10}], "deleted": [{"id": 4, "amount": 5}], "rekeyed": [{"old_key": {"id": 2}, "new_key": {"id": 20}, "before": {"id": 2, "amount": 7}}], "updated": [` + byPath + `]}`),
+			want:     true,
+		},
+		{
+			name:     "value within a tolerance",
+			expected: expectedOrders(`{"inserted": [{"id": 3, "amount": 10.004}], "deleted": [{"id": 4, "amount": 5}], "rekeyed": [{"old_key": {"id": 2}, "new_key": {"id": 20}}], "updated": [` + byPath + `]}`),
+			rules:    tolerance,
+			want:     true,
+		},
+		{
+			name:     "value without a tolerance",
+			expected: expectedOrders(`{"inserted": [{"id": 3, "amount": 10.004}], "deleted": [{"id": 4, "amount": 5}], "rekeyed": [{"old_key": {"id": 2}, "new_key": {"id": 20}}], "updated": [` + byPath + `]}`),
+		},
+		{name: "missing table", expected: `{"orders": {` + rows + `, "updated": [` + byPath + `]}}`},
+		{name: "missing kind of change", expected: expectedOrders(`{"inserted": [{"id": 3, "amount": 10}], "updated": [` + byPath + `]}`)},
+		{name: "wrong rename", expected: `{"customers": {"renamed_from": "buyers"}, "orders": {` + rows + `, "updated": [` + byPath + `]}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var expected any
+			if err := json.Unmarshal([]byte(tt.expected), &expected); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := Match(expected, testResult(tt.rules...)); got != tt.want {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResultMap(t *testing.T) {
+	resultMap := ResultMap(testResult())
+
+	data, err := json.Marshal(resultMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The map is the format of expected changes files, so it matches itself
+	var expected any
+	if err := json.Unmarshal(data, &expected); err != nil {
+		t.Fatal(err)
+	}
+
+	if !Match(expected, testResult()) {
+		t.Errorf("result map %s does not match its result", data)
+	}
+}
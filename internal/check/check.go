@@ -0,0 +1,335 @@
+package check
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rom8726/snapdiff/internal/diff"
+	"github.com/rom8726/snapdiff/internal/storage"
+)
+
+// maxViolations limits how many offending rows are reported per rule
+const maxViolations = 10
+
+// RuleResult is the outcome of evaluating a single rule
+type RuleResult struct {
+	Rule       Rule
+	Passed     bool
+	Violations []string
+	Total      int // Total number of violations, may exceed len(Violations)
+}
+
+// Report contains the results of all evaluated rules
+type Report struct {
+	Results []RuleResult
+}
+
+// Passed reports whether every rule passed
+func (r *Report) Passed() bool {
+	for _, res := range r.Results {
+		if !res.Passed {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Run executes the check command
+func Run(ctx context.Context, opts Options) (*Report, error) {
+	ruleSet, err := LoadRules(opts.RulesFile)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := diff.Run(ctx, diff.Options{
+		From:             opts.From,
+		To:               opts.To,
+		Tables:           opts.Tables,
+		IgnoreColumns:    opts.IgnoreColumns,
+		CompareRulesFile: opts.CompareRulesFile,
+		TableMap:         opts.TableMap,
+		Keys:             opts.Keys,
+		RowIdentity:      opts.RowIdentity,
+		MatchSimilar:     opts.MatchSimilar,
+		BaseDir:          opts.BaseDir,
+		Identity:         opts.Identity,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to run diff: %w", err)
+	}
+
+	store, err := storage.NewStorage(opts.BaseDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage: %w", err)
+	}
+
+	if err := store.UseIdentity(opts.Identity); err != nil {
+		return nil, err
+	}
+
+	fromManifest, err := store.LoadManifest(ctx, opts.From)
+	if err != nil {
+		return nil, err
+	}
+
+	toManifest, err := store.LoadManifest(ctx, opts.To)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := Snapshots{
+		Tables: make(map[string]bool),
+		Keys:   make(map[string][]string),
+		LoadTo: func(tableName string) ([]map[string]any, error) {
+			return store.LoadRows(ctx, opts.To, tableName)
+		},
+	}
+
+	for _, manifest := range []*storage.Manifest{fromManifest, toManifest} {
+		for _, name := range manifest.TableNames() {
+			snapshots.Tables[name] = true
+		}
+	}
+
+	// Referenced tables are looked up by their primary key, or by the key
+	// declared for tables without one
+	for _, table := range toManifest.Tables {
+		if table.Keys != nil && len(table.Keys.PrimaryKey) > 0 {
+			snapshots.Keys[table.Name] = table.Keys.PrimaryKey
+		} else if key := opts.Keys[table.Name]; len(key) > 0 {
+			snapshots.Keys[table.Name] = key
+		}
+	}
+
+	return Evaluate(result, ruleSet.Rules, snapshots)
+}
+
+// Snapshots gives rules access to the compared snapshots beyond their diff
+type Snapshots struct {
+	Tables map[string]bool                                  // Names of the tables of either snapshot
+	Keys   map[string][]string                              // Primary key columns of the tables of the target snapshot
+	LoadTo func(tableName string) ([]map[string]any, error) // Reads a table of the target snapshot
+}
+
+// Evaluate checks the rules against a diff result. Rules that need full
+// table data read the target snapshot through snapshots.
+func Evaluate(result *diff.Result, rules []Rule, snapshots Snapshots) (*Report, error) {
+	report := &Report{}
+
+	for _, rule := range rules {
+		tableDiff, err := findTable(result, snapshots, rule.Table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate rule %q: %w", rule.DisplayName(), err)
+		}
+
+		var violations []string
+
+		// A rekeyed row counts as an update, and as an insert of its new key
+		// and a delete of its old key
+		rekeyed := len(tableDiff.Rekeyed)
+		key := tableDiff.Key
+
+		switch rule.Type {
+		case RuleNoInserts:
+			violations = append(rowViolations("inserted", tableDiff.Inserted, key), rekeyedViolations(tableDiff.Rekeyed, key)...)
+		case RuleNoDeletes:
+			violations = append(rowViolations("deleted", tableDiff.Deleted, key), rekeyedViolations(tableDiff.Rekeyed, key)...)
+		case RuleNoUpdates:
+			violations = append(updateViolations(tableDiff.Updated, key), rekeyedViolations(tableDiff.Rekeyed, key)...)
+		case RuleMaxInserted:
+			violations = countViolation("inserted", len(tableDiff.Inserted)+rekeyed, rule.Max)
+		case RuleMaxUpdated:
+			violations = countViolation("updated", len(tableDiff.Updated)+rekeyed, rule.Max)
+		case RuleMaxDeleted:
+			violations = countViolation("deleted", len(tableDiff.Deleted)+rekeyed, rule.Max)
+		case RuleColumnUnchanged:
+			violations = columnViolations(tableDiff.Updated, tableDiff.Rekeyed, key, rule.Column)
+		case RuleReferences:
+			violations, err = referenceViolations(newRows(tableDiff), key, rule, snapshots)
+		default:
+			err = fmt.Errorf("unknown rule type: %s", rule.Type)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate rule %q: %w", rule.DisplayName(), err)
+		}
+
+		res := RuleResult{
+			Rule:   rule,
+			Passed: len(violations) == 0,
+			Total:  len(violations),
+		}
+		if len(violations) > maxViolations {
+			violations = violations[:maxViolations]
+		}
+		res.Violations = violations
+
+		report.Results = append(report.Results, res)
+	}
+
+	return report, nil
+}
+
+// findTable returns the diff of a table, looking up renamed tables by
+// their old name. Tables in neither snapshot, e.g. misspelled ones, and
+// tables that were not compared are errors, so that their rules can't pass
+// unnoticed.
+func findTable(result *diff.Result, snapshots Snapshots, name string) (*diff.TableDiff, error) {
+	if tableDiff := result.Tables[name]; tableDiff != nil && snapshots.Tables[name] {
+		return tableDiff, nil
+	}
+
+	for _, tableDiff := range result.Tables {
+		if tableDiff.RenamedFrom == name {
+			return tableDiff, nil
+		}
+	}
+
+	if !snapshots.Tables[name] {
+		return nil, fmt.Errorf("table %s is in neither snapshot", name)
+	}
+
+	return nil, fmt.Errorf("table %s was not compared", name)
+}
+
+// rowViolations reports every row in rows as a violation
+func rowViolations(kind string, rows []map[string]any, key []string) []string {
+	violations := make([]string, 0, len(rows))
+	for _, row := range rows {
+		violations = append(violations, fmt.Sprintf("%s %s", kind, formatKey(row, key)))
+	}
+
+	return violations
+}
+
+// updateViolations reports every updated row as a violation
+func updateViolations(rows []diff.UpdatedRow, key []string) []string {
+	violations := make([]string, 0, len(rows))
+	for _, row := range rows {
+		violations = append(violations, fmt.Sprintf("updated %s", formatKey(row.PrimaryKey, key)))
+	}
+
+	return violations
+}
+
+// rekeyedViolations reports every rekeyed row as a violation
+func rekeyedViolations(rows []diff.RekeyedRow, key []string) []string {
+	violations := make([]string, 0, len(rows))
+	for _, row := range rows {
+		violations = append(violations, fmt.Sprintf("rekeyed %s → %s", formatKey(row.OldKey, key), formatKey(row.NewKey, key)))
+	}
+
+	return violations
+}
+
+// newRows returns the rows of a table that appear under a new key: the
+// inserted rows and the rekeyed rows as they are after the change
+func newRows(tableDiff *diff.TableDiff) []map[string]any {
+	rows := make([]map[string]any, 0, len(tableDiff.Inserted)+len(tableDiff.Rekeyed))
+	rows = append(rows, tableDiff.Inserted...)
+	for _, row := range tableDiff.Rekeyed {
+		rows = append(rows, row.After)
+	}
+
+	return rows
+}
+
+// countViolation reports a violation when count exceeds limit
+func countViolation(kind string, count, limit int) []string {
+	if count <= limit {
+		return nil
+	}
+
+	return []string{fmt.Sprintf("%d rows %s, limit is %d", count, kind, limit)}
+}
+
+// columnViolations reports updated and rekeyed rows where the column value changed
+func columnViolations(rows []diff.UpdatedRow, rekeyed []diff.RekeyedRow, key []string, column string) []string {
+	var violations []string
+	for _, row := range rows {
+		if row.ColumnChanged(column) {
+			before, after := row.Before[column], row.After[column]
+			violations = append(violations, fmt.Sprintf("%s: %s changed %v → %v", formatKey(row.PrimaryKey, key), column, before, after))
+		}
+	}
+	for _, row := range rekeyed {
+		if row.ColumnChanged(column) {
+			before, after := row.Before[column], row.After[column]
+			violations = append(violations, fmt.Sprintf("%s: %s changed %v → %v", formatKey(row.OldKey, key), column, before, after))
+		}
+	}
+
+	return violations
+}
+
+// referenceViolations reports new rows whose columns don't match any row of
+// the referenced table. The referenced columns default to its primary key.
+func referenceViolations(inserted []map[string]any, key []string, rule Rule, snapshots Snapshots) ([]string, error) {
+	refColumns := rule.RefColumns
+	if len(refColumns) == 0 {
+		refColumns = snapshots.Keys[rule.RefTable]
+		if len(refColumns) == 0 {
+			return nil, fmt.Errorf("the primary key of %s is unknown, set ref_columns", rule.RefTable)
+		}
+
+		if len(refColumns) != len(rule.Columns) {
+			return nil, fmt.Errorf("columns don't match the primary key (%s) of %s, set ref_columns",
+				strings.Join(refColumns, ", "), rule.RefTable)
+		}
+	}
+
+	if len(inserted) == 0 {
+		return nil, nil
+	}
+
+	refRows, err := snapshots.LoadTo(rule.RefTable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load referenced table %s: %w", rule.RefTable, err)
+	}
+
+	var violations []string
+	for _, row := range diff.MissingReferences(inserted, rule.Columns, refRows, refColumns) {
+		values, _ := tupleKey(row, rule.Columns)
+		violations = append(violations, fmt.Sprintf("%s: no %s row with (%s) = (%s)",
+			formatKey(row, key), rule.RefTable, strings.Join(refColumns, ", "), values))
+	}
+
+	return violations, nil
+}
+
+// tupleKey builds a comparable key from the given columns; it returns false if any of them is NULL
+func tupleKey(row map[string]any, columns []string) (string, bool) {
+	parts := make([]string, 0, len(columns))
+	for _, col := range columns {
+		val, ok := row[col]
+		if !ok || val == nil {
+			return "", false
+		}
+
+		parts = append(parts, fmt.Sprintf("%v", val))
+	}
+
+	return strings.Join(parts, ", "), true
+}
+
+// formatKey returns the key columns of a row for violation messages, or
+// the whole row if the table has no key
+func formatKey(row map[string]any, key []string) string {
+	if len(key) == 0 {
+		return fmt.Sprintf("%v", row)
+	}
+
+	parts := make([]string, 0, len(key))
+	for _, col := range key {
+		val, ok := row[col]
+		if !ok {
+			return fmt.Sprintf("%v", row)
+		}
+
+		parts = append(parts, fmt.Sprintf("%s=%v", col, val))
+	}
+
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,148 @@
+package check
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rom8726/snapdiff/internal/diff"
+)
+
+func TestEvaluate(t *testing.T) {
+	result := &diff.Result{Tables: map[string]*diff.TableDiff{
+		"orders": {
+			TableName: "orders",
+			Key:       []string{"id"},
+			Inserted:  []map[string]any{{"id": 3, "customer_id": 1, "currency": "EUR"}},
+			Deleted:   []map[string]any{{"id": 4, "customer_id": 1}},
+			Updated: []diff.UpdatedRow{{
+				PrimaryKey: map[string]any{"id": 1},
+				Before:     map[string]any{"id": 1, "status": "new"},
+				After:      map[string]any{"id": 1, "status": "paid"},
+				Changes:    []diff.Change{{Path: "status", Before: "new", After: "paid"}},
+			}},
+			Rekeyed: []diff.RekeyedRow{{
+				OldKey:  map[string]any{"id": 2},
+				NewKey:  map[string]any{"id": 20},
+				Before:  map[string]any{"id": 2, "customer_id": 1},
+				After:   map[string]any{"id": 20, "customer_id": 9, "currency": "USD"},
+				Changes: []diff.Change{{Path: "customer_id", Before: 1, After: 9}},
+			}},
+		},
+		"customers": {TableName: "customers"},
+		"accounts": {
+			TableName:   "accounts",
+			RenamedFrom: "users",
+			Key:         []string{"id"},
+			Deleted:     []map[string]any{{"id": 7}},
+		},
+		"order_items": {
+			TableName: "order_items",
+			Key:       []string{"order_id", "line"},
+			Deleted:   []map[string]any{{"order_id": 4, "line": 2, "qty": 1}},
+		},
+		"events": {
+			TableName: "events",
+			Deleted:   []map[string]any{{"kind": "login"}},
+		},
+	}}
+
+	// payments was left out of the diff, e.g. by --table
+	tables := map[string]bool{
+		"orders": true, "order_items": true, "events": true, "customers": true, "currencies": true,
+		"users": true, "accounts": true, "payments": true,
+	}
+
+	loadTo := func(tableName string) ([]map[string]any, error) {
+		switch tableName {
+		case "customers":
+			return []map[string]any{{"id": 1}}, nil
+		case "currencies":
+			return []map[string]any{{"code": "EUR"}}, nil
+		default:
+			return nil, fmt.Errorf("unexpected table %s", tableName)
+		}
+	}
+
+	// payments has no key constraint
+	keys := map[string][]string{"customers": {"id"}, "currencies": {"code"}}
+
+	snapshots := Snapshots{Tables: tables, Keys: keys, LoadTo: loadTo}
+
+	tests := []struct {
+		name    string
+		rule    Rule
+		want    []string
+		wantErr string
+	}{
+		{name: "no inserts", rule: Rule{Type: RuleNoInserts, Table: "orders"}, want: []string{"inserted id=3", "rekeyed id=2 → id=20"}},
+		{name: "no deletes", rule: Rule{Type: RuleNoDeletes, Table: "orders"}, want: []string{"deleted id=4", "rekeyed id=2 → id=20"}},
+		{name: "no updates", rule: Rule{Type: RuleNoUpdates, Table: "orders"}, want: []string{"updated id=1", "rekeyed id=2 → id=20"}},
+		{name: "max inserted", rule: Rule{Type: RuleMaxInserted, Table: "orders", Max: 1}, want: []string{"2 rows inserted, limit is 1"}},
+		{name: "max updated", rule: Rule{Type: RuleMaxUpdated, Table: "orders", Max: 2}},
+		{name: "max deleted", rule: Rule{Type: RuleMaxDeleted, Table: "orders", Max: 1}, want: []string{"2 rows deleted, limit is 1"}},
+		{name: "column unchanged", rule: Rule{Type: RuleColumnUnchanged, Table: "orders", Column: "customer_id"}, want: []string{"id=2: customer_id changed 1 → 9"}},
+		{
+			name: "references of new keys",
+			rule: Rule{Type: RuleReferences, Table: "orders", Columns: []string{"customer_id"}, RefTable: "customers"},
+			want: []string{"id=20: no customers row with (id) = (9)"},
+		},
+		{
+			name: "references of a table with another key",
+			rule: Rule{Type: RuleReferences, Table: "orders", Columns: []string{"currency"}, RefTable: "currencies"},
+			want: []string{"id=20: no currencies row with (code) = (USD)"},
+		},
+		{
+			name:    "references of a table without a key",
+			rule:    Rule{Type: RuleReferences, Table: "orders", Columns: []string{"customer_id"}, RefTable: "payments"},
+			wantErr: "the primary key of payments is unknown, set ref_columns",
+		},
+		{
+			name:    "references with more columns than the key",
+			rule:    Rule{Type: RuleReferences, Table: "orders", Columns: []string{"customer_id", "currency"}, RefTable: "customers"},
+			wantErr: "columns don't match the primary key (id) of customers",
+		},
+		{name: "composite key", rule: Rule{Type: RuleNoDeletes, Table: "order_items"}, want: []string{"deleted order_id=4, line=2"}},
+		{name: "keyless table", rule: Rule{Type: RuleNoDeletes, Table: "events"}, want: []string{"deleted map[kind:login]"}},
+		{name: "unchanged table", rule: Rule{Type: RuleNoDeletes, Table: "customers"}},
+		{name: "renamed table by its old name", rule: Rule{Type: RuleNoDeletes, Table: "users"}, want: []string{"deleted id=7"}},
+		{name: "renamed table by its new name", rule: Rule{Type: RuleMaxDeleted, Table: "accounts"}, want: []string{"1 rows deleted, limit is 0"}},
+		{name: "unknown table", rule: Rule{Type: RuleNoDeletes, Table: "ordrs"}, wantErr: "table ordrs is in neither snapshot"},
+		{name: "table not compared", rule: Rule{Type: RuleNoDeletes, Table: "payments"}, wantErr: "table payments was not compared"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := Evaluate(result, []Rule{tt.rule}, snapshots)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res := report.Results[0]
+			if res.Passed != (len(tt.want) == 0) {
+				t.Errorf("passed = %v, want %v", res.Passed, len(tt.want) == 0)
+			}
+
+			if len(res.Violations) > 0 && !reflect.DeepEqual(res.Violations, tt.want) {
+				t.Errorf("violations = %q, want %q", res.Violations, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateUnknownRule(t *testing.T) {
+	result := &diff.Result{Tables: map[string]*diff.TableDiff{"orders": {TableName: "orders"}}}
+	snapshots := Snapshots{Tables: map[string]bool{"orders": true}}
+
+	if _, err := Evaluate(result, []Rule{{Type: "no_nulls", Table: "orders"}}, snapshots); err == nil {
+		t.Errorf("expected an error for an unknown rule type")
+	}
+}
@@ -0,0 +1,17 @@
+package check
+
+// Options contains configuration for the check command
+type Options struct {
+	From             string              // Source snapshot label
+	To               string              // Target snapshot label
+	RulesFile        string              // Path to the YAML rules file
+	Tables           []string            // Specific tables to include
+	IgnoreColumns    []string            // Columns to ignore in comparison
+	CompareRulesFile string              // YAML file of per-column comparison rules
+	TableMap         map[string]string   // New names of tables of the 'from' snapshot renamed in the 'to' snapshot
+	Keys             map[string][]string // Columns to match the rows of tables without a key constraint by
+	RowIdentity      map[string][]string // Columns that identify the rows of a table across key changes, e.g. uuid
+	MatchSimilar     float64             // Fraction of equal columns to pair deleted and inserted rows of keyless tables as updates
+	BaseDir          string              // Base directory for snapshots
+	Identity         string              // age identity file for encrypted snapshots (default: $SNAPDIFF_IDENTITY)
+}
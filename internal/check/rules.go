@@ -0,0 +1,120 @@
+package check
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RuleType represents the kind of invariant a rule checks
+type RuleType string
+
+const (
+	RuleNoInserts       RuleType = "no_inserts"
+	RuleNoUpdates       RuleType = "no_updates"
+	RuleNoDeletes       RuleType = "no_deletes"
+	RuleMaxInserted     RuleType = "max_inserted"
+	RuleMaxUpdated      RuleType = "max_updated"
+	RuleMaxDeleted      RuleType = "max_deleted"
+	RuleColumnUnchanged RuleType = "column_unchanged"
+	RuleReferences      RuleType = "references"
+)
+
+// Rule is a single invariant evaluated against a diff
+type Rule struct {
+	Name       string   `yaml:"name"`
+	Type       RuleType `yaml:"type"`
+	Table      string   `yaml:"table"`
+	Column     string   `yaml:"column"`
+	Max        int      `yaml:"max"`
+	Columns    []string `yaml:"columns"`
+	RefTable   string   `yaml:"ref_table"`
+	RefColumns []string `yaml:"ref_columns"`
+}
+
+// RuleSet is the top-level structure of a rules file
+type RuleSet struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// LoadRules reads and validates a rules file
+func LoadRules(path string) (*RuleSet, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %w", err)
+	}
+
+	var ruleSet RuleSet
+	if err := yaml.Unmarshal(content, &ruleSet); err != nil {
+		return nil, fmt.Errorf("failed to parse rules file: %w", err)
+	}
+
+	for i := range ruleSet.Rules {
+		if err := ruleSet.Rules[i].validate(); err != nil {
+			return nil, fmt.Errorf("rule #%d: %w", i+1, err)
+		}
+	}
+
+	return &ruleSet, nil
+}
+
+// DisplayName returns the rule name, or a generated description if it has none
+func (r Rule) DisplayName() string {
+	if r.Name != "" {
+		return r.Name
+	}
+
+	switch r.Type {
+	case RuleNoInserts:
+		return fmt.Sprintf("no rows inserted into %s", r.Table)
+	case RuleNoUpdates:
+		return fmt.Sprintf("no rows updated in %s", r.Table)
+	case RuleNoDeletes:
+		return fmt.Sprintf("no rows deleted from %s", r.Table)
+	case RuleMaxInserted:
+		return fmt.Sprintf("at most %d rows inserted into %s", r.Max, r.Table)
+	case RuleMaxUpdated:
+		return fmt.Sprintf("at most %d rows updated in %s", r.Max, r.Table)
+	case RuleMaxDeleted:
+		return fmt.Sprintf("at most %d rows deleted from %s", r.Max, r.Table)
+	case RuleColumnUnchanged:
+		return fmt.Sprintf("%s.%s never changes", r.Table, r.Column)
+	case RuleReferences:
+		return fmt.Sprintf("inserted %s rows reference existing %s rows", r.Table, r.RefTable)
+	default:
+		return string(r.Type)
+	}
+}
+
+// validate checks that the rule has all the fields its type requires
+func (r Rule) validate() error {
+	if r.Table == "" {
+		return fmt.Errorf("table is required")
+	}
+
+	switch r.Type {
+	case RuleNoInserts, RuleNoUpdates, RuleNoDeletes:
+	case RuleMaxInserted, RuleMaxUpdated, RuleMaxDeleted:
+		if r.Max < 0 {
+			return fmt.Errorf("max must not be negative")
+		}
+	case RuleColumnUnchanged:
+		if r.Column == "" {
+			return fmt.Errorf("column is required for %s", r.Type)
+		}
+	case RuleReferences:
+		if r.RefTable == "" || len(r.Columns) == 0 {
+			return fmt.Errorf("columns and ref_table are required for %s", r.Type)
+		}
+		if len(r.RefColumns) != 0 && len(r.RefColumns) != len(r.Columns) {
+			return fmt.Errorf("columns and ref_columns must have the same length")
+		}
+	case "":
+		return fmt.Errorf("type is required")
+	default:
+		return fmt.Errorf("unknown rule type: %s", r.Type)
+	}
+
+	return nil
+}
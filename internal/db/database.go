@@ -4,28 +4,69 @@ package db
 import (
 	"context"
 	"errors"
+	"net"
+	"net/url"
+	"strings"
 )
 
 // Database is an interface that abstracts database operations
-// to allow for different database implementations
+// to allow for different database implementations. It does not own
+// a connection, so it can run on top of a transaction.
 type Database interface {
-	// Connect establishes a connection to the database
-	Connect(ctx context.Context) error
-
-	// Close closes the database connection
-	Close() error
-
 	// GetTableNames returns a list of all tables in the database
 	GetTableNames(ctx context.Context, schema string) ([]string, error)
 
 	// GetTableColumns returns all columns for a table
 	GetTableColumns(ctx context.Context, schema, tableName string) ([]string, error)
 
+	// GetColumnTypes returns the SQL type of each column of a table
+	GetColumnTypes(ctx context.Context, schema, tableName string) (map[string]string, error)
+
 	// GetPrimaryKeyColumns returns the primary key columns for a table
 	GetPrimaryKeyColumns(ctx context.Context, schema, tableName string) ([]string, error)
 
-	// QueryTableData executes a query to get all data from a table with the specified columns
-	QueryTableData(ctx context.Context, schema, tableName string, columns []string) ([]map[string]any, error)
+	// GetUniqueKeys returns the columns of the unique constraints and indexes of a table
+	GetUniqueKeys(ctx context.Context, schema, tableName string) ([][]string, error)
+
+	// GetForeignKeys returns the foreign key constraints of a table
+	GetForeignKeys(ctx context.Context, schema, tableName string) ([]ForeignKey, error)
+
+	// QueryTableData executes a query to get all data from a table with the specified columns,
+	// in a stable order given by the orderBy columns
+	QueryTableData(ctx context.Context, schema, tableName string, columns, orderBy []string) ([]map[string]any, error)
+
+	// TableFingerprint computes a cheap fingerprint of the table contents in the database,
+	// which changes whenever the data returned by QueryTableData changes
+	TableFingerprint(ctx context.Context, schema, tableName string, columns, orderBy []string, method FingerprintMethod) (string, error)
+}
+
+// ForeignKey describes a foreign key constraint
+type ForeignKey struct {
+	Columns    []string // Referencing columns
+	RefTable   string   // Referenced table, qualified by its schema if it is in another schema
+	RefColumns []string // Referenced columns, in the order of Columns
+}
+
+// FingerprintMethod selects how table fingerprints are computed
+type FingerprintMethod string
+
+const (
+	// FingerprintHash hashes all rows ordered by primary key in the database
+	FingerprintHash FingerprintMethod = "hash"
+	// FingerprintStats uses the statistics counters of the table without reading it, falling
+	// back to FingerprintHash. The counters lag behind recent commits, so it is not exact.
+	FingerprintStats FingerprintMethod = "stats"
+)
+
+// Connection is a Database that owns its connection
+type Connection interface {
+	Database
+
+	// Connect establishes a connection to the database
+	Connect(ctx context.Context) error
+
+	// Close closes the database connection
+	Close() error
 }
 
 // Config contains configuration for database connections
@@ -43,6 +84,42 @@ type Config struct {
 	ConnMaxLifetime int
 }
 
+// Source describes the database the DSN points to as host:port/dbname,
+// leaving out credentials and other parameters
+func (c Config) Source() string {
+	var host, port, dbname string
+
+	if u, err := url.Parse(c.DSN); err == nil && u.Scheme != "" {
+		host = u.Hostname()
+		port = u.Port()
+		dbname = strings.TrimPrefix(u.Path, "/")
+	} else {
+		for _, field := range strings.Fields(c.DSN) {
+			key, value, _ := strings.Cut(field, "=")
+			value = strings.Trim(value, "'")
+
+			switch key {
+			case "host":
+				host = value
+			case "port":
+				port = value
+			case "dbname":
+				dbname = value
+			}
+		}
+	}
+
+	if host == "" {
+		host = "localhost"
+	}
+
+	if port != "" {
+		host = net.JoinHostPort(host, port)
+	}
+
+	return host + "/" + dbname
+}
+
 // Error definitions
 var (
 	// ErrUnsupportedDatabaseType is returned when an unsupported database type is specified
@@ -61,8 +138,8 @@ const (
 	SQLite DatabaseType = "sqlite"
 )
 
-// NewDatabase creates a new database instance of the specified type
-func NewDatabase(dbType DatabaseType, config Config) (Database, error) {
+// NewDatabase creates a new database connection of the specified type
+func NewDatabase(dbType DatabaseType, config Config) (Connection, error) {
 	switch dbType {
 	case PostgreSQL:
 		return NewPostgresDB(config), nil
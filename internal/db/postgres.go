@@ -11,8 +11,23 @@ import (
 	_ "github.com/lib/pq" // PostgreSQL driver
 )
 
-// PostgresDB implements the Database interface for PostgreSQL
+// Postgres implements the Database interface for PostgreSQL on top of
+// a querier owned by the caller, such as a connection pool or a transaction
+type Postgres struct {
+	q RowsQuerier
+}
+
+// NewPostgres creates a new PostgreSQL database instance using the querier
+func NewPostgres(q RowsQuerier) *Postgres {
+	return &Postgres{
+		q: q,
+	}
+}
+
+// PostgresDB implements the Connection interface for PostgreSQL
 type PostgresDB struct {
+	*Postgres
+
 	config Config
 	db     *sql.DB
 }
@@ -49,6 +64,7 @@ func (p *PostgresDB) Connect(ctx context.Context) error {
 	}
 
 	p.db = db
+	p.Postgres = NewPostgres(FromSQL(db))
 	return nil
 }
 
@@ -61,7 +77,7 @@ func (p *PostgresDB) Close() error {
 }
 
 // GetTableNames returns a list of all tables in the database
-func (p *PostgresDB) GetTableNames(ctx context.Context, schema string) ([]string, error) {
+func (p *Postgres) GetTableNames(ctx context.Context, schema string) ([]string, error) {
 	if schema == "" {
 		schema = "public"
 	}
@@ -73,7 +89,7 @@ WHERE table_schema = $1
 AND table_type = 'BASE TABLE'
 ORDER BY table_name`
 
-	rows, err := p.db.QueryContext(ctx, query, schema)
+	rows, err := p.q.Query(ctx, query, schema)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query tables: %w", err)
 	}
@@ -97,7 +113,7 @@ ORDER BY table_name`
 }
 
 // GetPrimaryKeyColumns returns the primary key columns for a table
-func (p *PostgresDB) GetPrimaryKeyColumns(ctx context.Context, schema, tableName string) ([]string, error) {
+func (p *Postgres) GetPrimaryKeyColumns(ctx context.Context, schema, tableName string) ([]string, error) {
 	if schema == "" {
 		schema = "public"
 	}
@@ -110,7 +126,7 @@ WHERE i.indrelid = ($1 || '.' || $2)::regclass
 AND i.indisprimary
 ORDER BY a.attnum`
 
-	rows, err := p.db.QueryContext(ctx, query, schema, tableName)
+	rows, err := p.q.Query(ctx, query, schema, tableName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query primary key for %s.%s: %w", schema, tableName, err)
 	}
@@ -133,8 +149,112 @@ ORDER BY a.attnum`
 	return pkColumns, nil
 }
 
+// GetUniqueKeys returns the columns of the unique constraints and indexes of
+// a table, other than the primary key, with the narrowest keys first.
+// Partial and expression indexes are left out, since they don't identify rows.
+func (p *Postgres) GetUniqueKeys(ctx context.Context, schema, tableName string) ([][]string, error) {
+	if schema == "" {
+		schema = "public"
+	}
+
+	query := `
+SELECT c.relname, a.attname
+FROM pg_index i
+JOIN pg_class c ON c.oid = i.indexrelid
+CROSS JOIN LATERAL unnest(i.indkey) WITH ORDINALITY AS k(attnum, ord)
+JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = k.attnum
+WHERE i.indrelid = ($1 || '.' || $2)::regclass
+AND i.indisunique
+AND NOT i.indisprimary
+AND i.indpred IS NULL
+AND NOT 0 = ANY(i.indkey)
+ORDER BY i.indnatts, c.relname, k.ord`
+
+	rows, err := p.q.Query(ctx, query, schema, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query unique keys for %s.%s: %w", schema, tableName, err)
+	}
+	defer rows.Close()
+
+	var keys [][]string
+	var current string
+	for rows.Next() {
+		var indexName, columnName string
+		if err := rows.Scan(&indexName, &columnName); err != nil {
+			return nil, fmt.Errorf("failed to scan unique key column: %w", err)
+		}
+
+		if len(keys) == 0 || indexName != current {
+			keys = append(keys, nil)
+			current = indexName
+		}
+
+		keys[len(keys)-1] = append(keys[len(keys)-1], columnName)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating unique key rows: %w", err)
+	}
+
+	return keys, nil
+}
+
+// GetForeignKeys returns the foreign key constraints of a table. Tables
+// referenced in another schema are qualified by their schema.
+func (p *Postgres) GetForeignKeys(ctx context.Context, schema, tableName string) ([]ForeignKey, error) {
+	if schema == "" {
+		schema = "public"
+	}
+
+	query := `
+SELECT c.conname, a.attname, rn.nspname, rt.relname, ra.attname
+FROM pg_constraint c
+JOIN pg_class rt ON rt.oid = c.confrelid
+JOIN pg_namespace rn ON rn.oid = rt.relnamespace
+CROSS JOIN LATERAL unnest(c.conkey, c.confkey) WITH ORDINALITY AS k(attnum, refattnum, ord)
+JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.attnum
+JOIN pg_attribute ra ON ra.attrelid = c.confrelid AND ra.attnum = k.refattnum
+WHERE c.conrelid = ($1 || '.' || $2)::regclass
+AND c.contype = 'f'
+ORDER BY c.conname, k.ord`
+
+	rows, err := p.q.Query(ctx, query, schema, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query foreign keys for %s.%s: %w", schema, tableName, err)
+	}
+	defer rows.Close()
+
+	var keys []ForeignKey
+	var current string
+	for rows.Next() {
+		var constraintName, columnName, refSchema, refTable, refColumn string
+		if err := rows.Scan(&constraintName, &columnName, &refSchema, &refTable, &refColumn); err != nil {
+			return nil, fmt.Errorf("failed to scan foreign key column: %w", err)
+		}
+
+		if len(keys) == 0 || constraintName != current {
+			if refSchema != schema {
+				refTable = refSchema + "." + refTable
+			}
+
+			keys = append(keys, ForeignKey{RefTable: refTable})
+			current = constraintName
+		}
+
+		key := &keys[len(keys)-1]
+		key.Columns = append(key.Columns, columnName)
+		key.RefColumns = append(key.RefColumns, refColumn)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating foreign key rows: %w", err)
+	}
+
+	return keys, nil
+}
+
 // GetTableColumns returns all columns for a table
-func (p *PostgresDB) GetTableColumns(ctx context.Context, schema, tableName string) ([]string, error) {
+func (p *Postgres) GetTableColumns(ctx context.Context, schema, tableName string) ([]string, error) {
 	if schema == "" {
 		schema = "public"
 	}
@@ -146,7 +266,7 @@ WHERE table_schema = $1
 AND table_name = $2
 ORDER BY ordinal_position`
 
-	rows, err := p.db.QueryContext(ctx, query, schema, tableName)
+	rows, err := p.q.Query(ctx, query, schema, tableName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query columns for %s.%s: %w", schema, tableName, err)
 	}
@@ -169,8 +289,46 @@ ORDER BY ordinal_position`
 	return columns, nil
 }
 
-// QueryTableData executes a query to get all data from a table with the specified columns
-func (p *PostgresDB) QueryTableData(ctx context.Context, schema, tableName string, columns []string) ([]map[string]any, error) {
+// GetColumnTypes returns the SQL type of each column of a table, as printed by format_type
+func (p *Postgres) GetColumnTypes(ctx context.Context, schema, tableName string) (map[string]string, error) {
+	if schema == "" {
+		schema = "public"
+	}
+
+	query := `
+SELECT a.attname, format_type(a.atttypid, a.atttypmod)
+FROM pg_attribute a
+WHERE a.attrelid = ($1 || '.' || $2)::regclass
+AND a.attnum > 0
+AND NOT a.attisdropped`
+
+	rows, err := p.q.Query(ctx, query, schema, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query column types for %s.%s: %w", schema, tableName, err)
+	}
+	defer rows.Close()
+
+	types := make(map[string]string)
+	for rows.Next() {
+		var columnName, columnType string
+		if err := rows.Scan(&columnName, &columnType); err != nil {
+			return nil, fmt.Errorf("failed to scan column type: %w", err)
+		}
+
+		types[columnName] = columnType
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating column type rows: %w", err)
+	}
+
+	return types, nil
+}
+
+// QueryTableData executes a query to get all data from a table with the specified columns.
+// Rows are ordered by the orderBy columns, or by their text representation if there are none,
+// so that unchanged tables always produce the same data.
+func (p *Postgres) QueryTableData(ctx context.Context, schema, tableName string, columns, orderBy []string) ([]map[string]any, error) {
 	if schema == "" {
 		schema = "public"
 	}
@@ -188,10 +346,22 @@ func (p *PostgresDB) QueryTableData(ctx context.Context, schema, tableName strin
 	}
 
 	// Quote schema and table names
-	queryBuilder.WriteString(fmt.Sprintf(" FROM \"%s\".\"%s\"", schema, tableName))
+	queryBuilder.WriteString(fmt.Sprintf(" FROM \"%s\".\"%s\" AS snapdiff_row", schema, tableName))
+
+	if len(orderBy) > 0 {
+		queryBuilder.WriteString(" ORDER BY ")
+		for i, col := range orderBy {
+			if i > 0 {
+				queryBuilder.WriteString(", ")
+			}
+			queryBuilder.WriteString(fmt.Sprintf("\"%s\"", col))
+		}
+	} else {
+		queryBuilder.WriteString(" ORDER BY snapdiff_row::text")
+	}
 
 	query := queryBuilder.String()
-	rows, err := p.db.QueryContext(ctx, query)
+	rows, err := p.q.Query(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query table %s.%s: %w", schema, tableName, err)
 	}
@@ -225,3 +395,110 @@ func (p *PostgresDB) QueryTableData(ctx context.Context, schema, tableName strin
 
 	return tableData, nil
 }
+
+// TableFingerprint computes a fingerprint of the table contents in the database
+func (p *Postgres) TableFingerprint(
+	ctx context.Context,
+	schema, tableName string,
+	columns, orderBy []string,
+	method FingerprintMethod,
+) (string, error) {
+	if schema == "" {
+		schema = "public"
+	}
+
+	switch method {
+	case FingerprintHash:
+		return p.hashFingerprint(ctx, schema, tableName, columns, orderBy)
+	case FingerprintStats:
+		fingerprint, ok, err := p.statsFingerprint(ctx, schema, tableName)
+		if err != nil {
+			return "", err
+		}
+
+		if !ok {
+			return p.hashFingerprint(ctx, schema, tableName, columns, orderBy)
+		}
+
+		return fingerprint, nil
+	default:
+		return "", fmt.Errorf("unsupported fingerprint method: %s", method)
+	}
+}
+
+// hashFingerprint aggregates the hashes of all rows, ordered by the orderBy columns
+func (p *Postgres) hashFingerprint(ctx context.Context, schema, tableName string, columns, orderBy []string) (string, error) {
+	quotedColumns := make([]string, 0, len(columns))
+	for _, col := range columns {
+		quotedColumns = append(quotedColumns, fmt.Sprintf("\"%s\"", col))
+	}
+
+	rowHash := fmt.Sprintf("md5(ROW(%s)::text)", strings.Join(quotedColumns, ", "))
+
+	orderClause := rowHash
+	if len(orderBy) > 0 {
+		quotedOrder := make([]string, 0, len(orderBy))
+		for _, col := range orderBy {
+			quotedOrder = append(quotedOrder, fmt.Sprintf("\"%s\"", col))
+		}
+		orderClause = strings.Join(quotedOrder, ", ")
+	}
+
+	query := fmt.Sprintf(
+		"SELECT count(*), coalesce(md5(string_agg(%s, '' ORDER BY %s)), '') FROM \"%s\".\"%s\"",
+		rowHash, orderClause, schema, tableName,
+	)
+
+	rows, err := p.q.Query(ctx, query)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute fingerprint of %s.%s: %w", schema, tableName, err)
+	}
+	defer rows.Close()
+
+	var count int64
+	var hash string
+	if rows.Next() {
+		if err := rows.Scan(&count, &hash); err != nil {
+			return "", fmt.Errorf("failed to scan fingerprint of %s.%s: %w", schema, tableName, err)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("error reading fingerprint of %s.%s: %w", schema, tableName, err)
+	}
+
+	return fmt.Sprintf("hash:%d:%s", count, hash), nil
+}
+
+// statsFingerprint combines the table's cumulative modification counters
+// with its live row count, without reading the table. It returns false if
+// statistics are not available. The counters are updated when transactions
+// end and reach the statistics system with a delay, so a table changed just
+// before the snapshot may look unchanged; FingerprintHash is exact.
+func (p *Postgres) statsFingerprint(ctx context.Context, schema, tableName string) (string, bool, error) {
+	query := `
+SELECT s.n_tup_ins, s.n_tup_upd, s.n_tup_del, s.n_live_tup, s.n_mod_since_analyze
+FROM pg_stat_user_tables s
+WHERE s.relid = ($1 || '.' || $2)::regclass`
+
+	rows, err := p.q.Query(ctx, query, fmt.Sprintf("\"%s\"", schema), fmt.Sprintf("\"%s\"", tableName))
+	if err != nil {
+		return "", false, fmt.Errorf("failed to query statistics of %s.%s: %w", schema, tableName, err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return "", false, fmt.Errorf("failed to read statistics of %s.%s: %w", schema, tableName, err)
+		}
+
+		return "", false, nil
+	}
+
+	var inserted, updated, deleted, live, modified int64
+	if err := rows.Scan(&inserted, &updated, &deleted, &live, &modified); err != nil {
+		return "", false, fmt.Errorf("failed to scan statistics of %s.%s: %w", schema, tableName, err)
+	}
+
+	return fmt.Sprintf("stats:%d:%d:%d:%d:%d", inserted, updated, deleted, live, modified), true, nil
+}
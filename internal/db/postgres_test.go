@@ -0,0 +1,177 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeResult is the result of the queries containing a fragment of SQL
+type fakeResult struct {
+	fragment string
+	rows     [][]any
+}
+
+// fakeQuerier is a RowsQuerier that answers queries with canned rows and
+// records the queries it ran
+type fakeQuerier struct {
+	results []fakeResult
+	queries []string
+}
+
+func (q *fakeQuerier) Query(_ context.Context, query string, _ ...any) (Rows, error) {
+	q.queries = append(q.queries, query)
+
+	for _, result := range q.results {
+		if strings.Contains(query, result.fragment) {
+			return &fakeRows{rows: result.rows, next: -1}, nil
+		}
+	}
+
+	return &fakeRows{next: -1}, nil
+}
+
+// fakeRows iterates over canned rows
+type fakeRows struct {
+	rows [][]any
+	next int
+}
+
+func (r *fakeRows) Next() bool {
+	r.next++
+
+	return r.next < len(r.rows)
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	row := r.rows[r.next]
+	if len(dest) != len(row) {
+		return fmt.Errorf("scan of %d values into %d destinations", len(row), len(dest))
+	}
+
+	for i, value := range row {
+		reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(value))
+	}
+
+	return nil
+}
+
+func (r *fakeRows) Err() error {
+	return nil
+}
+
+func (r *fakeRows) Close() {}
+
+func TestTableFingerprint(t *testing.T) {
+	hashRows := fakeResult{fragment: "string_agg", rows: [][]any{{int64(3), "abc"}}}
+
+	tests := []struct {
+		name        string
+		method      FingerprintMethod
+		orderBy     []string
+		results     []fakeResult
+		want        string
+		wantOrderBy string
+		wantQueries int
+		wantErr     bool
+	}{
+		{
+			name:        "hash by key",
+			method:      FingerprintHash,
+			orderBy:     []string{"id"},
+			results:     []fakeResult{hashRows},
+			want:        "hash:3:abc",
+			wantOrderBy: `ORDER BY "id")`,
+		},
+		{
+			name:        "hash of a keyless table",
+			method:      FingerprintHash,
+			results:     []fakeResult{hashRows},
+			want:        "hash:3:abc",
+			wantOrderBy: `ORDER BY md5(ROW("id", "status")::text))`,
+		},
+		{
+			name:   "stats",
+			method: FingerprintStats,
+			results: []fakeResult{
+				{fragment: "pg_stat_user_tables", rows: [][]any{{int64(5), int64(2), int64(1), int64(4), int64(3)}}},
+			},
+			want:        "stats:5:2:1:4:3",
+			wantQueries: 1, // The table itself is not read
+		},
+		{
+			name:        "stats unavailable",
+			method:      FingerprintStats,
+			orderBy:     []string{"id"},
+			results:     []fakeResult{hashRows},
+			want:        "hash:3:abc",
+			wantQueries: 2,
+		},
+		{name: "unknown method", method: "size", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &fakeQuerier{results: tt.results}
+
+			got, err := NewPostgres(q).TableFingerprint(context.Background(), "", "orders", []string{"id", "status"}, tt.orderBy, tt.method)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("fingerprint = %q, want %q", got, tt.want)
+			}
+
+			if tt.wantQueries != 0 && len(q.queries) != tt.wantQueries {
+				t.Errorf("queries = %q, want %d", q.queries, tt.wantQueries)
+			}
+
+			if tt.wantOrderBy != "" && !strings.Contains(q.queries[len(q.queries)-1], tt.wantOrderBy) {
+				t.Errorf("query = %s, want %s", q.queries[len(q.queries)-1], tt.wantOrderBy)
+			}
+		})
+	}
+}
+
+func TestGetUniqueKeys(t *testing.T) {
+	q := &fakeQuerier{results: []fakeResult{{fragment: "pg_index", rows: [][]any{
+		{"events_uuid_key", "uuid"},
+		{"events_source_seq_key", "source"},
+		{"events_source_seq_key", "seq"},
+	}}}}
+
+	keys, err := NewPostgres(q).GetUniqueKeys(context.Background(), "", "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{{"uuid"}, {"source", "seq"}}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+}
+
+func TestGetForeignKeys(t *testing.T) {
+	q := &fakeQuerier{results: []fakeResult{{fragment: "pg_constraint", rows: [][]any{
+		{"items_order_fkey", "order_id", "public", "orders", "id"},
+		{"items_org_fkey", "org_id", "billing", "orgs", "id"},
+		{"items_org_fkey", "region", "billing", "orgs", "region"},
+	}}}}
+
+	keys, err := NewPostgres(q).GetForeignKeys(context.Background(), "", "items")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tables in another schema are qualified by it
+	want := []ForeignKey{
+		{Columns: []string{"order_id"}, RefTable: "orders", RefColumns: []string{"id"}},
+		{Columns: []string{"org_id", "region"}, RefTable: "billing.orgs", RefColumns: []string{"id", "region"}},
+	}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %+v, want %+v", keys, want)
+	}
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Querier is implemented by *sql.DB, *sql.Tx and *sql.Conn
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// Rows is a result set returned by a RowsQuerier.
+// It matches the method set of pgx.Rows.
+type Rows interface {
+	Next() bool
+	Scan(dest ...any) error
+	Err() error
+	Close()
+}
+
+// RowsQuerier executes queries without going through database/sql.
+// Drivers such as pgx satisfy it with a one-line adapter:
+//
+//	func (a pgxAdapter) Query(ctx context.Context, query string, args ...any) (db.Rows, error) {
+//		return a.tx.Query(ctx, query, args...)
+//	}
+type RowsQuerier interface {
+	Query(ctx context.Context, query string, args ...any) (Rows, error)
+}
+
+// FromSQL adapts a database/sql querier to a RowsQuerier
+func FromSQL(q Querier) RowsQuerier {
+	return sqlQuerier{q: q}
+}
+
+// sqlQuerier implements RowsQuerier on top of database/sql
+type sqlQuerier struct {
+	q Querier
+}
+
+// Query executes a query and returns its rows
+func (s sqlQuerier) Query(ctx context.Context, query string, args ...any) (Rows, error) {
+	rows, err := s.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return sqlRows{Rows: rows}, nil
+}
+
+// sqlRows adapts *sql.Rows to the Rows interface
+type sqlRows struct {
+	*sql.Rows
+}
+
+// Close closes the rows
+func (r sqlRows) Close() {
+	_ = r.Rows.Close()
+}
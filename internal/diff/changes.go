@@ -0,0 +1,434 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Change describes a changed value of an updated row
+type Change struct {
+	Path    string // Column name, followed by the path inside JSON values, e.g. payload.items[3].qty
+	Before  any    // Value before the change, nil if the path was added
+	After   any    // Value after the change, nil if the path was removed
+	Added   bool   // The path does not exist before the change
+	Removed bool   // The path does not exist after the change
+}
+
+// identifierPattern matches JSON object keys that need no quoting in a path
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// indexPattern matches the array indices in a path
+var indexPattern = regexp.MustCompile(`\[[0-9]*\]`)
+
+// comparer compares the rows of a table
+type comparer struct {
+	table         string
+	types         map[string]string // Column types, empty if unknown
+	ignoreColumns map[string]bool
+	rules         []CompareRule
+	key           []string              // Columns rows are matched by, empty to match them as a multiset
+	identity      []string              // Columns that identify rows across key changes
+	similarity    float64               // Fraction of equal columns to pair unmatched rows as updates, 0 to never pair them
+	comparisons   map[string]comparison // Resolved comparison options by path without indices
+}
+
+// comparison returns the options the values at a path are compared with
+func (c *comparer) comparison(path string) comparison {
+	key := indexPattern.ReplaceAllString(path, "")
+	if cmp, ok := c.comparisons[key]; ok {
+		return cmp
+	}
+
+	if c.comparisons == nil {
+		c.comparisons = make(map[string]comparison)
+	}
+
+	cmp := resolveComparison(c.rules, c.table, key, c.pathType(path))
+	c.comparisons[key] = cmp
+
+	return cmp
+}
+
+// pathType returns the type of the values at a path: the column type for a
+// column, the element type for the elements of an array column, and ""
+// inside JSON values
+func (c *comparer) pathType(path string) string {
+	if colType, ok := c.types[path]; ok {
+		return colType
+	}
+
+	col := path
+	if i := strings.IndexAny(path, ".["); i >= 0 {
+		col = path[:i]
+	}
+
+	colType := c.types[col]
+	if !strings.HasSuffix(colType, "[]") {
+		return ""
+	}
+
+	for strings.HasSuffix(colType, "[]") {
+		colType = strings.TrimSuffix(colType, "[]")
+	}
+
+	return colType
+}
+
+// rowChanges returns the changes between two versions of a row, sorted by
+// column. JSON and array values are compared key by key and element by
+// element, so that a change deep inside a value is reported by its path.
+func (c *comparer) rowChanges(before, after map[string]any) []Change {
+	columns := make(map[string]bool)
+	for col := range before {
+		columns[col] = true
+	}
+	for col := range after {
+		columns[col] = true
+	}
+
+	sorted := make([]string, 0, len(columns))
+	for col := range columns {
+		if !c.ignoreColumns[col] {
+			sorted = append(sorted, col)
+		}
+	}
+	sort.Strings(sorted)
+
+	var changes []Change
+	for _, col := range sorted {
+		beforeVal, beforeOK := before[col]
+		afterVal, afterOK := after[col]
+
+		switch {
+		case !beforeOK:
+			changes = append(changes, Change{Path: col, After: afterVal, Added: true})
+		case !afterOK:
+			changes = append(changes, Change{Path: col, Before: beforeVal, Removed: true})
+		case reflect.DeepEqual(beforeVal, afterVal), c.comparison(col).equal(beforeVal, afterVal):
+		default:
+			changes = append(changes, c.columnChanges(col, beforeVal, afterVal)...)
+		}
+	}
+
+	return changes
+}
+
+// columnChanges returns the changes of a column value, by path for JSON and array values
+func (c *comparer) columnChanges(col string, before, after any) []Change {
+	beforeDoc, beforeOK := c.document(col, before)
+	afterDoc, afterOK := c.document(col, after)
+
+	if beforeOK && afterOK {
+		// Values that differ only in formatting are still reported as changed
+		if !reflect.DeepEqual(beforeDoc, afterDoc) {
+			return c.valueChanges(col, beforeDoc, afterDoc)
+		}
+	}
+
+	return []Change{{Path: col, Before: before, After: after}}
+}
+
+// document returns the structured value a column value holds: the document
+// of a JSON column, or the elements of an array column. If the column type
+// is unknown, only JSON objects and arrays are recognized, and PostgreSQL
+// array literals of columns with an array mode.
+func (c *comparer) document(col string, value any) (any, bool) {
+	colType := c.types[col]
+
+	if strings.HasSuffix(colType, "[]") {
+		if s, ok := value.(string); ok {
+			return parseArray(s)
+		}
+	}
+
+	isJSON := colType == "json" || colType == "jsonb"
+	if colType != "" && !isJSON {
+		return nil, false
+	}
+
+	switch v := value.(type) {
+	case map[string]any, []any:
+		return v, true
+	case string:
+		trimmed := strings.TrimSpace(v)
+		if !isJSON && !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+			return nil, false
+		}
+
+		var doc any
+		if err := json.Unmarshal([]byte(trimmed), &doc); err == nil {
+			return doc, true
+		}
+
+		if colType == "" && c.comparison(col).array != ArrayOrdered {
+			return parseArray(trimmed)
+		}
+
+		return nil, false
+	default:
+		return nil, false
+	}
+}
+
+// valueChanges returns the changes between two JSON or array values at a path
+func (c *comparer) valueChanges(path string, before, after any) []Change {
+	switch b := before.(type) {
+	case map[string]any:
+		if a, ok := after.(map[string]any); ok {
+			return c.objectChanges(path, b, a)
+		}
+	case []any:
+		if a, ok := after.([]any); ok {
+			return c.arrayChanges(path, b, a)
+		}
+	}
+
+	if c.comparison(path).equal(before, after) {
+		return nil
+	}
+
+	return []Change{{Path: path, Before: before, After: after}}
+}
+
+// objectChanges returns the changes between two JSON objects, sorted by key
+func (c *comparer) objectChanges(path string, before, after map[string]any) []Change {
+	keys := make(map[string]bool)
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var changes []Change
+	for _, k := range sorted {
+		child := path + pathKey(k)
+		beforeVal, beforeOK := before[k]
+		afterVal, afterOK := after[k]
+
+		switch {
+		case !beforeOK:
+			changes = append(changes, Change{Path: child, After: afterVal, Added: true})
+		case !afterOK:
+			changes = append(changes, Change{Path: child, Before: beforeVal, Removed: true})
+		default:
+			changes = append(changes, c.valueChanges(child, beforeVal, afterVal)...)
+		}
+	}
+
+	return changes
+}
+
+// arrayChanges returns the changes between two arrays according to the
+// array mode of the path
+func (c *comparer) arrayChanges(path string, before, after []any) []Change {
+	mode := c.comparison(path).array
+	if mode == ArrayOrdered {
+		return c.orderedChanges(path, before, after)
+	}
+
+	return unorderedChanges(path, before, after, mode == ArrayMultiset)
+}
+
+// orderedChanges returns the changes between two arrays, element by element
+func (c *comparer) orderedChanges(path string, before, after []any) []Change {
+	var changes []Change
+	for i := 0; i < max(len(before), len(after)); i++ {
+		child := fmt.Sprintf("%s[%d]", path, i)
+
+		switch {
+		case i >= len(before):
+			changes = append(changes, Change{Path: child, After: after[i], Added: true})
+		case i >= len(after):
+			changes = append(changes, Change{Path: child, Before: before[i], Removed: true})
+		default:
+			changes = append(changes, c.valueChanges(child, before[i], after[i])...)
+		}
+	}
+
+	return changes
+}
+
+// unorderedChanges returns the elements removed from and added to an array
+// regardless of their order, at the path "<path>[]". Duplicates count only
+// for multisets.
+func unorderedChanges(path string, before, after []any, multiset bool) []Change {
+	counts := make(map[string]int)
+	for _, elem := range after {
+		key := elementKey(elem)
+		if multiset || counts[key] == 0 {
+			counts[key]++
+		}
+	}
+
+	child := path + "[]"
+
+	var changes []Change
+	seen := make(map[string]bool)
+	for _, elem := range before {
+		key := elementKey(elem)
+		if !multiset {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+
+		if counts[key] > 0 {
+			counts[key]--
+		} else {
+			changes = append(changes, Change{Path: child, Before: elem, Removed: true})
+		}
+	}
+
+	for _, elem := range after {
+		key := elementKey(elem)
+		if counts[key] > 0 {
+			counts[key]--
+			changes = append(changes, Change{Path: child, After: elem, Added: true})
+		}
+	}
+
+	return changes
+}
+
+// elementKey returns a key that is equal for equal array elements
+func elementKey(elem any) string {
+	data, err := json.Marshal(elem)
+	if err != nil {
+		return fmt.Sprint(elem)
+	}
+
+	return string(data)
+}
+
+// pathKey returns the path segment of an object key: .key, or ["key"] for
+// keys that are not identifiers
+func pathKey(key string) string {
+	if identifierPattern.MatchString(key) {
+		return "." + key
+	}
+
+	return "[" + strconv.Quote(key) + "]"
+}
+
+// parseArray parses the text of a PostgreSQL array, e.g. {a,"b c",NULL}.
+// Elements are kept as text; NULL elements are nil.
+func parseArray(s string) (any, bool) {
+	p := arrayParser{s: s}
+
+	elems, ok := p.parse()
+	if !ok || p.pos != len(p.s) {
+		return nil, false
+	}
+
+	return elems, true
+}
+
+// arrayParser parses the text of a PostgreSQL array
+type arrayParser struct {
+	s   string
+	pos int
+}
+
+// parse parses an array starting at the current position
+func (p *arrayParser) parse() ([]any, bool) {
+	if p.pos >= len(p.s) || p.s[p.pos] != '{' {
+		return nil, false
+	}
+	p.pos++
+
+	elems := []any{}
+	if p.pos < len(p.s) && p.s[p.pos] == '}' {
+		p.pos++
+
+		return elems, true
+	}
+
+	for p.pos < len(p.s) {
+		elem, ok := p.element()
+		if !ok {
+			return nil, false
+		}
+		elems = append(elems, elem)
+
+		if p.pos >= len(p.s) {
+			return nil, false
+		}
+
+		switch p.s[p.pos] {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+
+			return elems, true
+		default:
+			return nil, false
+		}
+	}
+
+	return nil, false
+}
+
+// element parses an array element: a nested array, a quoted or an unquoted value
+func (p *arrayParser) element() (any, bool) {
+	if p.pos >= len(p.s) {
+		return nil, false
+	}
+
+	switch p.s[p.pos] {
+	case '{':
+		return p.parse()
+	case '"':
+		p.pos++
+
+		var b strings.Builder
+		for p.pos < len(p.s) {
+			ch := p.s[p.pos]
+			p.pos++
+
+			switch ch {
+			case '\\':
+				if p.pos >= len(p.s) {
+					return nil, false
+				}
+				b.WriteByte(p.s[p.pos])
+				p.pos++
+			case '"':
+				return b.String(), true
+			default:
+				b.WriteByte(ch)
+			}
+		}
+
+		return nil, false
+	default:
+		start := p.pos
+		for p.pos < len(p.s) && p.s[p.pos] != ',' && p.s[p.pos] != '}' {
+			p.pos++
+		}
+
+		value := strings.TrimSpace(p.s[start:p.pos])
+		if value == "" {
+			return nil, false
+		}
+
+		if strings.EqualFold(value, "NULL") {
+			return nil, true
+		}
+
+		return value, true
+	}
+}
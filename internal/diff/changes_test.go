@@ -0,0 +1,188 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRowChangesJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		types  map[string]string
+		before any
+		after  any
+		want   []Change
+	}{
+		{
+			name:   "nested value",
+			before: map[string]any{"items": []any{map[string]any{"qty": 1.0}}},
+			after:  map[string]any{"items": []any{map[string]any{"qty": 2.0}}},
+			want:   []Change{{Path: "payload.items[0].qty", Before: 1.0, After: 2.0}},
+		},
+		{
+			name:   "added and removed keys",
+			before: map[string]any{"a": 1.0, "b": 2.0},
+			after:  map[string]any{"b": 2.0, "c": 3.0},
+			want: []Change{
+				{Path: "payload.a", Before: 1.0, Removed: true},
+				{Path: "payload.c", After: 3.0, Added: true},
+			},
+		},
+		{
+			name:   "key that is not an identifier",
+			before: map[string]any{"first name": "Ann"},
+			after:  map[string]any{"first name": "Bob"},
+			want:   []Change{{Path: `payload["first name"]`, Before: "Ann", After: "Bob"}},
+		},
+		{
+			name:   "array element added",
+			before: map[string]any{"tags": []any{"a"}},
+			after:  map[string]any{"tags": []any{"a", "b"}},
+			want:   []Change{{Path: "payload.tags[1]", After: "b", Added: true}},
+		},
+		{
+			name:   "JSON text of a jsonb column",
+			types:  map[string]string{"payload": "jsonb"},
+			before: `{"status": "new"}`,
+			after:  `{"status": "paid"}`,
+			want:   []Change{{Path: "payload.status", Before: "new", After: "paid"}},
+		},
+		{
+			name:   "formatting only",
+			types:  map[string]string{"payload": "json"},
+			before: `{"status": "new"}`,
+			after:  `{"status":"new"}`,
+			want:   []Change{{Path: "payload", Before: `{"status": "new"}`, After: `{"status":"new"}`}},
+		},
+		{
+			name:   "text that is not JSON",
+			types:  map[string]string{"payload": "text"},
+			before: `{"status": "new"}`,
+			after:  `{"status": "paid"}`,
+			want:   []Change{{Path: "payload", Before: `{"status": "new"}`, After: `{"status": "paid"}`}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &comparer{table: "orders", types: tt.types}
+
+			changes := c.rowChanges(map[string]any{"id": 1, "payload": tt.before}, map[string]any{"id": 1, "payload": tt.after})
+			if !reflect.DeepEqual(changes, tt.want) {
+				t.Errorf("changes = %+v, want %+v", changes, tt.want)
+			}
+		})
+	}
+}
+
+func TestRowChangesIgnoredColumns(t *testing.T) {
+	c := &comparer{table: "orders", ignoreColumns: map[string]bool{"updated_at": true}}
+
+	changes := c.rowChanges(
+		map[string]any{"id": 1, "status": "new", "updated_at": "monday"},
+		map[string]any{"id": 1, "status": "paid", "updated_at": "tuesday", "note": "x"},
+	)
+
+	want := []Change{
+		{Path: "note", After: "x", Added: true},
+		{Path: "status", Before: "new", After: "paid"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes = %+v, want %+v", changes, want)
+	}
+}
+
+func TestRowChangesArrays(t *testing.T) {
+	tests := []struct {
+		name   string
+		types  map[string]string
+		mode   ArrayMode
+		before any
+		after  any
+		want   []Change
+	}{
+		{
+			name:   "ordered",
+			before: []any{"a", "b"},
+			after:  []any{"b", "a"},
+			want: []Change{
+				{Path: "tags[0]", Before: "a", After: "b"},
+				{Path: "tags[1]", Before: "b", After: "a"},
+			},
+		},
+		{name: "unordered set, reordered", mode: ArrayUnorderedSet, before: []any{"a", "b"}, after: []any{"b", "a", "a"}},
+		{
+			name:   "unordered set, changed",
+			mode:   ArrayUnorderedSet,
+			before: []any{"a", "b"},
+			after:  []any{"b", "c"},
+			want: []Change{
+				{Path: "tags[]", Before: "a", Removed: true},
+				{Path: "tags[]", After: "c", Added: true},
+			},
+		},
+		{
+			name:   "multiset counts duplicates",
+			mode:   ArrayMultiset,
+			before: []any{"a", "b"},
+			after:  []any{"b", "a", "a"},
+			want:   []Change{{Path: "tags[]", After: "a", Added: true}},
+		},
+		{
+			name:   "PostgreSQL array of an array column",
+			types:  map[string]string{"tags": "text[]"},
+			mode:   ArrayUnorderedSet,
+			before: `{a,"b c"}`,
+			after:  `{"b c",d}`,
+			want: []Change{
+				{Path: "tags[]", Before: "a", Removed: true},
+				{Path: "tags[]", After: "d", Added: true},
+			},
+		},
+		{
+			name:   "PostgreSQL array of an untyped column with an array mode",
+			mode:   ArrayUnorderedSet,
+			before: `{a,b}`,
+			after:  `{b,a}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &comparer{table: "posts", types: tt.types}
+			if tt.mode != "" {
+				c.rules = []CompareRule{{Column: "tags", Array: tt.mode}}
+			}
+
+			changes := c.rowChanges(map[string]any{"tags": tt.before}, map[string]any{"tags": tt.after})
+			if !reflect.DeepEqual(changes, tt.want) {
+				t.Errorf("changes = %+v, want %+v", changes, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseArray(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   any
+		wantOK bool
+	}{
+		{input: "{}", want: []any{}, wantOK: true},
+		{input: "{a,b}", want: []any{"a", "b"}, wantOK: true},
+		{input: `{"a,b","c \"d\""}`, want: []any{"a,b", `c "d"`}, wantOK: true},
+		{input: "{1,NULL}", want: []any{"1", nil}, wantOK: true},
+		{input: "{{1,2},{3,4}}", want: []any{[]any{"1", "2"}, []any{"3", "4"}}, wantOK: true},
+		{input: "{a,b"},
+		{input: "{a,}"},
+		{input: "{a}b"},
+		{input: "a,b"},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseArray(tt.input)
+		if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseArray(%q) = %v, %v, want %v, %v", tt.input, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
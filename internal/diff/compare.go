@@ -0,0 +1,167 @@
+package diff
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timestampLayouts are the text formats timestamps are stored in
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// equal reports whether two values are equal under the comparison options
+func (cmp comparison) equal(a, b any) bool {
+	if cmp.nullEqualsEmpty {
+		a, b = nullToEmpty(a), nullToEmpty(b)
+	}
+
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+
+	if cmp.numeric() {
+		if x, ok := numberOf(a); ok {
+			if y, ok := numberOf(b); ok {
+				return cmp.numbersEqual(x, y)
+			}
+		}
+	}
+
+	if cmp.temporal() {
+		if x, ok := timeOf(a); ok {
+			if y, ok := timeOf(b); ok {
+				d := x.Sub(y)
+				if d < 0 {
+					d = -d
+				}
+
+				return d <= cmp.timeTolerance
+			}
+		}
+	}
+
+	if cmp.ignoreCase || cmp.ignoreWhitespace {
+		if x, ok := a.(string); ok {
+			if y, ok := b.(string); ok {
+				return cmp.text(x) == cmp.text(y)
+			}
+		}
+	}
+
+	return false
+}
+
+// numeric reports whether numbers are compared by value
+func (cmp comparison) numeric() bool {
+	return cmp.tolerance > 0 || cmp.relativeTolerance > 0 || cmp.scale != nil
+}
+
+// temporal reports whether timestamps are compared as instants
+func (cmp comparison) temporal() bool {
+	return cmp.timeTolerance > 0 || cmp.normalizeTimezone
+}
+
+// numbersEqual compares two numbers, rounded to the scale, within the tolerances
+func (cmp comparison) numbersEqual(x, y *big.Rat) bool {
+	if cmp.scale != nil {
+		x, y = round(x, *cmp.scale), round(y, *cmp.scale)
+	}
+
+	if x.Cmp(y) == 0 {
+		return true
+	}
+
+	diff, _ := new(big.Rat).Sub(x, y).Float64()
+	diff = math.Abs(diff)
+	if diff <= cmp.tolerance {
+		return true
+	}
+
+	fx, _ := x.Float64()
+	fy, _ := y.Float64()
+
+	return diff <= cmp.relativeTolerance*math.Max(math.Abs(fx), math.Abs(fy))
+}
+
+// text normalizes text for comparison
+func (cmp comparison) text(s string) string {
+	if cmp.ignoreWhitespace {
+		s = strings.Join(strings.Fields(s), " ")
+	}
+
+	if cmp.ignoreCase {
+		s = strings.ToLower(s)
+	}
+
+	return s
+}
+
+// nullToEmpty returns the empty string for NULL
+func nullToEmpty(v any) any {
+	if v == nil {
+		return ""
+	}
+
+	return v
+}
+
+// numberOf returns the exact value of a number, or of the text of a number
+// as numeric columns are stored
+func numberOf(v any) (*big.Rat, bool) {
+	switch n := v.(type) {
+	case float64:
+		if math.IsNaN(n) || math.IsInf(n, 0) {
+			return nil, false
+		}
+
+		return new(big.Rat).SetFloat64(n), true
+	case int64:
+		return new(big.Rat).SetInt64(n), true
+	case int:
+		return new(big.Rat).SetInt64(int64(n)), true
+	case json.Number:
+		return new(big.Rat).SetString(n.String())
+	case string:
+		text := strings.TrimSpace(n)
+		if _, err := strconv.ParseFloat(text, 64); err != nil {
+			return nil, false
+		}
+
+		return new(big.Rat).SetString(text)
+	default:
+		return nil, false
+	}
+}
+
+// round rounds a number to the given decimal places, halves away from zero
+func round(x *big.Rat, scale int) *big.Rat {
+	r, _ := new(big.Rat).SetString(x.FloatString(scale))
+
+	return r
+}
+
+// timeOf parses the text of a timestamp. Timestamps without an offset are taken as UTC.
+func timeOf(v any) (time.Time, bool) {
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
@@ -0,0 +1,122 @@
+package diff
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestComparisonEqual(t *testing.T) {
+	two := 2
+
+	tests := []struct {
+		name string
+		cmp  comparison
+		a, b any
+		want bool
+	}{
+		{name: "equal", a: "x", b: "x", want: true},
+		{name: "numbers without rules", a: 1.0, b: "1", want: false},
+		{name: "within tolerance", cmp: comparison{tolerance: 0.01}, a: 10.001, b: "10.0", want: true},
+		{name: "outside tolerance", cmp: comparison{tolerance: 0.01}, a: 10.1, b: 10.0},
+		{name: "within relative tolerance", cmp: comparison{relativeTolerance: 0.01}, a: 1000.0, b: 1009.0, want: true},
+		{name: "outside relative tolerance", cmp: comparison{relativeTolerance: 0.01}, a: 1000.0, b: 1011.0},
+		{name: "equal after rounding", cmp: comparison{scale: &two}, a: "10.001", b: "10.004", want: true},
+		{name: "different after rounding", cmp: comparison{scale: &two}, a: "10.004", b: "10.005"},
+		{name: "exact decimals", cmp: comparison{scale: &two}, a: "12345678901234567890.12", b: "12345678901234567890.13"},
+		{name: "within time tolerance", cmp: comparison{timeTolerance: time.Second}, a: "2024-01-01T10:00:00.5Z", b: "2024-01-01 10:00:00", want: true},
+		{name: "outside time tolerance", cmp: comparison{timeTolerance: time.Second}, a: "2024-01-01T10:00:02Z", b: "2024-01-01T10:00:00Z"},
+		{name: "same instant", cmp: comparison{normalizeTimezone: true}, a: "2024-01-01T12:00:00+02:00", b: "2024-01-01T10:00:00Z", want: true},
+		{name: "different offset", a: "2024-01-01T12:00:00+02:00", b: "2024-01-01T10:00:00Z"},
+		{name: "ignore case", cmp: comparison{ignoreCase: true}, a: "Ann", b: "ANN", want: true},
+		{name: "ignore whitespace", cmp: comparison{ignoreWhitespace: true}, a: " a  b ", b: "a b", want: true},
+		{name: "null equals empty", cmp: comparison{nullEqualsEmpty: true}, a: nil, b: "", want: true},
+		{name: "null is not empty", a: nil, b: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cmp.equal(tt.a, tt.b); got != tt.want {
+				t.Errorf("equal(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveComparison(t *testing.T) {
+	rules := []CompareRule{
+		{Table: "orders", Column: "amount", Tolerance: 0.5},
+		{Type: "numeric", Tolerance: 0.01, IgnoreCase: true},
+		{Column: "payload.items.qty", Tolerance: 1},
+		{Column: "tags", Array: ArrayMultiset},
+	}
+
+	tests := []struct {
+		name    string
+		table   string
+		path    string
+		colType string
+		want    comparison
+	}{
+		{name: "no rule", table: "orders", path: "status", colType: "text", want: comparison{array: ArrayOrdered}},
+		{name: "first rule wins", table: "orders", path: "amount", colType: "numeric", want: comparison{array: ArrayOrdered, tolerance: 0.5, ignoreCase: true}},
+		{name: "by type", table: "payments", path: "amount", colType: "numeric", want: comparison{array: ArrayOrdered, tolerance: 0.01, ignoreCase: true}},
+		{name: "path inside JSON", table: "orders", path: "payload.items.qty", want: comparison{array: ArrayOrdered, tolerance: 1}},
+		{name: "array mode", table: "posts", path: "tags", colType: "text[]", want: comparison{array: ArrayMultiset}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveComparison(rules, tt.table, tt.path, tt.colType); got != tt.want {
+				t.Errorf("comparison = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadCompareRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "valid",
+			content: "rules:\n  - column: amount\n    tolerance: 0.01\n  - type: timestamp*\n    time_tolerance: 1s\nkeys:\n  events: [id]\nrow_identity:\n  users: [uuid]\n",
+		},
+		{name: "no column or type", content: "rules:\n  - tolerance: 0.01\n", wantErr: "column or type is required"},
+		{name: "invalid pattern", content: "rules:\n  - column: \"[\"\n", wantErr: "invalid pattern"},
+		{name: "unknown array mode", content: "rules:\n  - column: tags\n    array: sorted\n", wantErr: "unknown array mode"},
+		{name: "negative tolerance", content: "rules:\n  - column: amount\n    tolerance: -1\n", wantErr: "must not be negative"},
+		{name: "negative scale", content: "rules:\n  - column: amount\n    scale: -1\n", wantErr: "scale must not be negative"},
+		{name: "empty key", content: "keys:\n  events: []\n", wantErr: "key of table events has no columns"},
+		{name: "empty row identity", content: "row_identity:\n  users: []\n", wantErr: "row identity of table users has no columns"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rules.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			ruleSet, err := LoadCompareRules(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(ruleSet.Rules) != 2 || ruleSet.Rules[1].TimeTolerance != time.Second || ruleSet.RowIdentity["users"][0] != "uuid" {
+				t.Errorf("rule set = %+v", ruleSet)
+			}
+		})
+	}
+}
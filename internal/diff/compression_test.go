@@ -0,0 +1,57 @@
+package diff
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rom8726/snapdiff/internal/storage"
+)
+
+func TestRunCompressedSnapshots(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store, err := storage.NewStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots := []struct {
+		label       string
+		compression storage.Compression
+		rows        []map[string]any
+	}{
+		{label: "a", compression: storage.CompressionNone, rows: []map[string]any{{"id": 1, "status": "new"}, {"id": 2, "status": "new"}}},
+		{label: "b", compression: storage.CompressionZstd, rows: []map[string]any{{"id": 1, "status": "paid"}, {"id": 2, "status": "new"}}},
+	}
+
+	for _, snapshot := range snapshots {
+		w, err := store.CreateSnapshot(ctx, snapshot.label, storage.WriteOptions{Compression: snapshot.compression})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := w.WriteTable(ctx, storage.TableInfo{Name: "orders"}, snapshot.rows); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := w.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// An uncompressed snapshot compares with a compressed one row by row
+	result, err := Run(ctx, Options{From: "a", To: "b", BaseDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orders := result.Tables["orders"]
+	if orders == nil || len(orders.Inserted) != 0 || len(orders.Deleted) != 0 || len(orders.Updated) != 1 {
+		t.Fatalf("orders = %+v, want one updated row", orders)
+	}
+
+	if after := orders.Updated[0].After; after["status"] != "paid" {
+		t.Errorf("updated row = %v, want status paid", after)
+	}
+}
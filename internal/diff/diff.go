@@ -4,17 +4,26 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"slices"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/rom8726/snapdiff/internal/storage"
 )
 
 // TableDiff represents the differences between two snapshots of a table
 type TableDiff struct {
-	TableName string
-	Inserted  []map[string]any
-	Updated   []UpdatedRow
-	Deleted   []map[string]any
+	TableName   string
+	RenamedFrom string // Name of the table in the 'from' snapshot, if it was renamed
+	Inserted    []map[string]any
+	Updated     []UpdatedRow
+	Deleted     []map[string]any
+	Rekeyed     []RekeyedRow
+	Types       map[string]string    // Column types, empty if unknown
+	Key         []string             // Columns the rows are matched by, empty if they are matched as a multiset
+	foreignKeys []storage.ForeignKey // Foreign keys of the table, empty if unknown
+	Dangling    []DanglingReference  // Rows of the 'to' snapshot that reference a missing row
 }
 
 // UpdatedRow represents a row that was updated
@@ -22,34 +31,208 @@ type UpdatedRow struct {
 	PrimaryKey map[string]any
 	Before     map[string]any
 	After      map[string]any
+	Changes    []Change // Changed columns, and paths inside changed JSON values
+}
+
+// RekeyedRow represents a row whose key changed, e.g. when a migration
+// renumbers IDs, matched by its other columns or by its row identity columns
+type RekeyedRow struct {
+	OldKey  map[string]any
+	NewKey  map[string]any
+	Before  map[string]any
+	After   map[string]any
+	Changes []Change // Changed columns besides the key, if matched by row identity
 }
 
 // Result contains all table diffs
 type Result struct {
-	Tables map[string]*TableDiff
+	Tables       map[string]*TableDiff
+	CompareRules []CompareRule // Rules the values were compared with
+}
+
+// Source provides the tables of a snapshot to compare
+type Source interface {
+	// TableNames returns the names of all tables in the snapshot
+	TableNames(ctx context.Context) ([]string, error)
+
+	// LoadTable returns the rows of a table
+	LoadTable(ctx context.Context, tableName string) ([]map[string]any, error)
+
+	// TableHash returns the content hash of a table, or "" if it is unknown
+	TableHash(ctx context.Context, tableName string) string
+}
+
+// ColumnTyper is implemented by sources that know the database types of
+// their columns, which tells which values are JSON documents
+type ColumnTyper interface {
+	// ColumnTypes returns the type of each column of a table
+	ColumnTypes(ctx context.Context, tableName string) map[string]string
+}
+
+// storageSource reads a stored snapshot
+type storageSource struct {
+	store    *storage.Storage
+	manifest *storage.Manifest
+}
+
+// newStorageSource loads the manifest of a stored snapshot
+func newStorageSource(ctx context.Context, store *storage.Storage, label string) (*storageSource, error) {
+	manifest, err := store.LoadManifest(ctx, label)
+	if err != nil {
+		return nil, err
+	}
+
+	if !manifest.Complete {
+		return nil, fmt.Errorf("snapshot %s is incomplete", label)
+	}
+
+	return &storageSource{store: store, manifest: manifest}, nil
+}
+
+// TableNames returns the names of all tables in the stored snapshot
+func (s *storageSource) TableNames(context.Context) ([]string, error) {
+	return s.manifest.TableNames(), nil
+}
+
+// LoadTable loads the rows of a table from the stored snapshot
+func (s *storageSource) LoadTable(ctx context.Context, tableName string) ([]map[string]any, error) {
+	return s.store.ReadTable(ctx, s.manifest, tableName)
+}
+
+// LoadTableNumbers loads the rows of a table from the stored snapshot with
+// numbers as they are stored
+func (s *storageSource) LoadTableNumbers(ctx context.Context, tableName string) ([]map[string]any, error) {
+	return s.store.ReadTableNumbers(ctx, s.manifest, tableName)
+}
+
+// TableHash returns the content hash recorded in the manifest
+func (s *storageSource) TableHash(_ context.Context, tableName string) string {
+	if table, ok := s.manifest.Table(tableName); ok {
+		return table.Hash
+	}
+
+	return ""
+}
+
+// TableKeys returns the key constraints recorded in the manifest
+func (s *storageSource) TableKeys(_ context.Context, tableName string) *storage.Keys {
+	if table, ok := s.manifest.Table(tableName); ok {
+		return table.Keys
+	}
+
+	return nil
+}
+
+// ColumnTypes returns the column types recorded in the manifest
+func (s *storageSource) ColumnTypes(_ context.Context, tableName string) map[string]string {
+	types := make(map[string]string)
+	if table, ok := s.manifest.Table(tableName); ok {
+		for _, col := range table.Columns {
+			types[col.Name] = col.Type
+		}
+	}
+
+	return types
 }
 
 // Run executes the diff command
-func Run(_ context.Context, opts Options) (*Result, error) {
+func Run(ctx context.Context, opts Options) (*Result, error) {
 	store, err := storage.NewStorage(opts.BaseDir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create storage: %w", err)
 	}
 
-	var tables []string
-	if len(opts.Tables) > 0 {
-		tables = opts.Tables
-	} else {
-		fromTables, err := store.ListSnapshotTables(opts.From)
+	if err := store.UseIdentity(opts.Identity); err != nil {
+		return nil, err
+	}
+
+	if opts.MatchSimilar < 0 || opts.MatchSimilar > 1 {
+		return nil, fmt.Errorf("match similarity %v is not between 0 and 1", opts.MatchSimilar)
+	}
+
+	if opts.CompareRulesFile != "" {
+		ruleSet, err := LoadCompareRules(opts.CompareRulesFile)
 		if err != nil {
-			return nil, fmt.Errorf("failed to list tables in 'from' snapshot: %w", err)
+			return nil, err
 		}
 
-		toTables, err := store.ListSnapshotTables(opts.To)
+		opts.CompareRules = append(slices.Clip(opts.CompareRules), ruleSet.Rules...)
+		opts.Keys = mergeKeys(opts.Keys, ruleSet.Keys)
+		opts.RowIdentity = mergeKeys(opts.RowIdentity, ruleSet.RowIdentity)
+	}
+
+	from, err := newStorageSource(ctx, store, opts.From)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables in 'from' snapshot: %w", err)
+	}
+
+	to, err := newStorageSource(ctx, store, opts.To)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables in 'to' snapshot: %w", err)
+	}
+
+	return Compare(ctx, from, to, opts)
+}
+
+// Compare computes the differences between two snapshots
+func Compare(ctx context.Context, from, to Source, opts Options) (*Result, error) {
+	fromTables, err := from.TableNames(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables in 'from' snapshot: %w", err)
+	}
+
+	toTables, err := to.TableNames(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables in 'to' snapshot: %w", err)
+	}
+
+	ignoreColumnsMap := make(map[string]bool)
+	for _, col := range opts.IgnoreColumns {
+		ignoreColumnsMap[col] = true
+	}
+
+	renames, err := detectRenames(ctx, from, to, fromTables, toTables, opts.TableMap, ignoreColumnsMap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect renamed tables: %w", err)
+	}
+
+	// Renamed tables are compared under their new names
+	if len(renames) > 0 {
+		from = newRenamedSource(from, renames)
+
+		fromTables, err = from.TableNames(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("failed to list tables in 'to' snapshot: %w", err)
+			return nil, fmt.Errorf("failed to list tables in 'from' snapshot: %w", err)
 		}
+	}
+
+	fromTableMap := make(map[string]bool)
+	for _, t := range fromTables {
+		fromTableMap[t] = true
+	}
+
+	toTableMap := make(map[string]bool)
+	for _, t := range toTables {
+		toTableMap[t] = true
+	}
 
+	var tables []string
+	if len(opts.Tables) > 0 {
+		newNames := make(map[string]string, len(renames))
+		for newName, oldName := range renames {
+			newNames[oldName] = newName
+		}
+
+		// A renamed table is selected by either name
+		for _, t := range opts.Tables {
+			if newName, ok := newNames[t]; ok {
+				t = newName
+			}
+			if !slices.Contains(tables, t) {
+				tables = append(tables, t)
+			}
+		}
+	} else {
 		tableMap := make(map[string]bool)
 		for _, t := range fromTables {
 			tableMap[t] = true
@@ -65,123 +248,294 @@ func Run(_ context.Context, opts Options) (*Result, error) {
 		sort.Strings(tables)
 	}
 
-	ignoreColumnsMap := make(map[string]bool)
-	for _, col := range opts.IgnoreColumns {
-		ignoreColumnsMap[col] = true
+	result := &Result{
+		Tables:       make(map[string]*TableDiff),
+		CompareRules: opts.CompareRules,
 	}
 
-	result := &Result{
-		Tables: make(map[string]*TableDiff),
+	// Rows of tables that reference or are referenced by foreign keys are
+	// kept to look for dangling references
+	related := make(map[string]bool)
+	if opts.CheckForeignKeys {
+		for _, t := range toTables {
+			for _, fk := range foreignKeys(ctx, t, to) {
+				related[t] = true
+				related[fk.RefTable] = true
+			}
+		}
 	}
 
+	toCache := make(map[string][]map[string]any)
+	changed := make(map[string]bool)
+	keys := make(map[string][]string)
+
 	for _, tableName := range tables {
-		fromData, err := store.LoadSnapshot(opts.From, tableName, storage.FormatJSON)
-		if err != nil {
-			// If table doesn't exist in 'from', all rows are inserted
-			fromData = []any{}
+		keys[tableName] = keyColumns(ctx, tableName, opts.Keys[tableName], from, to)
+
+		// Tables with the same content hash are unchanged and need not be read
+		if hash := from.TableHash(ctx, tableName); hash != "" && hash == to.TableHash(ctx, tableName) {
+			if !opts.OnlyChanged || renames[tableName] != "" {
+				result.Tables[tableName] = &TableDiff{TableName: tableName, RenamedFrom: renames[tableName]}
+			}
+
+			continue
 		}
 
-		toData, err := store.LoadSnapshot(opts.To, tableName, storage.FormatJSON)
-		if err != nil {
-			// If table doesn't exist in 'to', all rows are deleted
-			toData = []any{}
+		// If table doesn't exist in 'from', all rows are inserted
+		fromRows := []map[string]any{}
+		if fromTableMap[tableName] {
+			fromRows, err = from.LoadTable(ctx, tableName)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load table %s from 'from' snapshot: %w", tableName, err)
+			}
 		}
 
-		fromRows := convertToMapSlice(fromData)
-		toRows := convertToMapSlice(toData)
+		// If table doesn't exist in 'to', all rows are deleted
+		toRows := []map[string]any{}
+		if toTableMap[tableName] {
+			toRows, err = to.LoadTable(ctx, tableName)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load table %s from 'to' snapshot: %w", tableName, err)
+			}
+
+			if related[tableName] {
+				toCache[tableName] = toRows
+			}
+		}
 
-		tableDiff, err := compareRows(tableName, fromRows, toRows, ignoreColumnsMap)
+		c := &comparer{
+			table:         tableName,
+			types:         columnTypes(ctx, tableName, from, to),
+			ignoreColumns: ignoreColumnsMap,
+			rules:         opts.CompareRules,
+			key:           keys[tableName],
+			identity:      opts.RowIdentity[tableName],
+			similarity:    opts.MatchSimilar,
+		}
+
+		tableDiff, err := c.compareRows(fromRows, toRows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to compare rows for table %s: %w", tableName, err)
 		}
 
-		if opts.OnlyChanged && len(tableDiff.Inserted) == 0 && len(tableDiff.Updated) == 0 && len(tableDiff.Deleted) == 0 {
+		tableDiff.Types = c.types
+		tableDiff.RenamedFrom = renames[tableName]
+		tableDiff.Key = c.key
+		tableDiff.foreignKeys = foreignKeys(ctx, tableName, to, from)
+		changed[tableName] = tableDiff.HasChanges()
+
+		if opts.OnlyChanged && !tableDiff.HasChanges() {
 			continue
 		}
 
 		result.Tables[tableName] = tableDiff
 	}
 
+	if !opts.CheckForeignKeys {
+		return result, nil
+	}
+
+	loadTo := func(tableName string) ([]map[string]any, bool, error) {
+		if rows, ok := toCache[tableName]; ok {
+			return rows, true, nil
+		}
+
+		if !toTableMap[tableName] {
+			return nil, false, nil
+		}
+
+		rows, err := to.LoadTable(ctx, tableName)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to load table %s from 'to' snapshot: %w", tableName, err)
+		}
+		toCache[tableName] = rows
+
+		return rows, true, nil
+	}
+
+	dangling, err := danglingReferences(ctx, to, tables, changed, keys, loadTo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check foreign keys: %w", err)
+	}
+
+	for tableName, refs := range dangling {
+		tableDiff, ok := result.Tables[tableName]
+		if !ok {
+			tableDiff = &TableDiff{TableName: tableName, RenamedFrom: renames[tableName], Key: keys[tableName]}
+			result.Tables[tableName] = tableDiff
+		}
+
+		tableDiff.Dangling = refs
+	}
+
 	return result, nil
 }
 
-// convertToMapSlice converts an any to []map[string]any
-func convertToMapSlice(data any) []map[string]any {
-	if data == nil {
-		return []map[string]any{}
+// columnTypes returns the known column types of a table in either source
+func columnTypes(ctx context.Context, tableName string, sources ...Source) map[string]string {
+	types := make(map[string]string)
+	for _, source := range sources {
+		typer, ok := source.(ColumnTyper)
+		if !ok {
+			continue
+		}
+
+		for col, colType := range typer.ColumnTypes(ctx, tableName) {
+			if colType != "" {
+				types[col] = colType
+			}
+		}
 	}
 
-	if rows, ok := data.([]map[string]any); ok {
-		return rows
+	return types
+}
+
+// ColumnChanged reports whether a column, or a path inside it, changed
+func (r *UpdatedRow) ColumnChanged(column string) bool {
+	return changesColumn(r.Changes, column)
+}
+
+// ColumnChanged reports whether a column, a key column included, or a path inside it changed
+func (r *RekeyedRow) ColumnChanged(column string) bool {
+	if oldValue, ok := r.OldKey[column]; ok {
+		return !reflect.DeepEqual(oldValue, r.NewKey[column])
 	}
 
-	if rows, ok := data.([]any); ok {
-		result := make([]map[string]any, 0, len(rows))
-		for _, row := range rows {
-			if rowMap, ok := row.(map[string]any); ok {
-				result = append(result, rowMap)
-			}
+	return changesColumn(r.Changes, column)
+}
+
+// changesColumn reports whether any of the changes is of a column or a path inside it
+func changesColumn(changes []Change, column string) bool {
+	for _, change := range changes {
+		if change.Path == column || strings.HasPrefix(change.Path, column+".") || strings.HasPrefix(change.Path, column+"[") {
+			return true
 		}
-		return result
 	}
 
-	return []map[string]any{}
+	return false
 }
 
-// compareRows compares two sets of rows and returns the differences
-func compareRows(tableName string, fromRows, toRows []map[string]any, ignoreColumns map[string]bool) (*TableDiff, error) {
-	result := &TableDiff{
-		TableName: tableName,
+// HasChanges reports whether the table was renamed or has any inserted,
+// updated, deleted or rekeyed rows
+func (d *TableDiff) HasChanges() bool {
+	return d.RenamedFrom != "" || len(d.Inserted) > 0 || len(d.Updated) > 0 || len(d.Deleted) > 0 || len(d.Rekeyed) > 0
+}
+
+// ValuesEqual reports whether two values of a column, or at a path inside a
+// JSON or array column, of a table are equal under the comparison rules
+func (r *Result) ValuesEqual(tableName, path string, a, b any) bool {
+	c := &comparer{table: tableName, rules: r.CompareRules}
+	if tableDiff, ok := r.Tables[tableName]; ok {
+		c.types = tableDiff.Types
 	}
 
-	fromMap := make(map[string]map[string]any)
-	toMap := make(map[string]map[string]any)
+	return c.comparison(path).equal(a, b)
+}
 
-	for _, row := range fromRows {
-		key := generateRowKey(row)
-		fromMap[key] = row
+// HasChanges reports whether any table in the result has changes
+func (r *Result) HasChanges() bool {
+	for _, tableDiff := range r.Tables {
+		if tableDiff.HasChanges() {
+			return true
+		}
 	}
 
-	for _, row := range toRows {
-		key := generateRowKey(row)
-		toMap[key] = row
+	return false
+}
+
+// compareRows compares two sets of rows and returns the differences. Rows
+// are matched by their key columns; rows without a key value of their own
+// are matched as a multiset. Deleted and inserted rows that are the same
+// row under a new key are reported as rekeyed.
+func (c *comparer) compareRows(fromRows, toRows []map[string]any) (*TableDiff, error) {
+	result := &TableDiff{
+		TableName: c.table,
 	}
 
-	for key, row := range toMap {
+	fromMap, fromRest := c.keyRows(fromRows)
+	toMap, toRest := c.keyRows(toRows)
+
+	// Rows whose key is NULL or duplicated can't be matched by it
+	for key, rows := range fromMap {
+		if len(rows) > 1 || len(toMap[key]) > 1 {
+			fromRest = append(fromRest, rows...)
+			toRest = append(toRest, toMap[key]...)
+			delete(fromMap, key)
+			delete(toMap, key)
+		}
+	}
+	for key, rows := range toMap {
+		if len(rows) > 1 {
+			toRest = append(toRest, rows...)
+			delete(toMap, key)
+		}
+	}
+
+	for _, key := range sortedRowKeys(toMap) {
 		if _, exists := fromMap[key]; !exists {
-			result.Inserted = append(result.Inserted, row)
+			result.Inserted = append(result.Inserted, toMap[key][0])
 		}
 	}
 
-	for key, row := range fromMap {
-		if _, exists := toMap[key]; !exists {
-			result.Deleted = append(result.Deleted, row)
+	for _, key := range sortedRowKeys(fromMap) {
+		fromRow := fromMap[key][0]
+
+		toRows, exists := toMap[key]
+		if !exists {
+			result.Deleted = append(result.Deleted, fromRow)
+
+			continue
+		}
+
+		if updatedRow, ok := c.updatedRow(fromRow, toRows[0]); ok {
+			result.Updated = append(result.Updated, updatedRow)
 		}
 	}
 
-	for key, fromRow := range fromMap {
-		if toRow, exists := toMap[key]; exists {
-			if !rowsEqual(fromRow, toRow, ignoreColumns) {
-				updatedRow := UpdatedRow{
-					PrimaryKey: extractPrimaryKey(fromRow),
-					Before:     filterIgnoredColumns(fromRow, ignoreColumns),
-					After:      filterIgnoredColumns(toRow, ignoreColumns),
-				}
+	result.Rekeyed, result.Deleted, result.Inserted = c.matchRekeyed(result.Deleted, result.Inserted)
+
+	deleted, inserted := c.matchMultiset(fromRest, toRest)
+
+	if c.similarity > 0 {
+		var pairs [][2]map[string]any
+		pairs, deleted, inserted = c.pairSimilar(deleted, inserted)
+
+		for _, pair := range pairs {
+			if updatedRow, ok := c.updatedRow(pair[0], pair[1]); ok {
 				result.Updated = append(result.Updated, updatedRow)
 			}
 		}
 	}
 
+	result.Inserted = append(result.Inserted, inserted...)
+	result.Deleted = append(result.Deleted, deleted...)
+
 	return result, nil
 }
 
-// generateRowKey generates a unique key for a row based on its values
-func generateRowKey(row map[string]any) string {
-	// For simplicity, we'll use the "id" column if it exists
-	if id, ok := row["id"]; ok {
-		return fmt.Sprintf("%v", id)
+// updatedRow returns the changes of a row, or false if it is unchanged
+func (c *comparer) updatedRow(fromRow, toRow map[string]any) (UpdatedRow, bool) {
+	if rowsEqual(fromRow, toRow, c.ignoreColumns) {
+		return UpdatedRow{}, false
+	}
+
+	// Rows that differ only within the comparison rules are unchanged
+	changes := c.rowChanges(fromRow, toRow)
+	if len(changes) == 0 {
+		return UpdatedRow{}, false
 	}
 
+	return UpdatedRow{
+		PrimaryKey: c.primaryKey(fromRow),
+		Before:     filterIgnoredColumns(fromRow, c.ignoreColumns),
+		After:      filterIgnoredColumns(toRow, c.ignoreColumns),
+		Changes:    changes,
+	}, true
+}
+
+// generateRowKey generates a key for a row from all its values, which orders
+// rows that have no key columns
+func generateRowKey(row map[string]any) string {
 	var key string
 	keys := make([]string, 0, len(row))
 	for k := range row {
@@ -196,6 +550,31 @@ func generateRowKey(row map[string]any) string {
 	return key
 }
 
+// sortedRowKeys returns the row keys in a stable order, numeric keys compared by value
+func sortedRowKeys(rows map[string][]map[string]any) []string {
+	keys := make([]string, 0, len(rows))
+	for key := range rows {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return lessRowKey(keys[i], keys[j])
+	})
+
+	return keys
+}
+
+// lessRowKey orders row keys, numeric keys by value
+func lessRowKey(a, b string) bool {
+	x, errX := strconv.ParseFloat(a, 64)
+	y, errY := strconv.ParseFloat(b, 64)
+	if errX == nil && errY == nil && x != y {
+		return x < y
+	}
+
+	return a < b
+}
+
 // rowsEqual checks if two rows are equal, ignoring specified columns
 func rowsEqual(row1, row2 map[string]any, ignoreColumns map[string]bool) bool {
 	for key, val1 := range row1 {
@@ -227,19 +606,6 @@ func rowsEqual(row1, row2 map[string]any, ignoreColumns map[string]bool) bool {
 	return true
 }
 
-// extractPrimaryKey extracts the primary key from a row
-func extractPrimaryKey(row map[string]any) map[string]any {
-	result := make(map[string]any)
-
-	// For simplicity, we'll use the "id" column if it exists
-	if id, ok := row["id"]; ok {
-		result["id"] = id
-		return result
-	}
-
-	return row
-}
-
 // filterIgnoredColumns returns a copy of the row with ignored columns removed
 func filterIgnoredColumns(row map[string]any, ignoreColumns map[string]bool) map[string]any {
 	result := make(map[string]any)
@@ -0,0 +1,131 @@
+package diff
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/rom8726/snapdiff/internal/storage"
+)
+
+// memTable is a table of a memSource
+type memTable struct {
+	rows  []map[string]any
+	types map[string]string
+	keys  *storage.Keys
+	hash  string
+}
+
+// memSource is an in-memory Source that knows column types and keys
+type memSource map[string]*memTable
+
+func (s memSource) TableNames(context.Context) ([]string, error) {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (s memSource) LoadTable(_ context.Context, tableName string) ([]map[string]any, error) {
+	if table, ok := s[tableName]; ok {
+		return table.rows, nil
+	}
+
+	return nil, nil
+}
+
+func (s memSource) TableHash(_ context.Context, tableName string) string {
+	if table, ok := s[tableName]; ok {
+		return table.hash
+	}
+
+	return ""
+}
+
+func (s memSource) ColumnTypes(_ context.Context, tableName string) map[string]string {
+	if table, ok := s[tableName]; ok {
+		return table.types
+	}
+
+	return nil
+}
+
+func (s memSource) TableKeys(_ context.Context, tableName string) *storage.Keys {
+	if table, ok := s[tableName]; ok {
+		return table.keys
+	}
+
+	return nil
+}
+
+// row builds a row from column and value pairs
+func row(pairs ...any) map[string]any {
+	r := make(map[string]any, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		r[pairs[i].(string)] = pairs[i+1]
+	}
+
+	return r
+}
+
+// idKeys are the keys of a table with an id primary key
+func idKeys(foreign ...storage.ForeignKey) *storage.Keys {
+	return &storage.Keys{PrimaryKey: []string{"id"}, Foreign: foreign}
+}
+
+// counts returns the numbers of inserted, updated, deleted and rekeyed rows of a table diff
+func counts(d *TableDiff) [4]int {
+	if d == nil {
+		return [4]int{}
+	}
+
+	return [4]int{len(d.Inserted), len(d.Updated), len(d.Deleted), len(d.Rekeyed)}
+}
+
+func TestCompare(t *testing.T) {
+	from := memSource{
+		"orders": {keys: idKeys(), rows: []map[string]any{
+			row("id", 1, "status", "new"),
+			row("id", 2, "status", "new"),
+			row("id", 3, "status", "new"),
+		}},
+	}
+	to := memSource{
+		"orders": {keys: idKeys(), rows: []map[string]any{
+			row("id", 1, "status", "new"),
+			row("id", 2, "status", "paid"),
+			row("id", 4, "status", "shipped"),
+		}},
+	}
+
+	result, err := Compare(context.Background(), from, to, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := counts(result.Tables["orders"]), [4]int{1, 1, 1, 0}; got != want {
+		t.Errorf("counts = %v, want %v", got, want)
+	}
+
+	updated := result.Tables["orders"].Updated[0]
+	if len(updated.Changes) != 1 || updated.Changes[0].Path != "status" {
+		t.Errorf("changes = %+v, want a change of status", updated.Changes)
+	}
+}
+
+func TestCompareSkipsTablesWithEqualHashes(t *testing.T) {
+	from := memSource{"orders": {keys: idKeys(), hash: "h", rows: []map[string]any{row("id", 1)}}}
+	to := memSource{"orders": {keys: idKeys(), hash: "h", rows: []map[string]any{row("id", 2)}}}
+
+	result, err := Compare(context.Background(), from, to, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.HasChanges() {
+		t.Errorf("tables with equal hashes were compared")
+	}
+}
@@ -0,0 +1,376 @@
+package diff
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/rom8726/snapdiff/internal/storage"
+)
+
+// ChangeKind is the kind of change of a row
+type ChangeKind string
+
+const (
+	ChangeInserted ChangeKind = "inserted"
+	ChangeUpdated  ChangeKind = "updated"
+	ChangeDeleted  ChangeKind = "deleted"
+	ChangeRekeyed  ChangeKind = "rekeyed"
+)
+
+// DanglingReference is a row of the 'to' snapshot whose foreign key
+// references a row that does not exist
+type DanglingReference struct {
+	Key        map[string]any // Key columns of the referencing row, or the whole row
+	Columns    []string       // Referencing columns
+	Values     []any          // Values of the referencing columns
+	RefTable   string
+	RefColumns []string
+}
+
+// Aggregate is a root row with the changed rows that reference it, directly
+// or through other changed rows, e.g. an order with its items and payments
+type Aggregate struct {
+	Table   string         // Table of the root row
+	Key     map[string]any // Key of the root row
+	Changed bool           // The root row itself changed
+	Changes []RowChange    // Changed rows, the root first if it changed, then by depth
+}
+
+// RowChange is a changed row of an aggregate
+type RowChange struct {
+	Table   string
+	Kind    ChangeKind
+	Key     map[string]any // Key columns of the row, the new key of a rekeyed row
+	OldKey  map[string]any // Old key of a rekeyed row
+	Row     map[string]any // The row after the change, or before it if it was deleted
+	Changes []Change       // Changed columns of updated and rekeyed rows
+	Depth   int            // Number of references between the row and the root
+}
+
+// foreignKeys returns the foreign keys of a table from the first source that knows them
+func foreignKeys(ctx context.Context, tableName string, sources ...Source) []storage.ForeignKey {
+	for _, source := range sources {
+		keySource, ok := source.(KeySource)
+		if !ok {
+			continue
+		}
+
+		if keys := keySource.TableKeys(ctx, tableName); keys != nil {
+			return keys.Foreign
+		}
+	}
+
+	return nil
+}
+
+// danglingReferences returns the rows of the 'to' snapshot that reference a
+// missing row, by table. Only tables that changed, or that reference a
+// table that changed, are checked.
+func danglingReferences(
+	ctx context.Context,
+	to Source,
+	tables []string,
+	changed map[string]bool,
+	keys map[string][]string,
+	loadTo func(tableName string) ([]map[string]any, bool, error),
+) (map[string][]DanglingReference, error) {
+	result := make(map[string][]DanglingReference)
+
+	for _, tableName := range tables {
+		fks := foreignKeys(ctx, tableName, to)
+		if len(fks) == 0 {
+			continue
+		}
+
+		affected := changed[tableName]
+		for _, fk := range fks {
+			affected = affected || changed[fk.RefTable]
+		}
+		if !affected {
+			continue
+		}
+
+		rows, exists, err := loadTo(tableName)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+
+		for _, fk := range fks {
+			// References to a table or columns the snapshot lacks can't be checked
+			if !hasColumns(ctx, to, fk.RefTable, fk.RefColumns) {
+				continue
+			}
+
+			refRows, refExists, err := loadTo(fk.RefTable)
+			if err != nil {
+				return nil, err
+			}
+			if !refExists {
+				continue
+			}
+
+			for _, row := range MissingReferences(rows, fk.Columns, refRows, fk.RefColumns) {
+				values := make([]any, 0, len(fk.Columns))
+				for _, col := range fk.Columns {
+					values = append(values, row[col])
+				}
+
+				result[tableName] = append(result[tableName], DanglingReference{
+					Key:        rowKeyColumns(row, keys[tableName]),
+					Columns:    fk.Columns,
+					Values:     values,
+					RefTable:   fk.RefTable,
+					RefColumns: fk.RefColumns,
+				})
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// MissingReferences returns the rows whose columns match the refColumns of
+// no row of refRows. Rows with a NULL in the columns are not checked, as in SQL.
+func MissingReferences(rows []map[string]any, columns []string, refRows []map[string]any, refColumns []string) []map[string]any {
+	existing := make(map[string]bool, len(refRows))
+	for _, row := range refRows {
+		if key, ok := columnsKey(row, refColumns); ok {
+			existing[key] = true
+		}
+	}
+
+	var missing []map[string]any
+	for _, row := range rows {
+		if key, ok := columnsKey(row, columns); ok && !existing[key] {
+			missing = append(missing, row)
+		}
+	}
+
+	return missing
+}
+
+// hasColumns reports whether the columns of a table in the source include
+// the given ones. Tables with unknown columns are assumed to have them.
+func hasColumns(ctx context.Context, source Source, tableName string, columns []string) bool {
+	typer, ok := source.(ColumnTyper)
+	if !ok {
+		return true
+	}
+
+	types := typer.ColumnTypes(ctx, tableName)
+	if len(types) == 0 {
+		return true
+	}
+
+	for _, col := range columns {
+		if _, ok := types[col]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// aggregateNode is a changed row, or an unchanged row that changed rows reference
+type aggregateNode struct {
+	change   RowChange
+	versions []map[string]any // Versions of the row other rows may reference
+	changed  bool
+	parent   int // Index of the referenced node, -1 for roots
+	children []int
+}
+
+// GroupByForeignKeys clusters the changed rows of the result into
+// aggregates around root rows, following the foreign keys of their tables.
+// A changed row belongs to the changed row it references; a row that
+// references no changed row belongs to the unchanged row that most of such
+// rows reference. Rows of tables without foreign keys form aggregates of
+// their own.
+func (r *Result) GroupByForeignKeys() []Aggregate {
+	names := make([]string, 0, len(r.Tables))
+	for name := range r.Tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var nodes []*aggregateNode
+	add := func(change RowChange, versions ...map[string]any) {
+		nodes = append(nodes, &aggregateNode{change: change, versions: versions, changed: true, parent: -1})
+	}
+
+	for _, name := range names {
+		d := r.Tables[name]
+		for _, row := range d.Inserted {
+			add(RowChange{Table: name, Kind: ChangeInserted, Key: rowKeyColumns(row, d.Key), Row: row}, row)
+		}
+		for _, row := range d.Updated {
+			add(RowChange{Table: name, Kind: ChangeUpdated, Key: row.PrimaryKey, Row: row.After, Changes: row.Changes}, row.Before, row.After)
+		}
+		for _, row := range d.Deleted {
+			add(RowChange{Table: name, Kind: ChangeDeleted, Key: rowKeyColumns(row, d.Key), Row: row}, row)
+		}
+		for _, row := range d.Rekeyed {
+			add(RowChange{Table: name, Kind: ChangeRekeyed, Key: row.NewKey, OldKey: row.OldKey, Row: row.After, Changes: row.Changes}, row.Before, row.After)
+		}
+	}
+
+	changedCount := len(nodes)
+
+	// Changed rows by table, referenced columns and their values
+	index := make(map[string][]int)
+	indexed := make(map[string]bool)
+	lookup := func(table string, columns []string, value string) []int {
+		prefix := table + "\x00" + strings.Join(columns, "\x00") + "\x00"
+		if !indexed[prefix] {
+			indexed[prefix] = true
+			for i := 0; i < changedCount; i++ {
+				node := nodes[i]
+				if node.change.Table != table {
+					continue
+				}
+
+				for _, row := range node.versions {
+					if key, ok := columnsKey(row, columns); ok {
+						index[prefix+key] = append(index[prefix+key], i)
+					}
+				}
+			}
+		}
+
+		return index[prefix+value]
+	}
+
+	// Link rows to the changed rows they reference
+	type reference struct {
+		node int
+		ref  string // Table and values of the referenced row
+		fk   storage.ForeignKey
+	}
+	var unresolved [][]reference
+	var unresolvedNodes []int
+	votes := make(map[string]int)
+
+	for i := 0; i < changedCount; i++ {
+		node := nodes[i]
+		d := r.Tables[node.change.Table]
+
+		var refs []reference
+		for _, fk := range d.foreignKeys {
+			key, ok := columnsKey(node.change.Row, fk.Columns)
+			if !ok {
+				continue
+			}
+
+			if node.parent < 0 {
+				for _, p := range lookup(fk.RefTable, fk.RefColumns, key) {
+					if p != i {
+						node.parent = p
+
+						break
+					}
+				}
+			}
+
+			refs = append(refs, reference{node: i, ref: fk.RefTable + "\x00" + key, fk: fk})
+		}
+
+		if node.parent < 0 && len(refs) > 0 {
+			unresolved = append(unresolved, refs)
+			unresolvedNodes = append(unresolvedNodes, i)
+			for _, ref := range refs {
+				votes[ref.ref]++
+			}
+		}
+	}
+
+	// Rows that reference no changed row belong to the unchanged row most of them reference
+	unchanged := make(map[string]int)
+	for j, refs := range unresolved {
+		best := refs[0]
+		for _, ref := range refs[1:] {
+			if votes[ref.ref] > votes[best.ref] {
+				best = ref
+			}
+		}
+
+		p, ok := unchanged[best.ref]
+		if !ok {
+			values := make(map[string]any, len(best.fk.RefColumns))
+			row := nodes[best.node].change.Row
+			for k, col := range best.fk.RefColumns {
+				values[col] = row[best.fk.Columns[k]]
+			}
+
+			p = len(nodes)
+			nodes = append(nodes, &aggregateNode{change: RowChange{Table: best.fk.RefTable, Key: values}, parent: -1})
+			unchanged[best.ref] = p
+		}
+
+		nodes[unresolvedNodes[j]].parent = p
+	}
+
+	// Break reference cycles, e.g. of self-referencing tables
+	for i := range nodes {
+		seen := map[int]bool{i: true}
+		for p := nodes[i].parent; p >= 0; p = nodes[p].parent {
+			if seen[p] {
+				nodes[i].parent = -1
+
+				break
+			}
+			seen[p] = true
+		}
+	}
+
+	for i, node := range nodes {
+		if node.parent >= 0 {
+			nodes[node.parent].children = append(nodes[node.parent].children, i)
+		}
+	}
+
+	// Aggregates in the order of their first changed row
+	var aggregates []Aggregate
+	placed := make(map[int]bool)
+	for i := 0; i < changedCount; i++ {
+		root := i
+		for nodes[root].parent >= 0 {
+			root = nodes[root].parent
+		}
+		if placed[root] {
+			continue
+		}
+		placed[root] = true
+
+		aggregate := Aggregate{
+			Table:   nodes[root].change.Table,
+			Key:     nodes[root].change.Key,
+			Changed: nodes[root].changed,
+		}
+
+		level := []int{root}
+		for depth := 0; len(level) > 0; depth++ {
+			var next []int
+			for _, n := range level {
+				node := nodes[n]
+				if node.changed {
+					change := node.change
+					change.Depth = depth
+					aggregate.Changes = append(aggregate.Changes, change)
+				}
+				next = append(next, node.children...)
+			}
+			sort.SliceStable(next, func(a, b int) bool {
+				return nodes[next[a]].change.Table < nodes[next[b]].change.Table
+			})
+			level = next
+		}
+
+		aggregates = append(aggregates, aggregate)
+	}
+
+	return aggregates
+}
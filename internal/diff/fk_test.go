@@ -0,0 +1,199 @@
+package diff
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/rom8726/snapdiff/internal/storage"
+)
+
+// orderItemsFK references orders(id) from order_items(order_id)
+var orderItemsFK = storage.ForeignKey{Columns: []string{"order_id"}, RefTable: "orders", RefColumns: []string{"id"}}
+
+func TestDanglingReferences(t *testing.T) {
+	orderTypes := map[string]string{"id": "integer", "status": "text"}
+	itemTypes := map[string]string{"id": "integer", "order_id": "integer"}
+
+	from := memSource{
+		"orders": {keys: idKeys(), types: orderTypes, rows: []map[string]any{
+			row("id", 1, "status", "new"),
+			row("id", 2, "status", "new"),
+		}},
+		"order_items": {keys: idKeys(orderItemsFK), types: itemTypes, rows: []map[string]any{
+			row("id", 10, "order_id", 1),
+			row("id", 11, "order_id", 2),
+		}},
+	}
+
+	tests := []struct {
+		name      string
+		to        memSource
+		check     bool
+		wantTable string
+		want      []DanglingReference
+	}{
+		{
+			name: "deleted parent",
+			to: memSource{
+				"orders": {keys: idKeys(), types: orderTypes, rows: []map[string]any{row("id", 1, "status", "new")}},
+				"order_items": {keys: idKeys(orderItemsFK), types: itemTypes, rows: []map[string]any{
+					row("id", 10, "order_id", 1),
+					row("id", 11, "order_id", 2),
+					row("id", 12, "order_id", nil),
+				}},
+			},
+			check:     true,
+			wantTable: "order_items",
+			want: []DanglingReference{{
+				Key:        row("id", 11),
+				Columns:    []string{"order_id"},
+				Values:     []any{2},
+				RefTable:   "orders",
+				RefColumns: []string{"id"},
+			}},
+		},
+		{
+			name: "not checked without the option",
+			to: memSource{
+				"orders":      {keys: idKeys(), types: orderTypes, rows: []map[string]any{row("id", 1, "status", "new")}},
+				"order_items": from["order_items"],
+			},
+		},
+		{
+			name: "referenced table not captured",
+			to: memSource{
+				"order_items": {keys: idKeys(orderItemsFK), types: itemTypes, rows: []map[string]any{
+					row("id", 10, "order_id", 1),
+					row("id", 13, "order_id", 3),
+				}},
+			},
+			check: true,
+		},
+		{
+			name: "referenced column not captured",
+			to: memSource{
+				"orders": {keys: idKeys(), types: map[string]string{"status": "text"}, rows: []map[string]any{row("status", "new")}},
+				"order_items": {keys: idKeys(orderItemsFK), types: itemTypes, rows: []map[string]any{
+					row("id", 10, "order_id", 1),
+					row("id", 13, "order_id", 3),
+				}},
+			},
+			check: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Compare(context.Background(), from, tt.to, Options{CheckForeignKeys: tt.check})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for name, d := range result.Tables {
+				var want []DanglingReference
+				if name == tt.wantTable {
+					want = tt.want
+				}
+
+				if !reflect.DeepEqual(d.Dangling, want) {
+					t.Errorf("dangling references of %s = %+v, want %+v", name, d.Dangling, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMissingReferences(t *testing.T) {
+	refRows := []map[string]any{row("id", 1, "tenant", "a"), row("id", 2, "tenant", "b")}
+
+	tests := []struct {
+		name       string
+		rows       []map[string]any
+		columns    []string
+		refColumns []string
+		want       int
+	}{
+		{name: "all present", rows: []map[string]any{row("ref", 1), row("ref", 2)}, columns: []string{"ref"}, refColumns: []string{"id"}},
+		{name: "missing", rows: []map[string]any{row("ref", 1), row("ref", 3)}, columns: []string{"ref"}, refColumns: []string{"id"}, want: 1},
+		{name: "null is not checked", rows: []map[string]any{row("ref", nil)}, columns: []string{"ref"}, refColumns: []string{"id"}},
+		{
+			name:       "composite",
+			rows:       []map[string]any{row("ref", 1, "t", "a"), row("ref", 1, "t", "b")},
+			columns:    []string{"ref", "t"},
+			refColumns: []string{"id", "tenant"},
+			want:       1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MissingReferences(tt.rows, tt.columns, refRows, tt.refColumns); len(got) != tt.want {
+				t.Errorf("missing references = %v, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupByForeignKeys(t *testing.T) {
+	customersFK := storage.ForeignKey{Columns: []string{"customer_id"}, RefTable: "customers", RefColumns: []string{"id"}}
+	paymentsFK := storage.ForeignKey{Columns: []string{"order_id"}, RefTable: "orders", RefColumns: []string{"id"}}
+
+	from := memSource{
+		"customers": {keys: idKeys(), rows: []map[string]any{row("id", 1)}},
+		"orders": {keys: idKeys(customersFK), rows: []map[string]any{
+			row("id", 42, "customer_id", 1, "status", "pending"),
+		}},
+		"order_items": {keys: idKeys(orderItemsFK), rows: []map[string]any{}},
+		"payments":    {keys: idKeys(paymentsFK), rows: []map[string]any{}},
+		"audit":       {keys: idKeys(), rows: []map[string]any{}},
+	}
+	to := memSource{
+		"customers": from["customers"],
+		"orders": {keys: idKeys(customersFK), rows: []map[string]any{
+			row("id", 42, "customer_id", 1, "status", "paid"),
+		}},
+		"order_items": {keys: idKeys(orderItemsFK), rows: []map[string]any{row("id", 1, "order_id", 42)}},
+		"payments":    {keys: idKeys(paymentsFK), rows: []map[string]any{row("id", 7, "order_id", 42)}},
+		"audit":       {keys: idKeys(), rows: []map[string]any{row("id", 1)}},
+	}
+
+	result, err := Compare(context.Background(), from, to, Options{OnlyChanged: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type change struct {
+		table string
+		kind  ChangeKind
+		depth int
+	}
+
+	type aggregate struct {
+		table   string
+		changed bool
+		changes []change
+	}
+
+	var got []aggregate
+	for _, a := range result.GroupByForeignKeys() {
+		g := aggregate{table: a.Table, changed: a.Changed}
+		for _, c := range a.Changes {
+			g.changes = append(g.changes, change{c.Table, c.Kind, c.Depth})
+		}
+		got = append(got, g)
+	}
+
+	want := []aggregate{
+		{table: "audit", changed: true, changes: []change{{"audit", ChangeInserted, 0}}},
+		{table: "customers", changes: []change{
+			{"orders", ChangeUpdated, 1},
+			{"order_items", ChangeInserted, 2},
+			{"payments", ChangeInserted, 2},
+		}},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("aggregates = %+v, want %+v", got, want)
+	}
+}
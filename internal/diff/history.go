@@ -0,0 +1,362 @@
+package diff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rom8726/snapdiff/internal/storage"
+)
+
+// History is the timeline of a row across a series of snapshots
+type History struct {
+	Table string         // Table of the row
+	Key   map[string]any // Key columns of the row and their values
+	Steps []HistoryStep  // One step per snapshot, in the order of the series
+}
+
+// HistoryStep is the state of the row in a snapshot of the series and its
+// change since the previous snapshot
+type HistoryStep struct {
+	Label     string
+	CreatedAt time.Time
+	Present   bool           // The row exists in the snapshot
+	Kind      ChangeKind     // Change since the previous snapshot, empty if there is none or this is the first snapshot
+	Row       map[string]any // The row in the snapshot, nil if it does not exist
+	Changes   []Change       // Changed columns of an updated row
+}
+
+// SeriesSnapshot is a snapshot of a series
+type SeriesSnapshot struct {
+	Label     string
+	CreatedAt time.Time
+	Source    Source
+}
+
+// NumberSource is a Source that can load rows with numbers as they are
+// stored, so large integer keys are matched exactly
+type NumberSource interface {
+	LoadTableNumbers(ctx context.Context, tableName string) ([]map[string]any, error)
+}
+
+// keyCondition requires a key column of the row to have a value
+type keyCondition struct {
+	Column string
+	Value  string
+}
+
+// RunHistory executes the history command
+func RunHistory(ctx context.Context, opts HistoryOptions) (*History, error) {
+	store, err := storage.NewStorage(opts.BaseDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage: %w", err)
+	}
+
+	if err := store.UseIdentity(opts.Identity); err != nil {
+		return nil, err
+	}
+
+	if opts.CompareRulesFile != "" {
+		ruleSet, err := LoadCompareRules(opts.CompareRulesFile)
+		if err != nil {
+			return nil, err
+		}
+
+		opts.CompareRules = append(slices.Clip(opts.CompareRules), ruleSet.Rules...)
+	}
+
+	manifests, err := chronologicalSnapshots(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+
+	labels, err := seriesLabels(manifests, opts.Labels)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]SeriesSnapshot, 0, len(labels))
+	for _, label := range labels {
+		source, err := newStorageSource(ctx, store, label)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load snapshot %s: %w", label, err)
+		}
+
+		snapshots = append(snapshots, SeriesSnapshot{Label: label, CreatedAt: source.manifest.CreatedAt, Source: source})
+	}
+
+	return RowHistory(ctx, snapshots, opts)
+}
+
+// chronologicalSnapshots returns the manifests of all complete snapshots in
+// the order they were created
+func chronologicalSnapshots(ctx context.Context, store *storage.Storage) ([]*storage.Manifest, error) {
+	labels, err := store.ListSnapshots(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	manifests := make([]*storage.Manifest, 0, len(labels))
+	for _, label := range labels {
+		manifest, err := store.LoadMetadata(ctx, label)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load snapshot %s: %w", label, err)
+		}
+
+		if manifest.Complete {
+			manifests = append(manifests, manifest)
+		}
+	}
+
+	sort.SliceStable(manifests, func(i, j int) bool {
+		return manifests[i].CreatedAt.Before(manifests[j].CreatedAt)
+	})
+
+	return manifests, nil
+}
+
+// seriesLabels resolves snapshot labels and first..last ranges of
+// chronologically ordered snapshots into the labels of a series. Either end
+// of a range may be left out. No specs select all snapshots.
+func seriesLabels(manifests []*storage.Manifest, specs []string) ([]string, error) {
+	all := make([]string, 0, len(manifests))
+	for _, manifest := range manifests {
+		all = append(all, manifest.Label)
+	}
+
+	if len(specs) == 0 {
+		return all, nil
+	}
+
+	var labels []string
+	for _, spec := range specs {
+		first, last, isRange := strings.Cut(spec, "..")
+		if !isRange {
+			if !slices.Contains(all, spec) {
+				return nil, fmt.Errorf("snapshot %s not found or incomplete", spec)
+			}
+
+			labels = append(labels, spec)
+
+			continue
+		}
+
+		start, end := 0, len(all)-1
+		if first != "" {
+			start = slices.Index(all, first)
+			if start < 0 {
+				return nil, fmt.Errorf("snapshot %s not found", first)
+			}
+		}
+		if last != "" {
+			end = slices.Index(all, last)
+			if end < 0 {
+				return nil, fmt.Errorf("snapshot %s not found", last)
+			}
+		}
+		if start > end {
+			return nil, fmt.Errorf("snapshot %s was created after %s", first, last)
+		}
+
+		labels = append(labels, all[start:end+1]...)
+	}
+
+	return labels, nil
+}
+
+// RowHistory traces a row through a series of snapshots, comparing it
+// between each snapshot and the next
+func RowHistory(ctx context.Context, snapshots []SeriesSnapshot, opts HistoryOptions) (*History, error) {
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("no snapshots to trace row in")
+	}
+
+	known := false
+	for _, snapshot := range snapshots {
+		tables, err := snapshot.Source.TableNames(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tables in snapshot %s: %w", snapshot.Label, err)
+		}
+
+		if slices.Contains(tables, opts.Table) {
+			known = true
+
+			break
+		}
+	}
+	if !known {
+		return nil, fmt.Errorf("table %s is not in any of the snapshots", opts.Table)
+	}
+
+	sources := make([]Source, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		sources = append(sources, snapshot.Source)
+	}
+
+	conditions, err := parseKeyConditions(opts.PrimaryKey, keyColumns(ctx, opts.Table, nil, sources...))
+	if err != nil {
+		return nil, err
+	}
+
+	key := make([]string, 0, len(conditions))
+	history := &History{Table: opts.Table, Key: make(map[string]any, len(conditions))}
+	for _, cond := range conditions {
+		key = append(key, cond.Column)
+		history.Key[cond.Column] = cond.Value
+	}
+
+	ignoreColumnsMap := make(map[string]bool)
+	for _, col := range opts.IgnoreColumns {
+		ignoreColumnsMap[col] = true
+	}
+
+	var previous Source
+	var previousRows []map[string]any
+	for _, snapshot := range snapshots {
+		rows := previousRows
+
+		// A table with the same content hash holds the same row
+		hash := snapshot.Source.TableHash(ctx, opts.Table)
+		if previous == nil || hash == "" || hash != previous.TableHash(ctx, opts.Table) {
+			rows, err = matchingRows(ctx, snapshot, opts.Table, conditions)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		step := HistoryStep{Label: snapshot.Label, CreatedAt: snapshot.CreatedAt, Present: len(rows) > 0}
+		if step.Present {
+			step.Row = filterIgnoredColumns(rows[0], ignoreColumnsMap)
+
+			// The key values as stored rather than as written
+			history.Key = rowKeyColumns(rows[0], key)
+		}
+
+		if previous != nil {
+			c := &comparer{
+				table:         opts.Table,
+				types:         columnTypes(ctx, opts.Table, previous, snapshot.Source),
+				ignoreColumns: ignoreColumnsMap,
+				rules:         opts.CompareRules,
+				key:           key,
+			}
+
+			tableDiff, err := c.compareRows(previousRows, rows)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compare row in snapshot %s: %w", snapshot.Label, err)
+			}
+
+			switch {
+			case len(tableDiff.Inserted) > 0:
+				step.Kind = ChangeInserted
+			case len(tableDiff.Deleted) > 0:
+				step.Kind = ChangeDeleted
+			case len(tableDiff.Updated) > 0:
+				step.Kind = ChangeUpdated
+				step.Changes = tableDiff.Updated[0].Changes
+			}
+		}
+
+		history.Steps = append(history.Steps, step)
+		previous, previousRows = snapshot.Source, rows
+	}
+
+	return history, nil
+}
+
+// matchingRows returns the row of a table of a snapshot with the key, if
+// any. A key that matches several rows is an error.
+func matchingRows(ctx context.Context, snapshot SeriesSnapshot, tableName string, conditions []keyCondition) ([]map[string]any, error) {
+	tables, err := snapshot.Source.TableNames(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables in snapshot %s: %w", snapshot.Label, err)
+	}
+
+	if !slices.Contains(tables, tableName) {
+		return nil, nil
+	}
+
+	load := snapshot.Source.LoadTable
+	if numbers, ok := snapshot.Source.(NumberSource); ok {
+		load = numbers.LoadTableNumbers
+	}
+
+	rows, err := load(ctx, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load table %s from snapshot %s: %w", tableName, snapshot.Label, err)
+	}
+
+	var matching []map[string]any
+	for _, row := range rows {
+		if matchesKey(row, conditions) {
+			matching = append(matching, row)
+		}
+	}
+
+	if len(matching) > 1 {
+		return nil, fmt.Errorf("key matches %d rows of table %s in snapshot %s", len(matching), tableName, snapshot.Label)
+	}
+
+	return matching, nil
+}
+
+// matchesKey reports whether the key columns of a row have the values of the conditions
+func matchesKey(row map[string]any, conditions []keyCondition) bool {
+	for _, cond := range conditions {
+		value, ok := row[cond.Column]
+		if !ok || value == nil || keyValue(value) != cond.Value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// keyValue formats a key value the way it is written on the command line
+func keyValue(value any) string {
+	switch v := value.(type) {
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// parseKeyConditions parses the key of a row: a bare value refers to the
+// single key column of the table, otherwise col=value pairs separated by
+// commas are expected
+func parseKeyConditions(spec string, key []string) ([]keyCondition, error) {
+	if spec == "" {
+		return nil, fmt.Errorf("row key is required")
+	}
+
+	if !strings.Contains(spec, "=") {
+		if len(key) != 1 {
+			return nil, fmt.Errorf("table has no single key column, expected col=value pairs")
+		}
+
+		return []keyCondition{{Column: key[0], Value: spec}}, nil
+	}
+
+	var conditions []keyCondition
+	for _, pair := range strings.Split(spec, ",") {
+		col, value, ok := strings.Cut(pair, "=")
+		col = strings.TrimSpace(col)
+		if !ok || col == "" {
+			return nil, fmt.Errorf("invalid key %q, expected col=value", pair)
+		}
+
+		conditions = append(conditions, keyCondition{Column: col, Value: value})
+	}
+
+	return conditions, nil
+}
@@ -0,0 +1,194 @@
+package diff
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rom8726/snapdiff/internal/storage"
+)
+
+// numberSource is a memSource whose numbers are loaded as stored
+type numberSource struct {
+	memSource
+	stored map[string][]map[string]any
+}
+
+func (s numberSource) LoadTableNumbers(_ context.Context, tableName string) ([]map[string]any, error) {
+	return s.stored[tableName], nil
+}
+
+func TestSeriesLabels(t *testing.T) {
+	var manifests []*storage.Manifest
+	for _, label := range []string{"mon", "tue", "wed", "thu"} {
+		manifests = append(manifests, &storage.Manifest{Label: label, Complete: true})
+	}
+
+	tests := []struct {
+		name    string
+		specs   []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "all", want: []string{"mon", "tue", "wed", "thu"}},
+		{name: "range", specs: []string{"tue..wed"}, want: []string{"tue", "wed"}},
+		{name: "open start", specs: []string{"..tue"}, want: []string{"mon", "tue"}},
+		{name: "open end", specs: []string{"wed.."}, want: []string{"wed", "thu"}},
+		{name: "labels and ranges", specs: []string{"thu", "mon..tue"}, want: []string{"thu", "mon", "tue"}},
+		{name: "reversed range", specs: []string{"wed..tue"}, wantErr: true},
+		{name: "unknown range end", specs: []string{"mon..fri"}, wantErr: true},
+		{name: "unknown label", specs: []string{"fri"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := seriesLabels(manifests, tt.specs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error: %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("labels = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchesKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		row        map[string]any
+		conditions []keyCondition
+		want       bool
+	}{
+		{name: "text", row: row("code", "A1"), conditions: []keyCondition{{"code", "A1"}}, want: true},
+		{name: "decoded number", row: row("id", float64(42)), conditions: []keyCondition{{"id", "42"}}, want: true},
+		{name: "stored number", row: row("id", json.Number("9007199254740993")), conditions: []keyCondition{{"id", "9007199254740993"}}, want: true},
+		{name: "neighbouring stored number", row: row("id", json.Number("9007199254740992")), conditions: []keyCondition{{"id", "9007199254740993"}}},
+		{name: "composite", row: row("a", "x", "b", float64(1)), conditions: []keyCondition{{"a", "x"}, {"b", "1"}}, want: true},
+		{name: "one column differs", row: row("a", "x", "b", float64(2)), conditions: []keyCondition{{"a", "x"}, {"b", "1"}}},
+		{name: "null", row: row("id", nil), conditions: []keyCondition{{"id", "<nil>"}}},
+		{name: "missing column", row: row("id", float64(1)), conditions: []keyCondition{{"code", "1"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchesKey(tt.row, tt.conditions); got != tt.want {
+				t.Errorf("matchesKey = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseKeyConditions(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		key     []string
+		want    []keyCondition
+		wantErr bool
+	}{
+		{name: "bare value", spec: "42", key: []string{"id"}, want: []keyCondition{{"id", "42"}}},
+		{name: "pairs", spec: "tenant=a, id=42", key: []string{"tenant", "id"}, want: []keyCondition{{"tenant", "a"}, {"id", "42"}}},
+		{name: "bare value of a composite key", spec: "42", key: []string{"tenant", "id"}, wantErr: true},
+		{name: "empty", key: []string{"id"}, wantErr: true},
+		{name: "empty column", spec: "=42", key: []string{"id"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseKeyConditions(tt.spec, tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error: %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("conditions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRowHistory(t *testing.T) {
+	day := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	series := func(sources ...Source) []SeriesSnapshot {
+		snapshots := make([]SeriesSnapshot, 0, len(sources))
+		for i, source := range sources {
+			snapshots = append(snapshots, SeriesSnapshot{
+				Label:     string(rune('a' + i)),
+				CreatedAt: day.AddDate(0, 0, i),
+				Source:    source,
+			})
+		}
+
+		return snapshots
+	}
+	orders := func(rows ...map[string]any) memSource {
+		return memSource{"orders": {keys: idKeys(), rows: rows}}
+	}
+
+	t.Run("lifecycle", func(t *testing.T) {
+		snapshots := series(
+			orders(row("id", float64(2), "status", "new")),
+			orders(row("id", float64(1), "status", "new")),
+			orders(row("id", float64(1), "status", "paid")),
+			orders(row("id", float64(1), "status", "paid")),
+			orders(),
+		)
+
+		history, err := RowHistory(context.Background(), snapshots, HistoryOptions{Table: "orders", PrimaryKey: "1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var kinds []ChangeKind
+		for _, step := range history.Steps {
+			kinds = append(kinds, step.Kind)
+		}
+
+		want := []ChangeKind{"", ChangeInserted, ChangeUpdated, "", ChangeDeleted}
+		if !reflect.DeepEqual(kinds, want) {
+			t.Errorf("kinds = %v, want %v", kinds, want)
+		}
+
+		if changes := history.Steps[2].Changes; len(changes) != 1 || changes[0].Path != "status" {
+			t.Errorf("changes = %+v, want a change of status", changes)
+		}
+	})
+
+	t.Run("large integer key", func(t *testing.T) {
+		// Both ids decode to the same float64
+		stored := func(status string) numberSource {
+			return numberSource{
+				memSource: orders(),
+				stored: map[string][]map[string]any{"orders": {
+					row("id", json.Number("9007199254740992"), "status", "other"),
+					row("id", json.Number("9007199254740993"), "status", status),
+				}},
+			}
+		}
+
+		snapshots := series(stored("new"), stored("paid"))
+
+		history, err := RowHistory(context.Background(), snapshots, HistoryOptions{Table: "orders", PrimaryKey: "9007199254740993"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := history.Steps[1].Kind; got != ChangeUpdated {
+			t.Errorf("kind = %q, want %q", got, ChangeUpdated)
+		}
+
+		if got := history.Key["id"]; got != json.Number("9007199254740993") {
+			t.Errorf("key = %v, want the stored id", got)
+		}
+	})
+
+	t.Run("unknown table", func(t *testing.T) {
+		if _, err := RowHistory(context.Background(), series(orders()), HistoryOptions{Table: "payments", PrimaryKey: "1"}); err == nil {
+			t.Errorf("expected an error for a table that is in no snapshot")
+		}
+	})
+}
@@ -0,0 +1,415 @@
+package diff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/rom8726/snapdiff/internal/storage"
+)
+
+// KeySource is implemented by sources that know the key constraints of their tables
+type KeySource interface {
+	// TableKeys returns the key constraints of a table, or nil if they are unknown
+	TableKeys(ctx context.Context, tableName string) *storage.Keys
+}
+
+// keyColumns returns the columns the rows of a table are matched by: the
+// primary key or the narrowest unique key from the catalog, else the
+// declared key columns, else "id" if the catalog is unknown. It returns
+// nil if the rows are matched as a multiset.
+func keyColumns(ctx context.Context, tableName string, declared []string, sources ...Source) []string {
+	known := false
+	for _, source := range sources {
+		keySource, ok := source.(KeySource)
+		if !ok {
+			continue
+		}
+
+		keys := keySource.TableKeys(ctx, tableName)
+		if keys == nil {
+			continue
+		}
+
+		known = true
+
+		if len(keys.PrimaryKey) > 0 {
+			return keys.PrimaryKey
+		}
+
+		if len(keys.Unique) > 0 {
+			return keys.Unique[0]
+		}
+	}
+
+	if len(declared) > 0 {
+		return declared
+	}
+
+	// Snapshots taken before keys were recorded
+	if !known {
+		return []string{"id"}
+	}
+
+	return nil
+}
+
+// keyRows groups rows by their key value. Rows without a complete key
+// value are returned separately.
+func (c *comparer) keyRows(rows []map[string]any) (map[string][]map[string]any, []map[string]any) {
+	keyed := make(map[string][]map[string]any)
+	var rest []map[string]any
+
+	for _, row := range rows {
+		key, ok := c.rowKey(row)
+		if !ok {
+			rest = append(rest, row)
+
+			continue
+		}
+
+		keyed[key] = append(keyed[key], row)
+	}
+
+	return keyed, rest
+}
+
+// rowKey returns the key value of a row, or false if the table has no key
+// columns or one of them is NULL
+func (c *comparer) rowKey(row map[string]any) (string, bool) {
+	return columnsKey(row, c.key)
+}
+
+// identityKey returns the row identity value of a row, or false if the table
+// has no row identity columns or one of them is NULL
+func (c *comparer) identityKey(row map[string]any) (string, bool) {
+	return columnsKey(row, c.identity)
+}
+
+// payloadKey returns a key that is equal for rows with equal values in all
+// compared columns besides the key, or false if there are none. Columns that
+// compare rules may find equal despite different values are left out, so
+// rows with equal keys are only candidates; see samePayload.
+func (c *comparer) payloadKey(row map[string]any) (string, bool) {
+	payload := c.withoutKey(row)
+	if len(payload) == 0 {
+		return "", false
+	}
+
+	for col := range payload {
+		if !c.exactColumn(col) {
+			delete(payload, col)
+		}
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return generateRowKey(payload), true
+	}
+
+	return string(data), true
+}
+
+// exactColumn reports whether the values of a column are only equal if they
+// are identical: no compare rule applies to the column, and it holds no JSON
+// or array values rules could apply to inside
+func (c *comparer) exactColumn(col string) bool {
+	if c.comparison(col) != (comparison{array: ArrayOrdered}) {
+		return false
+	}
+
+	if len(c.rules) == 0 {
+		return true
+	}
+
+	colType := c.types[col]
+
+	return colType != "" && colType != "json" && colType != "jsonb" && !strings.HasSuffix(colType, "[]")
+}
+
+// samePayload reports whether two rows are equal in all compared columns
+// besides the key, under the compare rules
+func (c *comparer) samePayload(a, b map[string]any) bool {
+	return len(c.rowChanges(c.withoutKey(a), c.withoutKey(b))) == 0
+}
+
+// withoutKey returns a copy of the row without the key and ignored columns
+func (c *comparer) withoutKey(row map[string]any) map[string]any {
+	result := filterIgnoredColumns(row, c.ignoreColumns)
+	for _, col := range c.key {
+		delete(result, col)
+	}
+
+	return result
+}
+
+// columnsKey returns the values of the columns of a row as a key, or false
+// if there are no columns or one of them is NULL
+func columnsKey(row map[string]any, columns []string) (string, bool) {
+	if len(columns) == 0 {
+		return "", false
+	}
+
+	values := make([]any, 0, len(columns))
+	for _, col := range columns {
+		value, ok := row[col]
+		if !ok || value == nil {
+			return "", false
+		}
+
+		values = append(values, value)
+	}
+
+	if len(values) == 1 {
+		return fmt.Sprintf("%v", values[0]), true
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return fmt.Sprint(values...), true
+	}
+
+	return string(data), true
+}
+
+// primaryKey returns the key columns of a row, or the whole row if the table has none
+func (c *comparer) primaryKey(row map[string]any) map[string]any {
+	return rowKeyColumns(row, c.key)
+}
+
+// rowKeyColumns returns the key columns of a row, or the whole row if there are none
+func rowKeyColumns(row map[string]any, key []string) map[string]any {
+	if len(key) == 0 {
+		return row
+	}
+
+	result := make(map[string]any, len(key))
+	for _, col := range key {
+		result[col] = row[col]
+	}
+
+	return result
+}
+
+// matchRekeyed matches deleted and inserted rows that are the same row under
+// a new key: rows with equal row identity values, else rows with equal values
+// in all columns besides the key. It returns the rekeyed rows, and the
+// deleted and inserted rows left unmatched.
+func (c *comparer) matchRekeyed(deleted, inserted []map[string]any) ([]RekeyedRow, []map[string]any, []map[string]any) {
+	if len(c.key) == 0 || len(deleted) == 0 || len(inserted) == 0 {
+		return nil, deleted, inserted
+	}
+
+	byIdentity := indexRows(inserted, c.identityKey)
+	byPayload := indexRows(inserted, c.payloadKey)
+	used := make([]bool, len(inserted))
+
+	var rekeyed []RekeyedRow
+	var unmatched []map[string]any
+
+	for _, fromRow := range deleted {
+		i, ok := takeRow(byIdentity, c.identityKey, fromRow, used, nil)
+		if !ok {
+			i, ok = takeRow(byPayload, c.payloadKey, fromRow, used, func(i int) bool {
+				return c.samePayload(fromRow, inserted[i])
+			})
+		}
+
+		if !ok {
+			unmatched = append(unmatched, fromRow)
+
+			continue
+		}
+
+		toRow := inserted[i]
+		rekeyed = append(rekeyed, RekeyedRow{
+			OldKey:  c.primaryKey(fromRow),
+			NewKey:  c.primaryKey(toRow),
+			Before:  filterIgnoredColumns(fromRow, c.ignoreColumns),
+			After:   filterIgnoredColumns(toRow, c.ignoreColumns),
+			Changes: c.rowChanges(c.withoutKey(fromRow), c.withoutKey(toRow)),
+		})
+	}
+
+	var rest []map[string]any
+	for i, toRow := range inserted {
+		if !used[i] {
+			rest = append(rest, toRow)
+		}
+	}
+
+	return rekeyed, unmatched, rest
+}
+
+// indexRows returns the positions of rows by a key, in order
+func indexRows(rows []map[string]any, key func(map[string]any) (string, bool)) map[string][]int {
+	index := make(map[string][]int)
+	for i, row := range rows {
+		if k, ok := key(row); ok {
+			index[k] = append(index[k], i)
+		}
+	}
+
+	return index
+}
+
+// takeRow returns the position of the first unused indexed row with the
+// same key as a row that match accepts, if given, and marks it used
+func takeRow(index map[string][]int, key func(map[string]any) (string, bool), row map[string]any, used []bool, match func(i int) bool) (int, bool) {
+	k, ok := key(row)
+	if !ok {
+		return 0, false
+	}
+
+	for _, i := range index[k] {
+		if !used[i] && (match == nil || match(i)) {
+			used[i] = true
+
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// matchMultiset matches equal rows, counting duplicates, and returns the
+// rows that are only in from (deleted) and only in to (inserted)
+func (c *comparer) matchMultiset(fromRows, toRows []map[string]any) ([]map[string]any, []map[string]any) {
+	remaining := make(map[string]int)
+	for _, row := range toRows {
+		remaining[c.contentKey(row)]++
+	}
+
+	var deleted []map[string]any
+	for _, row := range fromRows {
+		key := c.contentKey(row)
+		if remaining[key] > 0 {
+			remaining[key]--
+		} else {
+			deleted = append(deleted, row)
+		}
+	}
+
+	var inserted []map[string]any
+	for _, row := range toRows {
+		key := c.contentKey(row)
+		if remaining[key] > 0 {
+			remaining[key]--
+			inserted = append(inserted, row)
+		}
+	}
+
+	sortRows(deleted)
+	sortRows(inserted)
+
+	return deleted, inserted
+}
+
+// contentKey returns a key that is equal for rows with equal values in all
+// compared columns
+func (c *comparer) contentKey(row map[string]any) string {
+	data, err := json.Marshal(filterIgnoredColumns(row, c.ignoreColumns))
+	if err != nil {
+		return generateRowKey(row)
+	}
+
+	return string(data)
+}
+
+// sortRows orders rows without key columns by all their values
+func sortRows(rows []map[string]any) {
+	keys := make([]string, len(rows))
+	for i, row := range rows {
+		keys[i] = generateRowKey(row)
+	}
+
+	sort.Stable(rowSorter{rows: rows, keys: keys})
+}
+
+// rowSorter sorts rows by precomputed keys
+type rowSorter struct {
+	rows []map[string]any
+	keys []string
+}
+
+func (s rowSorter) Len() int           { return len(s.rows) }
+func (s rowSorter) Less(i, j int) bool { return lessRowKey(s.keys[i], s.keys[j]) }
+func (s rowSorter) Swap(i, j int) {
+	s.rows[i], s.rows[j] = s.rows[j], s.rows[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
+
+// pairSimilar pairs each deleted row with the most similar inserted row that
+// has at least the similarity threshold of equal columns. It returns the
+// pairs, and the deleted and inserted rows left unpaired.
+func (c *comparer) pairSimilar(deleted, inserted []map[string]any) ([][2]map[string]any, []map[string]any, []map[string]any) {
+	var pairs [][2]map[string]any
+	var unpaired []map[string]any
+	used := make([]bool, len(inserted))
+
+	for _, fromRow := range deleted {
+		best, bestScore := -1, c.similarity
+		for i, toRow := range inserted {
+			if used[i] {
+				continue
+			}
+
+			if score := c.rowSimilarity(fromRow, toRow); score >= bestScore && (best < 0 || score > bestScore) {
+				best, bestScore = i, score
+			}
+		}
+
+		if best < 0 {
+			unpaired = append(unpaired, fromRow)
+
+			continue
+		}
+
+		used[best] = true
+		pairs = append(pairs, [2]map[string]any{fromRow, inserted[best]})
+	}
+
+	var rest []map[string]any
+	for i, toRow := range inserted {
+		if !used[i] {
+			rest = append(rest, toRow)
+		}
+	}
+
+	return pairs, unpaired, rest
+}
+
+// rowSimilarity returns the fraction of compared columns that are equal in two rows
+func (c *comparer) rowSimilarity(a, b map[string]any) float64 {
+	columns := make(map[string]bool)
+	for col := range a {
+		columns[col] = true
+	}
+	for col := range b {
+		columns[col] = true
+	}
+
+	total, equal := 0, 0
+	for col := range columns {
+		if c.ignoreColumns[col] {
+			continue
+		}
+
+		total++
+
+		valueA, okA := a[col]
+		valueB, okB := b[col]
+		if okA && okB && (reflect.DeepEqual(valueA, valueB) || c.comparison(col).equal(valueA, valueB)) {
+			equal++
+		}
+	}
+
+	if total == 0 {
+		return 0
+	}
+
+	return float64(equal) / float64(total)
+}
@@ -0,0 +1,86 @@
+package diff
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCompareRekeyed(t *testing.T) {
+	two := 2
+
+	tests := []struct {
+		name  string
+		types map[string]string
+		from  []map[string]any
+		to    []map[string]any
+		opts  Options
+		want  [4]int
+	}{
+		{
+			name: "same payload",
+			from: []map[string]any{row("id", 1, "email", "a@example.com")},
+			to:   []map[string]any{row("id", 2, "email", "a@example.com")},
+			want: [4]int{0, 0, 0, 1},
+		},
+		{
+			name: "different payload",
+			from: []map[string]any{row("id", 1, "email", "a@example.com")},
+			to:   []map[string]any{row("id", 2, "email", "b@example.com")},
+			want: [4]int{1, 0, 1, 0},
+		},
+		{
+			name: "payload equal besides an ignored column",
+			from: []map[string]any{row("id", 1, "email", "a@example.com", "synced_at", "monday")},
+			to:   []map[string]any{row("id", 2, "email", "a@example.com", "synced_at", "tuesday")},
+			opts: Options{IgnoreColumns: []string{"synced_at"}},
+			want: [4]int{0, 0, 0, 1},
+		},
+		{
+			name:  "payload equal within a tolerance",
+			types: map[string]string{"id": "integer", "amount": "numeric"},
+			from:  []map[string]any{row("id", 1, "amount", 10.001)},
+			to:    []map[string]any{row("id", 2, "amount", 10.0)},
+			opts:  Options{CompareRules: []CompareRule{{Column: "amount", Tolerance: 0.01}}},
+			want:  [4]int{0, 0, 0, 1},
+		},
+		{
+			name:  "payload equal after rounding, other column differs",
+			types: map[string]string{"id": "integer", "amount": "numeric", "status": "text"},
+			from:  []map[string]any{row("id", 1, "amount", "10.001", "status", "new")},
+			to:    []map[string]any{row("id", 2, "amount", "10.004", "status", "paid")},
+			opts:  Options{CompareRules: []CompareRule{{Column: "amount", Scale: &two}}},
+			want:  [4]int{1, 0, 1, 0},
+		},
+		{
+			name:  "JSON payload equal under a rule inside it",
+			types: map[string]string{"id": "integer", "profile": "jsonb"},
+			from:  []map[string]any{row("id", 1, "profile", map[string]any{"name": "Ann"})},
+			to:    []map[string]any{row("id", 2, "profile", map[string]any{"name": "ann"})},
+			opts:  Options{CompareRules: []CompareRule{{Column: "profile.name", IgnoreCase: true}}},
+			want:  [4]int{0, 0, 0, 1},
+		},
+		{
+			name: "row identity",
+			from: []map[string]any{row("id", 1, "uuid", "u1", "email", "a@example.com")},
+			to:   []map[string]any{row("id", 2, "uuid", "u1", "email", "b@example.com")},
+			opts: Options{RowIdentity: map[string][]string{"users": {"uuid"}}},
+			want: [4]int{0, 0, 0, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from := memSource{"users": {keys: idKeys(), types: tt.types, rows: tt.from}}
+			to := memSource{"users": {keys: idKeys(), types: tt.types, rows: tt.to}}
+
+			result, err := Compare(context.Background(), from, to, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := counts(result.Tables["users"]); got != tt.want {
+				t.Errorf("counts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,14 +2,34 @@ package diff
 
 // Options contains configuration for the diff command
 type Options struct {
-	From          string   // Source snapshot label
-	To            string   // Target snapshot label
-	Tables        []string // Specific tables to include
-	IgnoreColumns []string // Columns to ignore in comparison
-	OnlyChanged   bool     // Show only changed tables
-	Format        string   // Output format (cli, yaml, markdown)
-	OutputFile    string   // Output file path (stdout if empty)
-	SortKeys      bool     // Sort keys in output
-	Limit         int      // Limit the number of rows in output
-	BaseDir       string   // Base directory for snapshots
+	From             string              // Source snapshot label
+	To               string              // Target snapshot label
+	Tables           []string            // Specific tables to include
+	IgnoreColumns    []string            // Columns to ignore in comparison
+	CompareRules     []CompareRule       // Per-column comparison rules
+	CompareRulesFile string              // YAML file of comparison rules, applied after CompareRules
+	TableMap         map[string]string   // New names of tables of the 'from' snapshot renamed in the 'to' snapshot
+	Keys             map[string][]string // Columns to match the rows of tables without a key constraint by
+	RowIdentity      map[string][]string // Columns that identify the rows of a table across key changes, e.g. uuid
+	MatchSimilar     float64             // Pair deleted and inserted rows of keyless tables with at least this fraction of equal columns as updates; 0 disables
+	CheckForeignKeys bool                // Look for rows of the 'to' snapshot that reference a missing row
+	OnlyChanged      bool                // Show only changed tables
+	Format           string              // Output format (cli, yaml, markdown)
+	OutputFile       string              // Output file path (stdout if empty)
+	SortKeys         bool                // Sort keys in output
+	Limit            int                 // Limit the number of rows in output
+	BaseDir          string              // Base directory for snapshots
+	Identity         string              // age identity file for encrypted snapshots (default: $SNAPDIFF_IDENTITY)
+}
+
+// HistoryOptions contains configuration for the history command
+type HistoryOptions struct {
+	Table            string        // Table of the row
+	PrimaryKey       string        // Key of the row: a value of the key column, or col=value pairs separated by commas
+	Labels           []string      // Snapshot labels, or first..last ranges in chronological order; all snapshots if empty
+	IgnoreColumns    []string      // Columns to ignore in comparison
+	CompareRules     []CompareRule // Per-column comparison rules
+	CompareRulesFile string        // YAML file of comparison rules, applied after CompareRules
+	BaseDir          string        // Base directory for snapshots
+	Identity         string        // age identity file for encrypted snapshots (default: $SNAPDIFF_IDENTITY)
 }
@@ -0,0 +1,366 @@
+package diff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/rom8726/snapdiff/internal/storage"
+)
+
+// renameSimilarity is the share of rows a table that was renamed and changed
+// must still have in common with its old table to be detected as renamed
+const renameSimilarity = 0.5
+
+// renameCandidate is a table in only one of the snapshots
+type renameCandidate struct {
+	name      string
+	structure string            // Columns with their types, empty if unknown
+	rows      map[string]int    // Counts of the rows by their fingerprint, nil until loaded
+	columns   map[string]string // Columns of the rows, nil until loaded
+	total     int               // Number of rows
+}
+
+// detectRenames returns the old names of the tables of the 'to' snapshot
+// that are renamed tables of the 'from' snapshot, by their new names. Tables
+// are renamed as mapped, or detected as renamed if they are in only one of
+// the snapshots and have the same columns, ignored columns aside, as a
+// single table in only the other one, and most of its rows
+func detectRenames(ctx context.Context, from, to Source, fromTables, toTables []string, tableMap map[string]string, ignoreColumns map[string]bool) (map[string]string, error) {
+	fromTableMap := make(map[string]bool, len(fromTables))
+	for _, t := range fromTables {
+		fromTableMap[t] = true
+	}
+
+	toTableMap := make(map[string]bool, len(toTables))
+	for _, t := range toTables {
+		toTableMap[t] = true
+	}
+
+	renames := make(map[string]string)
+	mapped := make(map[string]bool)
+	for oldName, newName := range tableMap {
+		if !fromTableMap[oldName] {
+			return nil, fmt.Errorf("mapped table %s is not in 'from' snapshot", oldName)
+		}
+		if !toTableMap[newName] {
+			return nil, fmt.Errorf("mapped table %s is not in 'to' snapshot", newName)
+		}
+		if fromTableMap[newName] {
+			return nil, fmt.Errorf("mapped table %s is also in 'from' snapshot", newName)
+		}
+		if _, ok := renames[newName]; ok {
+			return nil, fmt.Errorf("table %s is mapped more than once", newName)
+		}
+
+		renames[newName] = oldName
+		mapped[oldName] = true
+		mapped[newName] = true
+	}
+
+	var fromOnly, toOnly []string
+	for _, t := range fromTables {
+		if !toTableMap[t] && !mapped[t] {
+			fromOnly = append(fromOnly, t)
+		}
+	}
+	for _, t := range toTables {
+		if !fromTableMap[t] && !mapped[t] {
+			toOnly = append(toOnly, t)
+		}
+	}
+
+	if len(fromOnly) == 0 || len(toOnly) == 0 {
+		return renames, nil
+	}
+
+	// Tables with the same content are found without reading them
+	for newName, oldName := range renamesByHash(ctx, from, to, fromOnly, toOnly) {
+		renames[newName] = oldName
+		fromOnly = slices.DeleteFunc(fromOnly, func(t string) bool { return t == oldName })
+		toOnly = slices.DeleteFunc(toOnly, func(t string) bool { return t == newName })
+	}
+
+	if len(fromOnly) == 0 || len(toOnly) == 0 {
+		return renames, nil
+	}
+
+	fromCandidates, err := renameCandidates(ctx, from, fromOnly, ignoreColumns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tables of 'from' snapshot: %w", err)
+	}
+
+	toCandidates, err := renameCandidates(ctx, to, toOnly, ignoreColumns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tables of 'to' snapshot: %w", err)
+	}
+
+	// Only tables with the same structure as a table on the other side are read
+	fromStructures := make(map[string]bool)
+	for _, c := range fromCandidates {
+		fromStructures[c.structure] = true
+	}
+
+	toStructures := make(map[string]bool)
+	for _, c := range toCandidates {
+		toStructures[c.structure] = true
+	}
+
+	for _, c := range fromCandidates {
+		if toStructures[c.structure] {
+			if err := c.loadRows(ctx, from, ignoreColumns); err != nil {
+				return nil, fmt.Errorf("failed to read tables of 'from' snapshot: %w", err)
+			}
+		}
+	}
+
+	for _, c := range toCandidates {
+		if fromStructures[c.structure] {
+			if err := c.loadRows(ctx, to, ignoreColumns); err != nil {
+				return nil, fmt.Errorf("failed to read tables of 'to' snapshot: %w", err)
+			}
+		}
+	}
+
+	// A pair is renamed if each table is the other's single most similar one
+	bestFrom := make(map[*renameCandidate]*renameCandidate)
+	bestTo := make(map[*renameCandidate]*renameCandidate)
+	bestFromScore := make(map[*renameCandidate]float64)
+	bestToScore := make(map[*renameCandidate]float64)
+	for _, f := range fromCandidates {
+		for _, t := range toCandidates {
+			if f.structure != t.structure {
+				continue
+			}
+
+			score := f.similarity(t)
+			if score < renameSimilarity {
+				continue
+			}
+
+			updateBest(bestFrom, bestFromScore, t, f, score)
+			updateBest(bestTo, bestToScore, f, t, score)
+		}
+	}
+
+	for _, t := range toCandidates {
+		f := bestFrom[t]
+		if f != nil && bestTo[f] == t {
+			renames[t.name] = f.name
+		}
+	}
+
+	return renames, nil
+}
+
+// renamesByHash returns the tables that are in only one of the snapshots and
+// have the same content hash as a single table in only the other one
+func renamesByHash(ctx context.Context, from, to Source, fromOnly, toOnly []string) map[string]string {
+	fromHashes := make(map[string][]string)
+	for _, t := range fromOnly {
+		if hash := from.TableHash(ctx, t); hash != "" {
+			fromHashes[hash] = append(fromHashes[hash], t)
+		}
+	}
+
+	toHashes := make(map[string][]string)
+	for _, t := range toOnly {
+		if hash := to.TableHash(ctx, t); hash != "" {
+			toHashes[hash] = append(toHashes[hash], t)
+		}
+	}
+
+	renames := make(map[string]string)
+	for hash, oldNames := range fromHashes {
+		newNames := toHashes[hash]
+		if len(oldNames) == 1 && len(newNames) == 1 {
+			renames[newNames[0]] = oldNames[0]
+		}
+	}
+
+	return renames
+}
+
+// renameCandidates returns the tables with their structure. The rows of
+// tables with unknown column types are read to learn their columns, and
+// tables with no known columns are left out.
+func renameCandidates(ctx context.Context, source Source, tables []string, ignoreColumns map[string]bool) ([]*renameCandidate, error) {
+	candidates := make([]*renameCandidate, 0, len(tables))
+	for _, tableName := range tables {
+		c := &renameCandidate{name: tableName}
+
+		columns := columnTypes(ctx, tableName, source)
+		if len(columns) == 0 {
+			if err := c.loadRows(ctx, source, ignoreColumns); err != nil {
+				return nil, err
+			}
+		}
+
+		c.structure = tableStructure(columns, c.columns, ignoreColumns)
+		if c.structure == "" {
+			continue
+		}
+
+		candidates = append(candidates, c)
+	}
+
+	return candidates, nil
+}
+
+// loadRows reads the rows of the table, unless they were read already
+func (c *renameCandidate) loadRows(ctx context.Context, source Source, ignoreColumns map[string]bool) error {
+	if c.rows != nil {
+		return nil
+	}
+
+	rows, err := source.LoadTable(ctx, c.name)
+	if err != nil {
+		return fmt.Errorf("failed to load table %s: %w", c.name, err)
+	}
+
+	c.rows = make(map[string]int, len(rows))
+	c.total = len(rows)
+	for _, row := range rows {
+		c.rows[rowFingerprint(row, ignoreColumns)]++
+	}
+
+	c.columns = make(map[string]string)
+	for _, row := range rows {
+		for col := range row {
+			c.columns[col] = ""
+		}
+	}
+
+	return nil
+}
+
+// similarity returns the share of rows two tables have in common, relative to the larger one
+func (c *renameCandidate) similarity(other *renameCandidate) float64 {
+	if c.total == 0 && other.total == 0 {
+		return 1
+	}
+
+	shared := 0
+	for fingerprint, count := range c.rows {
+		shared += min(count, other.rows[fingerprint])
+	}
+
+	return float64(shared) / float64(max(c.total, other.total))
+}
+
+// updateBest records candidate as the best match of table if its score is
+// higher than the best so far. A tie leaves the table without a match.
+func updateBest(best map[*renameCandidate]*renameCandidate, scores map[*renameCandidate]float64, table, candidate *renameCandidate, score float64) {
+	bestScore, ok := scores[table]
+	switch {
+	case !ok || score > bestScore:
+		best[table] = candidate
+		scores[table] = score
+	case score == bestScore:
+		best[table] = nil
+	}
+}
+
+// tableStructure describes the columns of a table with their types, if
+// known, else the columns of its rows, without the ignored columns
+func tableStructure(types, rowColumns map[string]string, ignoreColumns map[string]bool) string {
+	columns := types
+	if len(columns) == 0 {
+		columns = rowColumns
+	}
+
+	parts := make([]string, 0, len(columns))
+	for col, colType := range columns {
+		if !ignoreColumns[col] {
+			parts = append(parts, col+" "+colType)
+		}
+	}
+	sort.Strings(parts)
+
+	return strings.Join(parts, ",")
+}
+
+// rowFingerprint returns the row without the ignored columns as a string
+func rowFingerprint(row map[string]any, ignoreColumns map[string]bool) string {
+	row = filterIgnoredColumns(row, ignoreColumns)
+
+	data, err := json.Marshal(row)
+	if err != nil {
+		return generateRowKey(row)
+	}
+
+	return string(data)
+}
+
+// renamedSource presents the tables of a snapshot under their new names
+type renamedSource struct {
+	Source
+	oldNames map[string]string // Old table names by new name
+}
+
+// newRenamedSource returns a source with the renamed tables under their new names
+func newRenamedSource(source Source, oldNames map[string]string) *renamedSource {
+	return &renamedSource{Source: source, oldNames: oldNames}
+}
+
+// name returns the name of a table in the underlying source
+func (s *renamedSource) name(tableName string) string {
+	if oldName, ok := s.oldNames[tableName]; ok {
+		return oldName
+	}
+
+	return tableName
+}
+
+// TableNames returns the names of all tables, renamed tables under their new names
+func (s *renamedSource) TableNames(ctx context.Context) ([]string, error) {
+	tables, err := s.Source.TableNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	newNames := make(map[string]string, len(s.oldNames))
+	for newName, oldName := range s.oldNames {
+		newNames[oldName] = newName
+	}
+
+	tables = slices.Clone(tables)
+	for i, t := range tables {
+		if newName, ok := newNames[t]; ok {
+			tables[i] = newName
+		}
+	}
+
+	return tables, nil
+}
+
+// LoadTable returns the rows of a table
+func (s *renamedSource) LoadTable(ctx context.Context, tableName string) ([]map[string]any, error) {
+	return s.Source.LoadTable(ctx, s.name(tableName))
+}
+
+// TableHash returns the content hash of a table
+func (s *renamedSource) TableHash(ctx context.Context, tableName string) string {
+	return s.Source.TableHash(ctx, s.name(tableName))
+}
+
+// ColumnTypes returns the column types of a table, if the underlying source knows them
+func (s *renamedSource) ColumnTypes(ctx context.Context, tableName string) map[string]string {
+	if typer, ok := s.Source.(ColumnTyper); ok {
+		return typer.ColumnTypes(ctx, s.name(tableName))
+	}
+
+	return nil
+}
+
+// TableKeys returns the key constraints of a table, if the underlying source knows them
+func (s *renamedSource) TableKeys(ctx context.Context, tableName string) *storage.Keys {
+	if keySource, ok := s.Source.(KeySource); ok {
+		return keySource.TableKeys(ctx, s.name(tableName))
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package diff
+
+import (
+	"context"
+	"maps"
+	"testing"
+)
+
+func TestDetectRenames(t *testing.T) {
+	customers := []map[string]any{
+		row("id", 1, "name", "Ann"),
+		row("id", 2, "name", "Bob"),
+		row("id", 3, "name", "Eve"),
+	}
+	types := map[string]string{"id": "integer", "name": "text"}
+
+	tests := []struct {
+		name     string
+		from     memSource
+		to       memSource
+		tableMap map[string]string
+		ignore   map[string]bool
+		want     map[string]string
+	}{
+		{
+			name: "same rows",
+			from: memSource{"customers": {types: types, rows: customers}},
+			to:   memSource{"clients": {types: types, rows: customers}},
+			want: map[string]string{"clients": "customers"},
+		},
+		{
+			name: "same content hash, not read",
+			from: memSource{"customers": {hash: "h1"}},
+			to:   memSource{"clients": {hash: "h1"}},
+			want: map[string]string{"clients": "customers"},
+		},
+		{
+			name: "renamed and changed",
+			from: memSource{"customers": {types: types, rows: customers}},
+			to: memSource{"clients": {types: types, rows: []map[string]any{
+				row("id", 1, "name", "Ann"),
+				row("id", 2, "name", "Bob"),
+				row("id", 3, "name", "Eva"),
+				row("id", 4, "name", "Joe"),
+			}}},
+			want: map[string]string{"clients": "customers"},
+		},
+		{
+			name: "mostly different rows",
+			from: memSource{"customers": {types: types, rows: customers}},
+			to:   memSource{"clients": {types: types, rows: []map[string]any{row("id", 1, "name", "Ann")}}},
+			want: map[string]string{},
+		},
+		{
+			name: "different columns",
+			from: memSource{"customers": {types: types, rows: customers}},
+			to:   memSource{"clients": {types: map[string]string{"id": "bigint", "name": "text"}, rows: customers}},
+			want: map[string]string{},
+		},
+		{
+			name:   "equal besides an ignored column",
+			from:   memSource{"customers": {rows: []map[string]any{row("id", 1, "synced_at", "monday")}}},
+			to:     memSource{"clients": {rows: []map[string]any{row("id", 1, "synced_at", "tuesday")}}},
+			ignore: map[string]bool{"synced_at": true},
+			want:   map[string]string{"clients": "customers"},
+		},
+		{
+			name: "ambiguous",
+			from: memSource{"customers": {types: types, rows: customers}},
+			to: memSource{
+				"clients": {types: types, rows: customers},
+				"buyers":  {types: types, rows: customers},
+			},
+			want: map[string]string{},
+		},
+		{
+			name: "most similar of several",
+			from: memSource{"customers": {types: types, rows: customers}},
+			to: memSource{
+				"clients": {types: types, rows: customers},
+				"buyers":  {types: types, rows: customers[:2]},
+			},
+			want: map[string]string{"clients": "customers"},
+		},
+		{
+			name:     "mapped",
+			from:     memSource{"customers": {types: types, rows: customers}},
+			to:       memSource{"clients": {types: map[string]string{"id": "integer"}}},
+			tableMap: map[string]string{"customers": "clients"},
+			want:     map[string]string{"clients": "customers"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fromTables, _ := tt.from.TableNames(ctx)
+			toTables, _ := tt.to.TableNames(ctx)
+
+			renames, err := detectRenames(ctx, tt.from, tt.to, fromTables, toTables, tt.tableMap, tt.ignore)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !maps.Equal(renames, tt.want) {
+				t.Errorf("renames = %v, want %v", renames, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectRenamesInvalidMap(t *testing.T) {
+	ctx := context.Background()
+	from := memSource{"customers": {}}
+	to := memSource{"clients": {}}
+
+	for _, tableMap := range []map[string]string{
+		{"users": "clients"},
+		{"customers": "users"},
+	} {
+		if _, err := detectRenames(ctx, from, to, []string{"customers"}, []string{"clients"}, tableMap, nil); err == nil {
+			t.Errorf("map %v: expected an error", tableMap)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package diff
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ArrayMode represents how array values are compared
+type ArrayMode string
+
+const (
+	ArrayOrdered      ArrayMode = "ordered"       // Elements are compared position by position
+	ArrayUnorderedSet ArrayMode = "unordered-set" // Order and duplicates are ignored
+	ArrayMultiset     ArrayMode = "multiset"      // Order is ignored, duplicates are counted
+)
+
+// CompareRule sets how the values of the columns matching a table, column
+// and type pattern are compared. Patterns use path.Match syntax; the column
+// pattern may also match a path inside a JSON or array column, without
+// array indices, e.g. "payload.items.qty". Empty patterns match everything.
+type CompareRule struct {
+	Table  string `yaml:"table"`
+	Column string `yaml:"column"`
+	Type   string `yaml:"type"` // Pattern of the column type, e.g. "timestamp*"
+
+	Array             ArrayMode     `yaml:"array"`              // Comparison of array values, ordered by default
+	Tolerance         float64       `yaml:"tolerance"`          // Absolute difference up to which numbers are equal
+	RelativeTolerance float64       `yaml:"relative_tolerance"` // Difference relative to the larger number up to which numbers are equal
+	Scale             *int          `yaml:"scale"`              // Decimal places numbers are rounded to before they are compared
+	TimeTolerance     time.Duration `yaml:"time_tolerance"`     // Difference up to which timestamps are equal
+	NormalizeTimezone bool          `yaml:"normalize_timezone"` // Timestamps are compared as instants, whatever their offset
+	IgnoreCase        bool          `yaml:"ignore_case"`        // Text is compared case-insensitively
+	IgnoreWhitespace  bool          `yaml:"ignore_whitespace"`  // Leading and trailing whitespace is ignored, and runs of whitespace are equal
+	NullEqualsEmpty   bool          `yaml:"null_equals_empty"`  // NULL equals the empty string
+}
+
+// CompareRuleSet is the top-level structure of a compare rules file
+type CompareRuleSet struct {
+	Rules       []CompareRule       `yaml:"rules"`
+	Keys        map[string][]string `yaml:"keys"`         // Columns to match the rows of tables without a key constraint by
+	RowIdentity map[string][]string `yaml:"row_identity"` // Columns that identify the rows of a table across key changes
+}
+
+// LoadCompareRules reads and validates a compare rules file
+func LoadCompareRules(path string) (*CompareRuleSet, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compare rules file: %w", err)
+	}
+
+	var ruleSet CompareRuleSet
+	if err := yaml.Unmarshal(content, &ruleSet); err != nil {
+		return nil, fmt.Errorf("failed to parse compare rules file: %w", err)
+	}
+
+	for i, rule := range ruleSet.Rules {
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("compare rule #%d: %w", i+1, err)
+		}
+	}
+
+	for table, columns := range ruleSet.Keys {
+		if len(columns) == 0 {
+			return nil, fmt.Errorf("key of table %s has no columns", table)
+		}
+	}
+
+	for table, columns := range ruleSet.RowIdentity {
+		if len(columns) == 0 {
+			return nil, fmt.Errorf("row identity of table %s has no columns", table)
+		}
+	}
+
+	return &ruleSet, nil
+}
+
+// mergeKeys returns the declared columns by table, adding those from a rules
+// file for tables that have none
+func mergeKeys(keys, fromFile map[string][]string) map[string][]string {
+	merged := make(map[string][]string, len(keys)+len(fromFile))
+	for table, columns := range fromFile {
+		merged[table] = columns
+	}
+	for table, columns := range keys {
+		merged[table] = columns
+	}
+
+	return merged
+}
+
+// Matches reports whether the rule applies to a column, or a path inside a
+// JSON or array column, of a table
+func (r CompareRule) Matches(table, column, colType string) bool {
+	for _, p := range [][2]string{{r.Table, table}, {r.Column, column}, {r.Type, colType}} {
+		if p[0] == "" {
+			continue
+		}
+
+		if ok, _ := path.Match(p[0], p[1]); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validate checks the patterns and options of the rule
+func (r CompareRule) validate() error {
+	if r.Column == "" && r.Type == "" {
+		return fmt.Errorf("column or type is required")
+	}
+
+	for _, pattern := range []string{r.Table, r.Column, r.Type} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	switch r.Array {
+	case "", ArrayOrdered, ArrayUnorderedSet, ArrayMultiset:
+	default:
+		return fmt.Errorf("unknown array mode: %s", r.Array)
+	}
+
+	if r.Tolerance < 0 || r.RelativeTolerance < 0 || r.TimeTolerance < 0 {
+		return fmt.Errorf("tolerances must not be negative")
+	}
+
+	if r.Scale != nil && *r.Scale < 0 {
+		return fmt.Errorf("scale must not be negative")
+	}
+
+	return nil
+}
+
+// comparison holds the options a value is compared with. Each option is
+// taken from the first matching rule that sets it.
+type comparison struct {
+	array             ArrayMode
+	tolerance         float64
+	relativeTolerance float64
+	scale             *int
+	timeTolerance     time.Duration
+	normalizeTimezone bool
+	ignoreCase        bool
+	ignoreWhitespace  bool
+	nullEqualsEmpty   bool
+}
+
+// resolveComparison returns the options the values at a path are compared with
+func resolveComparison(rules []CompareRule, table, path, colType string) comparison {
+	cmp := comparison{array: ArrayOrdered}
+	arraySet := false
+
+	for _, rule := range rules {
+		if !rule.Matches(table, path, colType) {
+			continue
+		}
+
+		if rule.Array != "" && !arraySet {
+			cmp.array = rule.Array
+			arraySet = true
+		}
+		if cmp.tolerance == 0 {
+			cmp.tolerance = rule.Tolerance
+		}
+		if cmp.relativeTolerance == 0 {
+			cmp.relativeTolerance = rule.RelativeTolerance
+		}
+		if cmp.scale == nil {
+			cmp.scale = rule.Scale
+		}
+		if cmp.timeTolerance == 0 {
+			cmp.timeTolerance = rule.TimeTolerance
+		}
+		cmp.normalizeTimezone = cmp.normalizeTimezone || rule.NormalizeTimezone
+		cmp.ignoreCase = cmp.ignoreCase || rule.IgnoreCase
+		cmp.ignoreWhitespace = cmp.ignoreWhitespace || rule.IgnoreWhitespace
+		cmp.nullEqualsEmpty = cmp.nullEqualsEmpty || rule.NullEqualsEmpty
+	}
+
+	return cmp
+}
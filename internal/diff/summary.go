@@ -0,0 +1,222 @@
+package diff
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// Summary aggregates the changes of a diff by table and column
+type Summary struct {
+	Tables []TableSummary `json:"tables" yaml:"tables"`
+}
+
+// TableSummary counts the changed rows of a table and aggregates its updates by column
+type TableSummary struct {
+	TableName   string          `json:"table" yaml:"table"`
+	RenamedFrom string          `json:"renamed_from,omitempty" yaml:"renamed_from,omitempty"`
+	Inserted    int             `json:"inserted" yaml:"inserted"`
+	Updated     int             `json:"updated" yaml:"updated"`
+	Deleted     int             `json:"deleted" yaml:"deleted"`
+	Rekeyed     int             `json:"rekeyed" yaml:"rekeyed"`
+	Dangling    int             `json:"dangling" yaml:"dangling"`                   // Rows that reference a missing row
+	Columns     []ColumnSummary `json:"columns,omitempty" yaml:"columns,omitempty"` // Changed columns, most changed first
+}
+
+// ColumnSummary aggregates the changes of a column
+type ColumnSummary struct {
+	Column      string       `json:"column" yaml:"column"`
+	Rows        int          `json:"rows" yaml:"rows"`                                 // Rows in which the column changed
+	Transitions []Transition `json:"transitions" yaml:"transitions"`                   // Most frequent old → new value pairs, most frequent first
+	Distinct    int          `json:"distinct_transitions" yaml:"distinct_transitions"` // Number of distinct old → new value pairs
+	NullToValue int          `json:"null_to_value" yaml:"null_to_value"`               // Changes from NULL to a value
+	ValueToNull int          `json:"value_to_null" yaml:"value_to_null"`               // Changes from a value to NULL
+	Delta       *Delta       `json:"delta,omitempty" yaml:"delta,omitempty"`           // Change of numeric values, nil if the values are not numeric
+}
+
+// Transition counts the changes of a column from one value to another
+type Transition struct {
+	Before any `json:"before" yaml:"before"`
+	After  any `json:"after" yaml:"after"`
+	Count  int `json:"count" yaml:"count"`
+}
+
+// Delta describes the changes of the numeric values of a column, new minus old
+type Delta struct {
+	Min float64 `json:"min" yaml:"min"`
+	Max float64 `json:"max" yaml:"max"`
+	Avg float64 `json:"avg" yaml:"avg"`
+}
+
+// Summarize aggregates the changes of the result by table and column,
+// keeping the top most frequent value transitions of each column
+func (r *Result) Summarize(top int) *Summary {
+	names := make([]string, 0, len(r.Tables))
+	for name := range r.Tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	summary := &Summary{Tables: make([]TableSummary, 0, len(names))}
+	for _, name := range names {
+		summary.Tables = append(summary.Tables, r.Tables[name].summarize(top))
+	}
+
+	return summary
+}
+
+// summarize aggregates the changes of the table by column
+func (d *TableDiff) summarize(top int) TableSummary {
+	columns := make(map[string]*columnStats)
+	add := func(changes []Change, before, after map[string]any) {
+		seen := make(map[string]bool)
+		for _, change := range changes {
+			col := changeColumn(change.Path)
+			if seen[col] {
+				continue
+			}
+			seen[col] = true
+
+			stats, ok := columns[col]
+			if !ok {
+				stats = &columnStats{counts: make(map[string]*Transition)}
+				columns[col] = stats
+			}
+			stats.add(before[col], after[col])
+		}
+	}
+
+	for _, row := range d.Updated {
+		add(row.Changes, row.Before, row.After)
+	}
+	for _, row := range d.Rekeyed {
+		add(row.Changes, row.Before, row.After)
+	}
+
+	summary := TableSummary{
+		TableName:   d.TableName,
+		RenamedFrom: d.RenamedFrom,
+		Inserted:    len(d.Inserted),
+		Updated:     len(d.Updated),
+		Deleted:     len(d.Deleted),
+		Rekeyed:     len(d.Rekeyed),
+		Dangling:    len(d.Dangling),
+	}
+
+	for col, stats := range columns {
+		summary.Columns = append(summary.Columns, stats.summary(col, top))
+	}
+
+	sort.Slice(summary.Columns, func(i, j int) bool {
+		a, b := summary.Columns[i], summary.Columns[j]
+		if a.Rows != b.Rows {
+			return a.Rows > b.Rows
+		}
+
+		return a.Column < b.Column
+	})
+
+	return summary
+}
+
+// changeColumn returns the column of a change path
+func changeColumn(path string) string {
+	if i := strings.IndexAny(path, ".["); i > 0 {
+		return path[:i]
+	}
+
+	return path
+}
+
+// columnStats accumulates the changes of a column
+type columnStats struct {
+	rows        int
+	counts      map[string]*Transition // Transitions by their old and new values
+	nullToValue int
+	valueToNull int
+	deltas      []*big.Rat
+	nonNumeric  bool // Some change has a value that is not a number
+}
+
+// add records a change of the column from one value to another
+func (s *columnStats) add(before, after any) {
+	s.rows++
+
+	key := elementKey(before) + "\x00" + elementKey(after)
+	if t, ok := s.counts[key]; ok {
+		t.Count++
+	} else {
+		s.counts[key] = &Transition{Before: before, After: after, Count: 1}
+	}
+
+	switch {
+	case before == nil && after != nil:
+		s.nullToValue++
+	case before != nil && after == nil:
+		s.valueToNull++
+	case before != nil && after != nil && !s.nonNumeric:
+		x, okX := numberOf(before)
+		y, okY := numberOf(after)
+		if !okX || !okY {
+			s.nonNumeric = true
+			s.deltas = nil
+
+			return
+		}
+
+		s.deltas = append(s.deltas, new(big.Rat).Sub(y, x))
+	}
+}
+
+// summary returns the aggregated changes of the column
+func (s *columnStats) summary(col string, top int) ColumnSummary {
+	transitions := make([]Transition, 0, len(s.counts))
+	for _, t := range s.counts {
+		transitions = append(transitions, *t)
+	}
+
+	sort.Slice(transitions, func(i, j int) bool {
+		if transitions[i].Count != transitions[j].Count {
+			return transitions[i].Count > transitions[j].Count
+		}
+
+		return elementKey(transitions[i].Before)+elementKey(transitions[i].After) <
+			elementKey(transitions[j].Before)+elementKey(transitions[j].After)
+	})
+
+	if top > 0 && len(transitions) > top {
+		transitions = transitions[:top]
+	}
+
+	summary := ColumnSummary{
+		Column:      col,
+		Rows:        s.rows,
+		Transitions: transitions,
+		Distinct:    len(s.counts),
+		NullToValue: s.nullToValue,
+		ValueToNull: s.valueToNull,
+	}
+
+	if len(s.deltas) > 0 {
+		sum := new(big.Rat)
+		minDelta, maxDelta := s.deltas[0], s.deltas[0]
+		for _, d := range s.deltas {
+			sum.Add(sum, d)
+			if d.Cmp(minDelta) < 0 {
+				minDelta = d
+			}
+			if d.Cmp(maxDelta) > 0 {
+				maxDelta = d
+			}
+		}
+
+		avg := sum.Quo(sum, new(big.Rat).SetInt64(int64(len(s.deltas))))
+		delta := &Delta{}
+		delta.Min, _ = minDelta.Float64()
+		delta.Max, _ = maxDelta.Float64()
+		delta.Avg, _ = avg.Float64()
+		summary.Delta = delta
+	}
+
+	return summary
+}
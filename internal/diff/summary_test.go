@@ -0,0 +1,82 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+// statusUpdate returns an updated row whose status and amount changed
+func statusUpdate(id int, before, after string, amountBefore, amountAfter any) UpdatedRow {
+	return UpdatedRow{
+		PrimaryKey: map[string]any{"id": id},
+		Before:     map[string]any{"id": id, "status": before, "amount": amountBefore},
+		After:      map[string]any{"id": id, "status": after, "amount": amountAfter},
+		Changes: []Change{
+			{Path: "status", Before: before, After: after},
+			{Path: "amount", Before: amountBefore, After: amountAfter},
+		},
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	result := &Result{Tables: map[string]*TableDiff{
+		"orders": {
+			TableName:   "orders",
+			RenamedFrom: "purchases",
+			Inserted:    []map[string]any{{"id": 9}},
+			Updated: []UpdatedRow{
+				statusUpdate(1, "new", "paid", 10.0, 12.0),
+				statusUpdate(2, "new", "paid", 10.0, 16.0),
+				statusUpdate(3, "paid", "shipped", nil, 5.0),
+				{
+					PrimaryKey: map[string]any{"id": 4},
+					Before:     map[string]any{"id": 4, "payload": map[string]any{"qty": 1.0, "sku": "a"}},
+					After:      map[string]any{"id": 4, "payload": map[string]any{"qty": 2.0, "sku": "b"}},
+					Changes: []Change{
+						{Path: "payload.qty", Before: 1.0, After: 2.0},
+						{Path: "payload.sku", Before: "a", After: "b"},
+					},
+				},
+			},
+		},
+	}}
+
+	summary := result.Summarize(1)
+	if len(summary.Tables) != 1 {
+		t.Fatalf("tables = %d, want 1", len(summary.Tables))
+	}
+
+	table := summary.Tables[0]
+	if table.TableName != "orders" || table.RenamedFrom != "purchases" || table.Inserted != 1 || table.Updated != 4 {
+		t.Errorf("table = %+v", table)
+	}
+
+	columns := make(map[string]ColumnSummary)
+	var order []string
+	for _, col := range table.Columns {
+		columns[col.Column] = col
+		order = append(order, col.Column)
+	}
+
+	// Most changed first, then by name; paths inside a column count once per row
+	if want := []string{"amount", "status", "payload"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("columns = %v, want %v", order, want)
+	}
+
+	status := columns["status"]
+	if status.Rows != 3 || status.Distinct != 2 || len(status.Transitions) != 1 || status.Delta != nil {
+		t.Errorf("status = %+v", status)
+	}
+	if want := (Transition{Before: "new", After: "paid", Count: 2}); !reflect.DeepEqual(status.Transitions[0], want) {
+		t.Errorf("top transition = %+v, want %+v", status.Transitions[0], want)
+	}
+
+	amount := columns["amount"]
+	if amount.NullToValue != 1 || amount.Delta == nil || amount.Delta.Min != 2 || amount.Delta.Max != 6 || amount.Delta.Avg != 4 {
+		t.Errorf("amount = %+v, delta %+v", amount, amount.Delta)
+	}
+
+	if payload := columns["payload"]; payload.Rows != 1 || payload.Delta != nil {
+		t.Errorf("payload = %+v", payload)
+	}
+}
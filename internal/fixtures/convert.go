@@ -0,0 +1,77 @@
+package fixtures
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timestampLayouts are the text formats of timestamps in pg_dump output and CSV exports
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+	"2006-01-02 15:04:05.999999999",
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// convertText converts a text value to the representation a live capture
+// stores for a column of the given type. Without a type, the value is kept
+// as text, since digits alone don't tell a number from a code like a zip.
+func convertText(value, pgType string) any {
+	t := strings.ToLower(pgType)
+
+	switch {
+	case strings.HasSuffix(t, "[]"):
+		return value
+	case t == "smallint", t == "integer", t == "bigint", t == "oid":
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case t == "real", t == "double precision":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case t == "boolean":
+		if b, ok := parseBool(value); ok {
+			return b
+		}
+	case strings.HasPrefix(t, "timestamp"), t == "date":
+		if ts, ok := parseTimestamp(value); ok {
+			return ts.Format(time.RFC3339Nano)
+		}
+	case t == "bytea":
+		// Binary values are stored base64-encoded, like driver byte slices
+		if data, err := hex.DecodeString(strings.TrimPrefix(value, `\x`)); err == nil {
+			return base64.StdEncoding.EncodeToString(data)
+		}
+	}
+
+	return value
+}
+
+// parseBool parses a PostgreSQL boolean literal
+func parseBool(value string) (bool, bool) {
+	switch strings.ToLower(value) {
+	case "t", "true", "y", "yes", "on", "1":
+		return true, true
+	case "f", "false", "n", "no", "off", "0":
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// parseTimestamp parses a timestamp or date; values without a time zone are taken as UTC
+func parseTimestamp(value string) (time.Time, bool) {
+	for _, layout := range timestampLayouts {
+		if ts, err := time.Parse(layout, value); err == nil {
+			return ts, true
+		}
+	}
+
+	return time.Time{}, false
+}
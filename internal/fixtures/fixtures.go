@@ -0,0 +1,306 @@
+package fixtures
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rom8726/snapdiff/internal/storage"
+)
+
+// table is the data of a table read from fixtures
+type table struct {
+	Name    string
+	Columns []string // Columns in file order
+	Rows    []map[string]any
+	Text    bool // Values are text that still has to be converted
+}
+
+// Run builds a snapshot from fixture files or a pg_dump file
+func Run(ctx context.Context, opts Options) error {
+	if (opts.FromDir == "") == (opts.FromPgDump == "") {
+		return fmt.Errorf("exactly one of a fixtures directory and a pg_dump file is required")
+	}
+
+	compression, err := storage.ParseCompression(opts.Compression)
+	if err != nil {
+		return err
+	}
+
+	store, err := storage.NewStorage(opts.OutputDir)
+	if err != nil {
+		return fmt.Errorf("failed to create storage: %w", err)
+	}
+
+	var types map[string]map[string]string
+	if opts.TypesFrom != "" {
+		types, err = columnTypes(ctx, store, opts.TypesFrom)
+		if err != nil {
+			return err
+		}
+	}
+
+	var tables []*table
+	var source string
+	if opts.FromDir != "" {
+		tables, err = readDir(opts.FromDir)
+		source = "fixtures:" + opts.FromDir
+	} else {
+		tables, err = readPgDumpFile(opts.FromPgDump)
+		source = "pg_dump:" + opts.FromPgDump
+	}
+	if err != nil {
+		return err
+	}
+
+	writer, err := store.CreateSnapshot(ctx, opts.Label, storage.WriteOptions{
+		Format:      storage.FormatJSON,
+		Compression: compression,
+		Source:      source,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	// Discard the partial snapshot on failure; this is a no-op after Commit
+	defer func() { _ = writer.Abort(context.WithoutCancel(ctx)) }()
+
+	for _, t := range tables {
+		if len(opts.Tables) > 0 && !slices.Contains(opts.Tables, t.Name) {
+			continue
+		}
+
+		info := storage.TableInfo{Name: t.Name}
+		for _, col := range t.Columns {
+			info.Columns = append(info.Columns, storage.Column{Name: col, Type: types[t.Name][col]})
+		}
+
+		if t.Text {
+			convertRows(t, types[t.Name])
+		}
+
+		if err := writer.WriteTable(ctx, info, t.Rows); err != nil {
+			return fmt.Errorf("failed to save snapshot for table %s: %w", t.Name, err)
+		}
+
+		log.Printf("Saved snapshot for table %s with %d rows", t.Name, len(t.Rows))
+	}
+
+	if err := writer.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to save snapshot manifest: %w", err)
+	}
+
+	log.Printf("Snapshot '%s' created successfully", opts.Label)
+
+	return nil
+}
+
+// columnTypes returns the column types of every table of a snapshot
+func columnTypes(ctx context.Context, store *storage.Storage, label string) (map[string]map[string]string, error) {
+	manifest, err := store.LoadManifest(ctx, label)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load snapshot %s for column types: %w", label, err)
+	}
+
+	types := make(map[string]map[string]string)
+	for _, t := range manifest.Tables {
+		types[t.Name] = make(map[string]string)
+		for _, col := range t.Columns {
+			types[t.Name][col.Name] = col.Type
+		}
+	}
+
+	return types, nil
+}
+
+// convertRows converts the text values of a table according to the column types
+func convertRows(t *table, types map[string]string) {
+	for _, row := range t.Rows {
+		for col, value := range row {
+			if s, ok := value.(string); ok {
+				row[col] = convertText(s, types[col])
+			}
+		}
+	}
+}
+
+// readDir reads one table from every CSV, JSON and YAML file in a directory
+func readDir(dir string) ([]*table, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures directory: %w", err)
+	}
+
+	var tables []*table
+	seen := make(map[string]string)
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		file := entry.Name()
+		ext := strings.ToLower(filepath.Ext(file))
+		name := strings.TrimSuffix(file, filepath.Ext(file))
+
+		var read func(path string) (*table, error)
+		switch ext {
+		case ".csv":
+			read = readCSV
+		case ".json":
+			read = readJSON
+		case ".yaml", ".yml":
+			read = readYAML
+		default:
+			continue
+		}
+
+		if prev, ok := seen[name]; ok {
+			return nil, fmt.Errorf("table %s is defined by both %s and %s", name, prev, file)
+		}
+		seen[name] = file
+
+		t, err := read(filepath.Join(dir, file))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+
+		t.Name = name
+		tables = append(tables, t)
+	}
+
+	return tables, nil
+}
+
+// readCSV reads a CSV file with a header row. Empty values are NULL, as in PostgreSQL's CSV format.
+func readCSV(path string) (*table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("missing header row")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	t := &table{Columns: header, Rows: []map[string]any{}, Text: true}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]any, len(header))
+		for i, col := range header {
+			if record[i] == "" {
+				row[col] = nil
+			} else {
+				row[col] = record[i]
+			}
+		}
+		t.Rows = append(t.Rows, row)
+	}
+
+	return t, nil
+}
+
+// readJSON reads a JSON array of row objects
+func readJSON(path string) (*table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []map[string]any
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+
+	return &table{Columns: columnsOf(rows), Rows: nonNil(rows)}, nil
+}
+
+// readYAML reads a YAML list of row mappings
+func readYAML(path string) (*table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []map[string]any
+	if err := yaml.Unmarshal(data, &rows); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal YAML: %w", err)
+	}
+
+	return &table{Columns: columnsOf(rows), Rows: nonNil(rows)}, nil
+}
+
+// readPgDumpFile reads the tables of a pg_dump file
+func readPgDumpFile(path string) ([]*table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dump: %w", err)
+	}
+	defer f.Close()
+
+	tables, err := readPgDump(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	for _, t := range tables {
+		t.Text = true
+		if t.Rows == nil {
+			t.Rows = []map[string]any{}
+		}
+	}
+
+	return tables, nil
+}
+
+// columnsOf returns the sorted columns of rows
+func columnsOf(rows []map[string]any) []string {
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		for col := range row {
+			seen[col] = true
+		}
+	}
+
+	columns := make([]string, 0, len(seen))
+	for col := range seen {
+		columns = append(columns, col)
+	}
+
+	sort.Strings(columns)
+
+	return columns
+}
+
+// nonNil returns rows, or an empty slice if there are none
+func nonNil(rows []map[string]any) []map[string]any {
+	if rows == nil {
+		return []map[string]any{}
+	}
+
+	return rows
+}
@@ -0,0 +1,136 @@
+package fixtures
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadPgDump(t *testing.T) {
+	tests := []struct {
+		name    string
+		dump    string
+		want    []*table
+		wantErr string
+	}{
+		{
+			name: "copy blocks",
+			dump: "SET client_encoding = 'UTF8';\n" +
+				"COPY public.orders (id, \"Status\") FROM stdin;\n" +
+				"1\tnew\n" +
+				"2\t\\N\n" +
+				"\\.\n" +
+				"COPY customers (id) FROM stdin;\n" +
+				"\\.\n",
+			want: []*table{
+				{Name: "orders", Columns: []string{"id", "Status"}, Rows: []map[string]any{
+					{"id": "1", "Status": "new"},
+					{"id": "2", "Status": nil},
+				}},
+				{Name: "customers", Columns: []string{"id"}},
+			},
+		},
+		{
+			name: "same table in two schemas",
+			dump: "COPY a.orders (id) FROM stdin;\n\\.\n" +
+				"COPY b.orders (id) FROM stdin;\n\\.\n",
+			wantErr: "line 3: table orders is dumped from more than one schema (a.orders and b.orders)",
+		},
+		{
+			name:    "wrong number of values",
+			dump:    "COPY orders (id, status) FROM stdin;\n1\n\\.\n",
+			wantErr: "line 2: expected 2 values for table orders, got 1",
+		},
+		{
+			name:    "octal escape out of range",
+			dump:    "COPY orders (id, note) FROM stdin;\n1\t\\400\n\\.\n",
+			wantErr: `line 2: column note of table orders: invalid octal escape \400`,
+		},
+		{
+			name:    "unterminated",
+			dump:    "COPY orders (id) FROM stdin;\n1\n",
+			wantErr: "unterminated COPY data for table orders",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tables, err := readPgDump(strings.NewReader(tt.dump))
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(tables, tt.want) {
+				t.Errorf("tables = %+v, want %+v", tables, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnescapeCopy(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain", value: "abc", want: "abc"},
+		{name: "control characters", value: `a\tb\nc\\d`, want: "a\tb\nc\\d"},
+		{name: "hex", value: `\x41\x4a`, want: "AJ"},
+		{name: "octal", value: `\101\0`, want: "A\x00"},
+		{name: "largest octal", value: `\377`, want: "\xff"},
+		{name: "octal out of range", value: `\400`, wantErr: true},
+		{name: "other escaped character", value: `\q`, want: "q"},
+		{name: "trailing backslash", value: `a\`, want: `a\`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := unescapeCopy(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error: %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("unescapeCopy(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertText(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		pgType string
+		want   any
+	}{
+		{name: "digits without a type", value: "00501", want: "00501"},
+		{name: "decimal without a type", value: "1.50", want: "1.50"},
+		{name: "boolean without a type", value: "true", want: "true"},
+		{name: "integer", value: "42", pgType: "bigint", want: int64(42)},
+		{name: "invalid integer", value: "n/a", pgType: "integer", want: "n/a"},
+		{name: "double", value: "1.5", pgType: "double precision", want: 1.5},
+		{name: "numeric stays text", value: "1.50", pgType: "numeric", want: "1.50"},
+		{name: "boolean", value: "t", pgType: "boolean", want: true},
+		{name: "timestamp", value: "2024-01-02 03:04:05+02", pgType: "timestamp with time zone", want: "2024-01-02T03:04:05+02:00"},
+		{name: "timestamp without zone", value: "2024-01-02 03:04:05.5", pgType: "timestamp without time zone", want: "2024-01-02T03:04:05.5Z"},
+		{name: "bytea", value: `\x4142`, pgType: "bytea", want: "QUI="},
+		{name: "array", value: "{1,2}", pgType: "integer[]", want: "{1,2}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := convertText(tt.value, tt.pgType); got != tt.want {
+				t.Errorf("convertText(%q, %q) = %#v, want %#v", tt.value, tt.pgType, got, tt.want)
+			}
+		})
+	}
+}
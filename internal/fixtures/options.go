@@ -0,0 +1,12 @@
+package fixtures
+
+// Options contains configuration for building a snapshot from fixture data
+type Options struct {
+	Label       string   // Snapshot label
+	FromDir     string   // Directory with one CSV, JSON or YAML file per table
+	FromPgDump  string   // Plain-format pg_dump file whose COPY data is read
+	TypesFrom   string   // Snapshot whose column types are used to convert text values
+	Tables      []string // Specific tables to include
+	Compression string   // Table file compression (none, gzip, zstd)
+	OutputDir   string   // Output base directory (default ".snapdiff")
+}
@@ -0,0 +1,221 @@
+package fixtures
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// readPgDump reads the COPY data blocks of a plain-format pg_dump file.
+// Values are kept as text, or nil for NULL.
+func readPgDump(r io.Reader) ([]*table, error) {
+	reader := bufio.NewReader(r)
+
+	var tables []*table
+	var current *table
+	schemas := make(map[string]string) // Schema each table name was read from
+	lineNo := 0
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read dump: %w", err)
+		}
+		if line == "" && err == io.EOF {
+			break
+		}
+		lineNo++
+
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		if current == nil {
+			if strings.HasPrefix(line, "COPY ") {
+				var schema string
+				current, schema, err = parseCopyHeader(line)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", lineNo, err)
+				}
+
+				// Without their schemas, tables of several schemas would be merged
+				if other, ok := schemas[current.Name]; ok {
+					return nil, fmt.Errorf("line %d: table %s is dumped from more than one schema (%s and %s)",
+						lineNo, current.Name, qualifiedName(other, current.Name), qualifiedName(schema, current.Name))
+				}
+				schemas[current.Name] = schema
+			}
+
+			continue
+		}
+
+		if line == `\.` {
+			tables = append(tables, current)
+			current = nil
+
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != len(current.Columns) {
+			return nil, fmt.Errorf("line %d: expected %d values for table %s, got %d",
+				lineNo, len(current.Columns), current.Name, len(fields))
+		}
+
+		row := make(map[string]any, len(fields))
+		for i, field := range fields {
+			if field == `\N` {
+				row[current.Columns[i]] = nil
+
+				continue
+			}
+
+			value, err := unescapeCopy(field)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: column %s of table %s: %w", lineNo, current.Columns[i], current.Name, err)
+			}
+			row[current.Columns[i]] = value
+		}
+		current.Rows = append(current.Rows, row)
+	}
+
+	if current != nil {
+		return nil, fmt.Errorf("unterminated COPY data for table %s", current.Name)
+	}
+
+	return tables, nil
+}
+
+// parseCopyHeader parses a line like COPY public.orders (id, status) FROM stdin;
+// and returns the table and its schema, empty if the name is unqualified
+func parseCopyHeader(line string) (*table, string, error) {
+	rest := strings.TrimPrefix(line, "COPY ")
+
+	open := strings.Index(rest, " (")
+	end := strings.LastIndex(rest, ") FROM stdin;")
+	if open < 0 || end < open {
+		return nil, "", fmt.Errorf("unsupported COPY statement: %s", line)
+	}
+
+	// Tables are named without their schema, like in live snapshots
+	names := splitIdentifiers(rest[:open], '.')
+	name := names[len(names)-1]
+	schema := strings.Join(names[:len(names)-1], ".")
+
+	return &table{
+		Name:    name,
+		Columns: splitIdentifiers(rest[open+2:end], ','),
+	}, schema, nil
+}
+
+// qualifiedName returns the name of a table with its schema, if any
+func qualifiedName(schema, name string) string {
+	if schema == "" {
+		return name
+	}
+
+	return schema + "." + name
+}
+
+// splitIdentifiers splits a list of possibly quoted SQL identifiers
+func splitIdentifiers(s string, sep rune) []string {
+	var parts []string
+	var current strings.Builder
+	quoted := false
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+
+		switch {
+		case c == '"' && quoted && i+1 < len(runes) && runes[i+1] == '"':
+			current.WriteRune('"')
+			i++
+		case c == '"':
+			quoted = !quoted
+		case c == sep && !quoted:
+			parts = append(parts, strings.TrimSpace(current.String()))
+			current.Reset()
+		case c == ' ' && !quoted:
+			// Whitespace outside quotes only separates tokens
+		default:
+			current.WriteRune(c)
+		}
+	}
+
+	return append(parts, strings.TrimSpace(current.String()))
+}
+
+// unescapeCopy decodes a value in COPY text format
+func unescapeCopy(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 == len(s) {
+			b.WriteByte(c)
+
+			continue
+		}
+
+		i++
+		switch next := s[i]; next {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case 'x':
+			j := i + 1
+			for j < len(s) && j < i+3 && isHex(s[j]) {
+				j++
+			}
+
+			if j == i+1 {
+				b.WriteByte(next)
+
+				continue
+			}
+
+			n, _ := strconv.ParseUint(s[i+1:j], 16, 8)
+			b.WriteByte(byte(n))
+			i = j - 1
+		default:
+			if next < '0' || next > '7' {
+				b.WriteByte(next)
+
+				continue
+			}
+
+			j := i
+			for j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7' {
+				j++
+			}
+
+			n, err := strconv.ParseUint(s[i:j], 8, 8)
+			if err != nil {
+				return "", fmt.Errorf("invalid octal escape \\%s", s[i:j])
+			}
+
+			b.WriteByte(byte(n))
+			i = j - 1
+		}
+	}
+
+	return b.String(), nil
+}
+
+// isHex reports whether c is a hexadecimal digit
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
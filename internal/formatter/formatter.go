@@ -1,6 +1,7 @@
 package formatter
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -19,6 +20,7 @@ const (
 	FormatCLI      FormatType = "cli"
 	FormatYAML     FormatType = "yaml"
 	FormatMarkdown FormatType = "markdown"
+	FormatJSON     FormatType = "json"
 )
 
 // Options contains configuration for the formatter
@@ -27,20 +29,19 @@ type Options struct {
 	SortKeys   bool
 	Limit      int
 	OutputFile string
+	GroupByFK  bool
 }
 
 // FormatDiff formats the diff result according to the specified format
 func FormatDiff(result *diff.Result, opts Options) error {
-	var writer io.Writer
-	if opts.OutputFile == "" {
-		writer = os.Stdout
-	} else {
-		file, err := os.Create(opts.OutputFile)
-		if err != nil {
-			return fmt.Errorf("failed to create output file: %w", err)
-		}
-		defer file.Close()
-		writer = file
+	writer, closeFn, err := openOutput(opts.OutputFile)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	if opts.GroupByFK {
+		return formatGrouped(writer, result, opts)
 	}
 
 	switch opts.Format {
@@ -50,11 +51,27 @@ func FormatDiff(result *diff.Result, opts Options) error {
 		return formatYAML(writer, result, opts)
 	case FormatMarkdown:
 		return formatMarkdown(writer, result, opts)
+	case FormatJSON:
+		return formatJSON(writer, result, opts)
 	default:
 		return fmt.Errorf("unsupported format: %s", opts.Format)
 	}
 }
 
+// openOutput opens the output file, or returns stdout if file is empty
+func openOutput(file string) (io.Writer, func(), error) {
+	if file == "" {
+		return os.Stdout, func() {}, nil
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+
+	return f, func() { _ = f.Close() }, nil
+}
+
 // formatCLI formats the diff result in CLI format
 func formatCLI(w io.Writer, result *diff.Result, opts Options) error {
 	tableNames := getSortedTableNames(result)
@@ -62,7 +79,7 @@ func formatCLI(w io.Writer, result *diff.Result, opts Options) error {
 	for _, tableName := range tableNames {
 		tableDiff := result.Tables[tableName]
 
-		_, _ = fmt.Fprintf(w, "📄 Table: %s\n", tableName)
+		_, _ = fmt.Fprintf(w, "📄 Table: %s\n", tableTitle(tableDiff))
 
 		if len(tableDiff.Inserted) > 0 {
 			_, _ = fmt.Fprintf(w, "  ✅  Inserted: %d\n", len(tableDiff.Inserted))
@@ -91,7 +108,7 @@ func formatCLI(w io.Writer, result *diff.Result, opts Options) error {
 
 			for _, row := range rows {
 				pk := formatRow(row.PrimaryKey, opts.SortKeys)
-				changes := formatChanges(row.Before, row.After, opts.SortKeys)
+				changes := formatChanges(row.Changes)
 				_, _ = fmt.Fprintf(w, "      ~ %s, %s\n", pk, changes)
 			}
 
@@ -117,6 +134,32 @@ func formatCLI(w io.Writer, result *diff.Result, opts Options) error {
 			}
 		}
 
+		if len(tableDiff.Rekeyed) > 0 {
+			_, _ = fmt.Fprintf(w, "  🔁  Rekeyed: %d\n", len(tableDiff.Rekeyed))
+
+			rows := tableDiff.Rekeyed
+			if opts.Limit > 0 && len(rows) > opts.Limit {
+				rows = rows[:opts.Limit]
+			}
+
+			for _, row := range rows {
+				keys := fmt.Sprintf("%s → %s", formatRow(row.OldKey, opts.SortKeys), formatRow(row.NewKey, opts.SortKeys))
+				if len(row.Changes) > 0 {
+					keys += ", " + formatChanges(row.Changes)
+				}
+				_, _ = fmt.Fprintf(w, "      ↪ %s\n", keys)
+			}
+
+			if opts.Limit > 0 && len(tableDiff.Rekeyed) > opts.Limit {
+				_, _ = fmt.Fprintf(w, "      ... and %d more\n", len(tableDiff.Rekeyed)-opts.Limit)
+			}
+		}
+
+		if len(tableDiff.Dangling) > 0 {
+			_, _ = fmt.Fprintf(w, "  ⚠️  Dangling references: %d\n", len(tableDiff.Dangling))
+			formatDanglingCLI(w, tableDiff.Dangling, opts)
+		}
+
 		_, _ = fmt.Fprintln(w)
 	}
 
@@ -125,10 +168,29 @@ func formatCLI(w io.Writer, result *diff.Result, opts Options) error {
 
 // formatYAML formats the diff result in YAML format
 func formatYAML(w io.Writer, result *diff.Result, opts Options) error {
-	// For simplicity, we'll use JSON as a placeholder
-	// In a real implementation, you'd use a YAML library
+	data, err := yaml.Marshal(diffOutput(result, opts))
+	if err != nil {
+		return fmt.Errorf("failed to marshal YAML: %w", err)
+	}
+
+	_, err = w.Write(data)
 
-	// Convert to a map for JSON marshaling
+	return err
+}
+
+// formatJSON formats the diff result in JSON format, with the same structure as YAML
+func formatJSON(w io.Writer, result *diff.Result, opts Options) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(diffOutput(result, opts)); err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	return nil
+}
+
+// diffOutput converts the diff result to a map of tables for YAML and JSON output
+func diffOutput(result *diff.Result, opts Options) map[string]any {
 	output := make(map[string]any)
 
 	// Get sorted table names
@@ -139,6 +201,10 @@ func formatYAML(w io.Writer, result *diff.Result, opts Options) error {
 
 		tableOutput := make(map[string]any)
 
+		if tableDiff.RenamedFrom != "" {
+			tableOutput["renamed_from"] = tableDiff.RenamedFrom
+		}
+
 		// Add inserted rows
 		if len(tableDiff.Inserted) > 0 {
 			// Apply limit
@@ -165,6 +231,7 @@ func formatYAML(w io.Writer, result *diff.Result, opts Options) error {
 					"primary_key": row.PrimaryKey,
 					"before":      row.Before,
 					"after":       row.After,
+					"changes":     changesOutput(row.Changes),
 				}
 				updatedRows = append(updatedRows, updatedRow)
 			}
@@ -183,19 +250,39 @@ func formatYAML(w io.Writer, result *diff.Result, opts Options) error {
 			tableOutput["deleted"] = rows
 		}
 
-		output[tableName] = tableOutput
-	}
+		// Add rekeyed rows
+		if len(tableDiff.Rekeyed) > 0 {
+			// Apply limit
+			rows := tableDiff.Rekeyed
+			if opts.Limit > 0 && len(rows) > opts.Limit {
+				rows = rows[:opts.Limit]
+			}
 
-	// Marshal to JSON
-	data, err := yaml.Marshal(output)
-	if err != nil {
-		return fmt.Errorf("failed to marshal YAML: %w", err)
-	}
+			rekeyedRows := make([]map[string]any, 0, len(rows))
+			for _, row := range rows {
+				rekeyedRow := map[string]any{
+					"old_key": row.OldKey,
+					"new_key": row.NewKey,
+					"before":  row.Before,
+					"after":   row.After,
+				}
+				if len(row.Changes) > 0 {
+					rekeyedRow["changes"] = changesOutput(row.Changes)
+				}
+				rekeyedRows = append(rekeyedRows, rekeyedRow)
+			}
 
-	// Write to output
-	_, err = w.Write(data)
+			tableOutput["rekeyed"] = rekeyedRows
+		}
 
-	return err
+		if len(tableDiff.Dangling) > 0 {
+			tableOutput["dangling"] = danglingOutput(tableDiff.Dangling, opts)
+		}
+
+		output[tableName] = tableOutput
+	}
+
+	return output
 }
 
 // formatMarkdown formats the diff result in Markdown format
@@ -205,7 +292,7 @@ func formatMarkdown(w io.Writer, result *diff.Result, opts Options) error {
 	for _, tableName := range tableNames {
 		tableDiff := result.Tables[tableName]
 
-		_, _ = fmt.Fprintf(w, "## Table: %s\n\n", tableName)
+		_, _ = fmt.Fprintf(w, "## Table: %s\n\n", tableTitle(tableDiff))
 
 		if len(tableDiff.Inserted) > 0 {
 			_, _ = fmt.Fprintf(w, "### ✅ Inserted (%d rows)\n", len(tableDiff.Inserted))
@@ -259,16 +346,13 @@ func formatMarkdown(w io.Writer, result *diff.Result, opts Options) error {
 					id = formatRow(row.PrimaryKey, opts.SortKeys)
 				}
 
-				changedFields := getChangedFields(row.Before, row.After)
-
-				for i, field := range changedFields {
-					before := fmt.Sprintf("%v", row.Before[field])
-					after := fmt.Sprintf("%v", row.After[field])
+				for i, change := range row.Changes {
+					before, after := changeValues(change)
 
 					if i == 0 {
-						_, _ = fmt.Fprintf(w, "| %s | %s | %s | %s |\n", id, field, before, after)
+						_, _ = fmt.Fprintf(w, "| %s | %s | %s | %s |\n", id, change.Path, before, after)
 					} else {
-						_, _ = fmt.Fprintf(w, "| | %s | %s | %s |\n", field, before, after)
+						_, _ = fmt.Fprintf(w, "| | %s | %s | %s |\n", change.Path, before, after)
 					}
 				}
 			}
@@ -312,6 +396,35 @@ func formatMarkdown(w io.Writer, result *diff.Result, opts Options) error {
 
 			_, _ = fmt.Fprintln(w)
 		}
+
+		if len(tableDiff.Rekeyed) > 0 {
+			_, _ = fmt.Fprintf(w, "### 🔁 Rekeyed (%d rows)\n", len(tableDiff.Rekeyed))
+
+			rows := tableDiff.Rekeyed
+			if opts.Limit > 0 && len(rows) > opts.Limit {
+				rows = rows[:opts.Limit]
+			}
+
+			_, _ = fmt.Fprintln(w, "| Old key | New key | Changes |")
+			_, _ = fmt.Fprintln(w, "|-----|-----|-----|")
+
+			for _, row := range rows {
+				oldKey := formatRow(row.OldKey, opts.SortKeys)
+				newKey := formatRow(row.NewKey, opts.SortKeys)
+				_, _ = fmt.Fprintf(w, "| %s | %s | %s |\n", oldKey, newKey, formatChanges(row.Changes))
+			}
+
+			if opts.Limit > 0 && len(tableDiff.Rekeyed) > opts.Limit {
+				_, _ = fmt.Fprintf(w, "\n_... and %d more rows_\n", len(tableDiff.Rekeyed)-opts.Limit)
+			}
+
+			_, _ = fmt.Fprintln(w)
+		}
+
+		if len(tableDiff.Dangling) > 0 {
+			_, _ = fmt.Fprintf(w, "### ⚠️ Dangling references (%d rows)\n", len(tableDiff.Dangling))
+			formatDanglingMarkdown(w, tableDiff.Dangling, opts)
+		}
 	}
 
 	return nil
@@ -329,6 +442,15 @@ func getSortedTableNames(result *diff.Result) []string {
 	return tableNames
 }
 
+// tableTitle returns the name of a table, with its old name if it was renamed
+func tableTitle(tableDiff *diff.TableDiff) string {
+	if tableDiff.RenamedFrom != "" {
+		return fmt.Sprintf("%s (renamed from %s)", tableDiff.TableName, tableDiff.RenamedFrom)
+	}
+
+	return tableDiff.TableName
+}
+
 // formatRow formats a row as a string
 func formatRow(row map[string]any, sortKeys bool) string {
 	var parts []string
@@ -349,41 +471,53 @@ func formatRow(row map[string]any, sortKeys bool) string {
 	return strings.Join(parts, ", ")
 }
 
-// formatChanges formats the changes between two rows
-func formatChanges(before, after map[string]any, sortKeys bool) string {
-	var parts []string
-
-	keys := make(map[string]bool)
-	for k := range before {
-		keys[k] = true
-	}
-	for k := range after {
-		keys[k] = true
+// formatChanges formats the changes of an updated row, e.g. "payload.items[3].qty: 1 → 2"
+func formatChanges(changes []diff.Change) string {
+	parts := make([]string, 0, len(changes))
+	for _, change := range changes {
+		switch {
+		case change.Added:
+			parts = append(parts, fmt.Sprintf("%s: + %s", change.Path, FormatValue(change.After)))
+		case change.Removed:
+			parts = append(parts, fmt.Sprintf("%s: - %s", change.Path, FormatValue(change.Before)))
+		default:
+			parts = append(parts, fmt.Sprintf("%s: %s → %s", change.Path, FormatValue(change.Before), FormatValue(change.After)))
+		}
 	}
 
-	keySlice := make([]string, 0, len(keys))
-	for k := range keys {
-		keySlice = append(keySlice, k)
-	}
+	return strings.Join(parts, ", ")
+}
 
-	if sortKeys {
-		sort.Strings(keySlice)
+// changeValues returns the before and after values of a change for display,
+// empty where the path does not exist
+func changeValues(change diff.Change) (string, string) {
+	var before, after string
+	if !change.Added {
+		before = FormatValue(change.Before)
+	}
+	if !change.Removed {
+		after = FormatValue(change.After)
 	}
 
-	for _, k := range keySlice {
-		beforeVal, beforeOK := before[k]
-		afterVal, afterOK := after[k]
+	return before, after
+}
 
-		if !beforeOK {
-			parts = append(parts, fmt.Sprintf("%s: null → %v", k, afterVal))
-		} else if !afterOK {
-			parts = append(parts, fmt.Sprintf("%s: %v → null", k, beforeVal))
-		} else if beforeVal != afterVal {
-			parts = append(parts, fmt.Sprintf("%s: %v → %v", k, beforeVal, afterVal))
+// changesOutput converts the changes of an updated row for YAML output
+func changesOutput(changes []diff.Change) []map[string]any {
+	output := make([]map[string]any, 0, len(changes))
+	for _, change := range changes {
+		item := map[string]any{"path": change.Path}
+		if !change.Added {
+			item["before"] = change.Before
+		}
+		if !change.Removed {
+			item["after"] = change.After
 		}
+
+		output = append(output, item)
 	}
 
-	return strings.Join(parts, ", ")
+	return output
 }
 
 // getAllColumnNames returns all column names from a slice of rows
@@ -405,28 +539,3 @@ func getAllColumnNames(rows []map[string]any) []string {
 
 	return columns
 }
-
-// getChangedFields returns a list of fields that changed between two rows
-func getChangedFields(before, after map[string]any) []string {
-	var changedFields []string
-
-	for field, beforeVal := range before {
-		if afterVal, ok := after[field]; ok {
-			if beforeVal != afterVal {
-				changedFields = append(changedFields, field)
-			}
-		} else {
-			changedFields = append(changedFields, field)
-		}
-	}
-
-	for field := range after {
-		if _, ok := before[field]; !ok {
-			changedFields = append(changedFields, field)
-		}
-	}
-
-	sort.Strings(changedFields)
-
-	return changedFields
-}
@@ -0,0 +1,176 @@
+package formatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/rom8726/snapdiff/internal/diff"
+)
+
+// testResult returns a diff of a renamed orders table with one change of each kind
+func testResult() *diff.Result {
+	return &diff.Result{Tables: map[string]*diff.TableDiff{
+		"orders": {
+			TableName:   "orders",
+			RenamedFrom: "purchases",
+			Inserted:    []map[string]any{{"id": 3.0}, {"id": 5.0}},
+			Updated: []diff.UpdatedRow{{
+				PrimaryKey: map[string]any{"id": 1.0},
+				Before:     map[string]any{"id": 1.0, "tags": []any{"a"}},
+				After:      map[string]any{"id": 1.0, "tags": []any{"a", "b"}},
+				Changes:    []diff.Change{{Path: "tags[]", After: "b", Added: true}},
+			}},
+			Rekeyed: []diff.RekeyedRow{{
+				OldKey: map[string]any{"id": 2.0},
+				NewKey: map[string]any{"id": 20.0},
+				Before: map[string]any{"id": 2.0},
+				After:  map[string]any{"id": 20.0},
+			}},
+		},
+	}}
+}
+
+func TestFormatJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := formatJSON(&buf, testResult(), Options{Limit: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	var output map[string]map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &output); err != nil {
+		t.Fatalf("invalid JSON %s: %v", buf.String(), err)
+	}
+
+	orders := output["orders"]
+	if orders["renamed_from"] != "purchases" {
+		t.Errorf("renamed_from = %v, want purchases", orders["renamed_from"])
+	}
+
+	// The limit applies to each kind of change
+	if inserted, _ := orders["inserted"].([]any); len(inserted) != 1 {
+		t.Errorf("inserted = %v, want one row", orders["inserted"])
+	}
+
+	updated, _ := orders["updated"].([]any)
+	if len(updated) != 1 {
+		t.Fatalf("updated = %v, want one row", orders["updated"])
+	}
+
+	// An added element has no before value
+	changes := updated[0].(map[string]any)["changes"].([]any)
+	change := changes[0].(map[string]any)
+	if _, ok := change["before"]; ok || change["path"] != "tags[]" || change["after"] != "b" {
+		t.Errorf("change = %v, want an added element", change)
+	}
+
+	// A rekeyed row without changes lists none
+	rekeyed, _ := orders["rekeyed"].([]any)
+	if len(rekeyed) != 1 {
+		t.Fatalf("rekeyed = %v, want one row", orders["rekeyed"])
+	}
+	if _, ok := rekeyed[0].(map[string]any)["changes"]; ok {
+		t.Errorf("rekeyed row = %v, want no changes", rekeyed[0])
+	}
+}
+
+func TestFormatChanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes []diff.Change
+		want    string
+	}{
+		{name: "value", changes: []diff.Change{{Path: "payload.items[3].qty", Before: 1.0, After: 2.0}}, want: "payload.items[3].qty: 1 → 2"},
+		{name: "to NULL", changes: []diff.Change{{Path: "email", Before: "a@example.com"}}, want: "email: a@example.com → NULL"},
+		{name: "added", changes: []diff.Change{{Path: "tags[]", After: "b", Added: true}}, want: "tags[]: + b"},
+		{name: "removed", changes: []diff.Change{{Path: "tags[]", Before: "a", Removed: true}}, want: "tags[]: - a"},
+		{
+			name:    "several",
+			changes: []diff.Change{{Path: "status", Before: "new", After: "paid"}, {Path: "meta", Before: nil, After: map[string]any{"a": 1.0}}},
+			want:    `status: new → paid, meta: NULL → {"a":1}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatChanges(tt.changes); got != tt.want {
+				t.Errorf("formatChanges = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{value: nil, want: "NULL"},
+		{value: 1e21, want: "1000000000000000000000"},
+		{value: 0.5, want: "0.5"},
+		{value: "text", want: "text"},
+		{value: []any{1.0, "a"}, want: `[1,"a"]`},
+		{value: true, want: "true"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatValue(tt.value); got != tt.want {
+			t.Errorf("FormatValue(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFormatCount(t *testing.T) {
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{n: 0, want: "0"},
+		{n: 999, want: "999"},
+		{n: 4211, want: "4,211"},
+		{n: 1234567, want: "1,234,567"},
+		{n: -4211, want: "-4,211"},
+	}
+
+	for _, tt := range tests {
+		if got := formatCount(tt.n); got != tt.want {
+			t.Errorf("formatCount(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestFormatSummaryCLI(t *testing.T) {
+	summary := &diff.Summary{Tables: []diff.TableSummary{{
+		TableName: "orders",
+		Updated:   4211,
+		Rekeyed:   1,
+		Columns: []diff.ColumnSummary{{
+			Column:      "status",
+			Rows:        4211,
+			Transitions: []diff.Transition{{Before: "pending", After: "shipped", Count: 4200}},
+			Distinct:    3,
+			ValueToNull: 11,
+			Delta:       &diff.Delta{Min: -1, Max: 2.5, Avg: 0.5},
+		}},
+	}}}
+
+	var buf bytes.Buffer
+	if err := formatSummaryCLI(&buf, summary); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"📄 Table: orders\n",
+		"Inserted: 0, Updated: 4,211, Deleted: 0, Rekeyed: 1\n",
+		"~ status: 4,211 rows\n",
+		"pending → shipped ×4,200\n",
+		"... and 2 more\n",
+		"value → NULL ×11\n",
+		"Δ min -1, max 2.5, avg 0.5\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, buf.String())
+		}
+	}
+}
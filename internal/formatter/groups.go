@@ -0,0 +1,265 @@
+package formatter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rom8726/snapdiff/internal/diff"
+)
+
+// formatGrouped formats the diff result as aggregates of related changes
+func formatGrouped(w io.Writer, result *diff.Result, opts Options) error {
+	aggregates := result.GroupByForeignKeys()
+
+	switch opts.Format {
+	case FormatCLI:
+		return formatGroupedCLI(w, result, aggregates, opts)
+	case FormatYAML:
+		data, err := yaml.Marshal(groupedOutput(result, aggregates, opts))
+		if err != nil {
+			return fmt.Errorf("failed to marshal YAML: %w", err)
+		}
+
+		_, err = w.Write(data)
+
+		return err
+	case FormatMarkdown:
+		return formatGroupedMarkdown(w, result, aggregates, opts)
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(groupedOutput(result, aggregates, opts)); err != nil {
+			return fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("unsupported format: %s", opts.Format)
+	}
+}
+
+// formatGroupedCLI formats aggregates of related changes in CLI format
+func formatGroupedCLI(w io.Writer, result *diff.Result, aggregates []diff.Aggregate, opts Options) error {
+	shown := aggregates
+	if opts.Limit > 0 && len(shown) > opts.Limit {
+		shown = shown[:opts.Limit]
+	}
+
+	for _, aggregate := range shown {
+		_, _ = fmt.Fprintf(w, "📦 %s\n", aggregateTitle(aggregate, opts.SortKeys))
+
+		for _, change := range aggregate.Changes {
+			indent := strings.Repeat("  ", change.Depth)
+			_, _ = fmt.Fprintf(w, "    %s%s\n", indent, formatRowChange(change, opts.SortKeys))
+		}
+
+		_, _ = fmt.Fprintln(w)
+	}
+
+	if opts.Limit > 0 && len(aggregates) > opts.Limit {
+		_, _ = fmt.Fprintf(w, "... and %d more\n\n", len(aggregates)-opts.Limit)
+	}
+
+	for _, tableName := range getSortedTableNames(result) {
+		refs := result.Tables[tableName].Dangling
+		if len(refs) == 0 {
+			continue
+		}
+
+		_, _ = fmt.Fprintf(w, "⚠️  Dangling references in %s: %d\n", tableName, len(refs))
+		formatDanglingCLI(w, refs, opts)
+		_, _ = fmt.Fprintln(w)
+	}
+
+	return nil
+}
+
+// formatGroupedMarkdown formats aggregates of related changes in Markdown format
+func formatGroupedMarkdown(w io.Writer, result *diff.Result, aggregates []diff.Aggregate, opts Options) error {
+	shown := aggregates
+	if opts.Limit > 0 && len(shown) > opts.Limit {
+		shown = shown[:opts.Limit]
+	}
+
+	for _, aggregate := range shown {
+		_, _ = fmt.Fprintf(w, "## 📦 %s\n\n", aggregateTitle(aggregate, opts.SortKeys))
+
+		for _, change := range aggregate.Changes {
+			indent := strings.Repeat("  ", change.Depth)
+			_, _ = fmt.Fprintf(w, "%s- %s\n", indent, formatRowChange(change, opts.SortKeys))
+		}
+
+		_, _ = fmt.Fprintln(w)
+	}
+
+	if opts.Limit > 0 && len(aggregates) > opts.Limit {
+		_, _ = fmt.Fprintf(w, "_... and %d more_\n\n", len(aggregates)-opts.Limit)
+	}
+
+	for _, tableName := range getSortedTableNames(result) {
+		refs := result.Tables[tableName].Dangling
+		if len(refs) == 0 {
+			continue
+		}
+
+		_, _ = fmt.Fprintf(w, "## ⚠️ Dangling references in %s (%d rows)\n", tableName, len(refs))
+		formatDanglingMarkdown(w, refs, opts)
+	}
+
+	return nil
+}
+
+// groupedOutput converts aggregates of related changes for YAML and JSON output
+func groupedOutput(result *diff.Result, aggregates []diff.Aggregate, opts Options) map[string]any {
+	if opts.Limit > 0 && len(aggregates) > opts.Limit {
+		aggregates = aggregates[:opts.Limit]
+	}
+
+	aggregatesOutput := make([]map[string]any, 0, len(aggregates))
+	for _, aggregate := range aggregates {
+		changes := make([]map[string]any, 0, len(aggregate.Changes))
+		for _, change := range aggregate.Changes {
+			item := map[string]any{
+				"table": change.Table,
+				"kind":  string(change.Kind),
+				"key":   change.Key,
+				"depth": change.Depth,
+			}
+
+			switch change.Kind {
+			case diff.ChangeInserted, diff.ChangeDeleted:
+				item["row"] = change.Row
+			case diff.ChangeRekeyed:
+				item["old_key"] = change.OldKey
+				if len(change.Changes) > 0 {
+					item["changes"] = changesOutput(change.Changes)
+				}
+			default:
+				item["changes"] = changesOutput(change.Changes)
+			}
+
+			changes = append(changes, item)
+		}
+
+		aggregatesOutput = append(aggregatesOutput, map[string]any{
+			"table":   aggregate.Table,
+			"key":     aggregate.Key,
+			"changed": aggregate.Changed,
+			"changes": changes,
+		})
+	}
+
+	output := map[string]any{"aggregates": aggregatesOutput}
+
+	dangling := make(map[string]any)
+	for _, tableName := range getSortedTableNames(result) {
+		if refs := result.Tables[tableName].Dangling; len(refs) > 0 {
+			dangling[tableName] = danglingOutput(refs, opts)
+		}
+	}
+	if len(dangling) > 0 {
+		output["dangling"] = dangling
+	}
+
+	return output
+}
+
+// aggregateTitle returns the table and key of the root row of an aggregate
+func aggregateTitle(aggregate diff.Aggregate, sortKeys bool) string {
+	title := fmt.Sprintf("%s %s", aggregate.Table, formatRow(aggregate.Key, sortKeys))
+	if !aggregate.Changed {
+		title += " (unchanged)"
+	}
+
+	return title
+}
+
+// formatRowChange formats a changed row of an aggregate
+func formatRowChange(change diff.RowChange, sortKeys bool) string {
+	switch change.Kind {
+	case diff.ChangeInserted:
+		return fmt.Sprintf("+ %s %s", change.Table, formatRow(change.Row, sortKeys))
+	case diff.ChangeDeleted:
+		return fmt.Sprintf("- %s %s", change.Table, formatRow(change.Row, sortKeys))
+	case diff.ChangeRekeyed:
+		text := fmt.Sprintf("↪ %s %s → %s", change.Table, formatRow(change.OldKey, sortKeys), formatRow(change.Key, sortKeys))
+		if len(change.Changes) > 0 {
+			text += ", " + formatChanges(change.Changes)
+		}
+
+		return text
+	default:
+		return fmt.Sprintf("~ %s %s, %s", change.Table, formatRow(change.Key, sortKeys), formatChanges(change.Changes))
+	}
+}
+
+// formatDanglingCLI lists dangling references in CLI format
+func formatDanglingCLI(w io.Writer, refs []diff.DanglingReference, opts Options) {
+	shown := refs
+	if opts.Limit > 0 && len(shown) > opts.Limit {
+		shown = shown[:opts.Limit]
+	}
+
+	for _, ref := range shown {
+		_, _ = fmt.Fprintf(w, "      ! %s → %s\n", formatRow(ref.Key, opts.SortKeys), danglingTarget(ref))
+	}
+
+	if opts.Limit > 0 && len(refs) > opts.Limit {
+		_, _ = fmt.Fprintf(w, "      ... and %d more\n", len(refs)-opts.Limit)
+	}
+}
+
+// formatDanglingMarkdown lists dangling references as a Markdown table
+func formatDanglingMarkdown(w io.Writer, refs []diff.DanglingReference, opts Options) {
+	shown := refs
+	if opts.Limit > 0 && len(shown) > opts.Limit {
+		shown = shown[:opts.Limit]
+	}
+
+	_, _ = fmt.Fprintln(w, "| Row | Missing row |")
+	_, _ = fmt.Fprintln(w, "|-----|-----|")
+
+	for _, ref := range shown {
+		_, _ = fmt.Fprintf(w, "| %s | %s |\n", formatRow(ref.Key, opts.SortKeys), danglingTarget(ref))
+	}
+
+	if opts.Limit > 0 && len(refs) > opts.Limit {
+		_, _ = fmt.Fprintf(w, "\n_... and %d more rows_\n", len(refs)-opts.Limit)
+	}
+
+	_, _ = fmt.Fprintln(w)
+}
+
+// danglingOutput converts dangling references for YAML and JSON output
+func danglingOutput(refs []diff.DanglingReference, opts Options) []map[string]any {
+	if opts.Limit > 0 && len(refs) > opts.Limit {
+		refs = refs[:opts.Limit]
+	}
+
+	output := make([]map[string]any, 0, len(refs))
+	for _, ref := range refs {
+		output = append(output, map[string]any{
+			"key":         ref.Key,
+			"columns":     ref.Columns,
+			"values":      ref.Values,
+			"ref_table":   ref.RefTable,
+			"ref_columns": ref.RefColumns,
+		})
+	}
+
+	return output
+}
+
+// danglingTarget formats the missing row a dangling reference points to, e.g. orders(id) = 77
+func danglingTarget(ref diff.DanglingReference) string {
+	values := make([]string, 0, len(ref.Values))
+	for _, value := range ref.Values {
+		values = append(values, FormatValue(value))
+	}
+
+	return fmt.Sprintf("%s(%s) = %s", ref.RefTable, strings.Join(ref.RefColumns, ", "), strings.Join(values, ", "))
+}
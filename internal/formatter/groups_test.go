@@ -0,0 +1,161 @@
+package formatter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/rom8726/snapdiff/internal/diff"
+)
+
+func TestFormatRowChange(t *testing.T) {
+	tests := []struct {
+		name   string
+		change diff.RowChange
+		want   string
+	}{
+		{
+			name:   "inserted",
+			change: diff.RowChange{Table: "items", Kind: diff.ChangeInserted, Row: map[string]any{"id": 7.0, "order_id": 1.0}},
+			want:   "+ items id: 7, order_id: 1",
+		},
+		{
+			name:   "deleted",
+			change: diff.RowChange{Table: "items", Kind: diff.ChangeDeleted, Row: map[string]any{"id": 7.0}},
+			want:   "- items id: 7",
+		},
+		{
+			name: "updated",
+			change: diff.RowChange{
+				Table:   "orders",
+				Kind:    diff.ChangeUpdated,
+				Key:     map[string]any{"id": 1.0},
+				Changes: []diff.Change{{Path: "status", Before: "new", After: "paid"}},
+			},
+			want: "~ orders id: 1, status: new → paid",
+		},
+		{
+			name:   "rekeyed",
+			change: diff.RowChange{Table: "orders", Kind: diff.ChangeRekeyed, OldKey: map[string]any{"id": 2.0}, Key: map[string]any{"id": 20.0}},
+			want:   "↪ orders id: 2 → id: 20",
+		},
+		{
+			name: "rekeyed and updated",
+			change: diff.RowChange{
+				Table:   "orders",
+				Kind:    diff.ChangeRekeyed,
+				OldKey:  map[string]any{"id": 2.0},
+				Key:     map[string]any{"id": 20.0},
+				Changes: []diff.Change{{Path: "status", Before: "new", After: "paid"}},
+			},
+			want: "↪ orders id: 2 → id: 20, status: new → paid",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatRowChange(tt.change, true); got != tt.want {
+				t.Errorf("formatRowChange = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDanglingTarget(t *testing.T) {
+	tests := []struct {
+		name string
+		ref  diff.DanglingReference
+		want string
+	}{
+		{
+			name: "single column",
+			ref:  diff.DanglingReference{Values: []any{77.0}, RefTable: "orders", RefColumns: []string{"id"}},
+			want: "orders(id) = 77",
+		},
+		{
+			name: "composite",
+			ref:  diff.DanglingReference{Values: []any{1.0, "eu"}, RefTable: "orgs", RefColumns: []string{"id", "region"}},
+			want: "orgs(id, region) = 1, eu",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := danglingTarget(tt.ref); got != tt.want {
+				t.Errorf("danglingTarget = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatGroupedCLI(t *testing.T) {
+	result := &diff.Result{Tables: map[string]*diff.TableDiff{
+		"items": {
+			TableName: "items",
+			Dangling: []diff.DanglingReference{
+				{Key: map[string]any{"id": 8.0}, Values: []any{77.0}, RefTable: "orders", RefColumns: []string{"id"}},
+				{Key: map[string]any{"id": 9.0}, Values: []any{78.0}, RefTable: "orders", RefColumns: []string{"id"}},
+			},
+		},
+	}}
+
+	aggregates := []diff.Aggregate{
+		{
+			Table:   "orders",
+			Key:     map[string]any{"id": 1.0},
+			Changed: true,
+			Changes: []diff.RowChange{
+				{Table: "orders", Kind: diff.ChangeUpdated, Key: map[string]any{"id": 1.0}, Changes: []diff.Change{{Path: "total", Before: 10.0, After: 15.0}}},
+				{Table: "items", Kind: diff.ChangeInserted, Row: map[string]any{"id": 7.0}, Depth: 1},
+			},
+		},
+		{
+			Table:   "orders",
+			Key:     map[string]any{"id": 2.0},
+			Changes: []diff.RowChange{{Table: "items", Kind: diff.ChangeDeleted, Row: map[string]any{"id": 6.0}, Depth: 1}},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		limit   int
+		want    []string
+		wantNot []string
+	}{
+		{
+			name: "all",
+			want: []string{
+				"📦 orders id: 1\n    ~ orders id: 1, total: 10 → 15\n      + items id: 7\n",
+				"📦 orders id: 2 (unchanged)\n      - items id: 6\n",
+				"⚠️  Dangling references in items: 2\n      ! id: 8 → orders(id) = 77\n      ! id: 9 → orders(id) = 78\n",
+			},
+		},
+		{
+			name:    "limited",
+			limit:   1,
+			want:    []string{"📦 orders id: 1\n", "... and 1 more\n", "      ! id: 8 → orders(id) = 77\n      ... and 1 more\n"},
+			wantNot: []string{"orders id: 2", "id: 9"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := formatGroupedCLI(&buf, result, aggregates, Options{Limit: tt.limit, SortKeys: true}); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("output does not contain %q:\n%s", want, buf.String())
+				}
+			}
+
+			for _, unwanted := range tt.wantNot {
+				if strings.Contains(buf.String(), unwanted) {
+					t.Errorf("output contains %q:\n%s", unwanted, buf.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package formatter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rom8726/snapdiff/internal/diff"
+)
+
+// FormatHistory formats the history of a row according to the specified format
+func FormatHistory(history *diff.History, opts Options) error {
+	writer, closeFn, err := openOutput(opts.OutputFile)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	switch opts.Format {
+	case FormatCLI:
+		return formatHistoryCLI(writer, history, opts)
+	case FormatYAML:
+		data, err := yaml.Marshal(historyOutput(history))
+		if err != nil {
+			return fmt.Errorf("failed to marshal YAML: %w", err)
+		}
+
+		_, err = writer.Write(data)
+
+		return err
+	case FormatMarkdown:
+		return formatHistoryMarkdown(writer, history, opts)
+	case FormatJSON:
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(historyOutput(history)); err != nil {
+			return fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("unsupported format: %s", opts.Format)
+	}
+}
+
+// formatHistoryCLI formats the history of a row in CLI format
+func formatHistoryCLI(w io.Writer, history *diff.History, opts Options) error {
+	_, _ = fmt.Fprintf(w, "📜 %s %s\n", history.Table, formatRow(history.Key, true))
+
+	width := 0
+	for _, step := range history.Steps {
+		width = max(width, len(step.Label))
+	}
+
+	for i, step := range history.Steps {
+		_, _ = fmt.Fprintf(w, "  %-*s  %s  %s\n",
+			width, step.Label, step.CreatedAt.Local().Format(time.DateTime), formatHistoryStep(step, i == 0, opts.SortKeys))
+	}
+
+	return nil
+}
+
+// formatHistoryMarkdown formats the history of a row as a Markdown table
+func formatHistoryMarkdown(w io.Writer, history *diff.History, opts Options) error {
+	_, _ = fmt.Fprintf(w, "## 📜 %s %s\n\n", history.Table, formatRow(history.Key, true))
+	_, _ = fmt.Fprintln(w, "| Snapshot | Created | Change |")
+	_, _ = fmt.Fprintln(w, "|-----|-----|-----|")
+
+	for i, step := range history.Steps {
+		_, _ = fmt.Fprintf(w, "| %s | %s | %s |\n",
+			step.Label, step.CreatedAt.Local().Format(time.DateTime), formatHistoryStep(step, i == 0, opts.SortKeys))
+	}
+
+	_, _ = fmt.Fprintln(w)
+
+	return nil
+}
+
+// formatHistoryStep formats the state of a row in a snapshot and its change
+// since the previous snapshot
+func formatHistoryStep(step diff.HistoryStep, first, sortKeys bool) string {
+	switch {
+	case step.Kind == diff.ChangeInserted:
+		return "+ " + formatRow(step.Row, sortKeys)
+	case step.Kind == diff.ChangeDeleted:
+		return "- deleted"
+	case step.Kind == diff.ChangeUpdated:
+		return "~ " + formatChanges(step.Changes)
+	case !step.Present:
+		return "(absent)"
+	case first:
+		return "= " + formatRow(step.Row, sortKeys)
+	default:
+		return "(unchanged)"
+	}
+}
+
+// historyOutput converts the history of a row for YAML and JSON output
+func historyOutput(history *diff.History) map[string]any {
+	steps := make([]map[string]any, 0, len(history.Steps))
+	for _, step := range history.Steps {
+		item := map[string]any{
+			"label":      step.Label,
+			"created_at": step.CreatedAt,
+			"present":    step.Present,
+		}
+
+		if step.Kind != "" {
+			item["kind"] = string(step.Kind)
+		}
+		if step.Row != nil {
+			item["row"] = step.Row
+		}
+		if len(step.Changes) > 0 {
+			item["changes"] = changesOutput(step.Changes)
+		}
+
+		steps = append(steps, item)
+	}
+
+	return map[string]any{
+		"table": history.Table,
+		"key":   history.Key,
+		"steps": steps,
+	}
+}
@@ -0,0 +1,112 @@
+package formatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rom8726/snapdiff/internal/diff"
+)
+
+// testHistory returns the history of an order that is created, paid and deleted
+func testHistory() *diff.History {
+	at := func(day int) time.Time {
+		return time.Date(2026, 3, day, 12, 0, 0, 0, time.Local)
+	}
+
+	return &diff.History{
+		Table: "orders",
+		Key:   map[string]any{"id": 1.0},
+		Steps: []diff.HistoryStep{
+			{Label: "d1", CreatedAt: at(1)},
+			{Label: "d2", CreatedAt: at(2), Present: true, Kind: diff.ChangeInserted, Row: map[string]any{"id": 1.0, "status": "new"}},
+			{Label: "d3", CreatedAt: at(3), Present: true, Row: map[string]any{"id": 1.0, "status": "new"}},
+			{
+				Label:     "d4",
+				CreatedAt: at(4),
+				Present:   true,
+				Kind:      diff.ChangeUpdated,
+				Row:       map[string]any{"id": 1.0, "status": "paid"},
+				Changes:   []diff.Change{{Path: "status", Before: "new", After: "paid"}},
+			},
+			{Label: "deleted", CreatedAt: at(5), Kind: diff.ChangeDeleted},
+		},
+	}
+}
+
+func TestFormatHistoryStep(t *testing.T) {
+	steps := testHistory().Steps
+	present := diff.HistoryStep{Present: true, Row: map[string]any{"id": 1.0, "status": "new"}}
+
+	tests := []struct {
+		name  string
+		step  diff.HistoryStep
+		first bool
+		want  string
+	}{
+		{name: "absent", step: steps[0], first: true, want: "(absent)"},
+		{name: "inserted", step: steps[1], want: "+ id: 1, status: new"},
+		{name: "unchanged", step: steps[2], want: "(unchanged)"},
+		{name: "updated", step: steps[3], want: "~ status: new → paid"},
+		{name: "deleted", step: steps[4], want: "- deleted"},
+		{name: "present in the first snapshot", step: present, first: true, want: "= id: 1, status: new"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatHistoryStep(tt.step, tt.first, true); got != tt.want {
+				t.Errorf("formatHistoryStep = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatHistoryCLI(t *testing.T) {
+	var buf bytes.Buffer
+	if err := formatHistoryCLI(&buf, testHistory(), Options{SortKeys: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Labels are padded to the longest one
+	want := "📜 orders id: 1\n" +
+		"  d1       2026-03-01 12:00:00  (absent)\n" +
+		"  d2       2026-03-02 12:00:00  + id: 1, status: new\n" +
+		"  d3       2026-03-03 12:00:00  (unchanged)\n" +
+		"  d4       2026-03-04 12:00:00  ~ status: new → paid\n" +
+		"  deleted  2026-03-05 12:00:00  - deleted\n"
+
+	if buf.String() != want {
+		t.Errorf("output =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestHistoryOutput(t *testing.T) {
+	data, err := json.Marshal(historyOutput(testHistory()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var output struct {
+		Table string
+		Steps []map[string]any
+	}
+	if err := json.Unmarshal(data, &output); err != nil {
+		t.Fatal(err)
+	}
+
+	if output.Table != "orders" || len(output.Steps) != 5 {
+		t.Fatalf("output = %s, want 5 steps of orders", data)
+	}
+
+	// Only the steps with a change have a kind, and only the updated one has changes
+	for i, step := range output.Steps {
+		_, hasKind := step["kind"]
+		_, hasChanges := step["changes"]
+		_, hasRow := step["row"]
+
+		if hasKind != (i != 0 && i != 2) || hasChanges != (i == 3) || hasRow != (i >= 1 && i <= 3) {
+			t.Errorf("step %d = %v", i, step)
+		}
+	}
+}
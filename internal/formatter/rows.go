@@ -0,0 +1,157 @@
+package formatter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FormatRows formats table rows according to the specified format. The
+// columns are printed in the given order; if empty, all columns of the
+// rows are printed sorted by name.
+func FormatRows(columns []string, rows []map[string]any, opts Options) error {
+	writer, closeFn, err := openOutput(opts.OutputFile)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	if len(columns) == 0 {
+		columns = getAllColumnNames(rows)
+	}
+
+	if opts.Limit > 0 && len(rows) > opts.Limit {
+		rows = rows[:opts.Limit]
+	}
+
+	switch opts.Format {
+	case FormatCLI:
+		return formatRowsCLI(writer, columns, rows)
+	case FormatYAML:
+		return formatRowsYAML(writer, columns, rows)
+	case FormatMarkdown:
+		return formatRowsMarkdown(writer, columns, rows)
+	case FormatJSON:
+		return formatRowsJSON(writer, columns, rows)
+	default:
+		return fmt.Errorf("unsupported format: %s", opts.Format)
+	}
+}
+
+// formatRowsCLI formats rows as an aligned text table
+func formatRowsCLI(w io.Writer, columns []string, rows []map[string]any) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	_, _ = fmt.Fprintln(tw, strings.Join(columns, "\t"))
+
+	for _, row := range rows {
+		values := make([]string, 0, len(columns))
+		for _, col := range columns {
+			values = append(values, FormatValue(row[col]))
+		}
+		_, _ = fmt.Fprintln(tw, strings.Join(values, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+// formatRowsYAML formats rows as a YAML list, keeping the column order
+func formatRowsYAML(w io.Writer, columns []string, rows []map[string]any) error {
+	list := &yaml.Node{Kind: yaml.SequenceNode}
+
+	for _, row := range rows {
+		item := &yaml.Node{Kind: yaml.MappingNode}
+		for _, col := range columns {
+			var value yaml.Node
+			if err := value.Encode(yamlValue(row[col])); err != nil {
+				return fmt.Errorf("failed to marshal YAML: %w", err)
+			}
+
+			item.Content = append(item.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: col}, &value)
+		}
+		list.Content = append(list.Content, item)
+	}
+
+	data, err := yaml.Marshal(list)
+	if err != nil {
+		return fmt.Errorf("failed to marshal YAML: %w", err)
+	}
+
+	_, err = w.Write(data)
+
+	return err
+}
+
+// formatRowsMarkdown formats rows as a Markdown table
+func formatRowsMarkdown(w io.Writer, columns []string, rows []map[string]any) error {
+	_, _ = fmt.Fprintf(w, "| %s |\n", strings.Join(columns, " | "))
+	_, _ = fmt.Fprintf(w, "|%s\n", strings.Repeat("-----|", len(columns)))
+
+	for _, row := range rows {
+		values := make([]string, 0, len(columns))
+		for _, col := range columns {
+			values = append(values, strings.ReplaceAll(FormatValue(row[col]), "|", "\\|"))
+		}
+		_, _ = fmt.Fprintf(w, "| %s |\n", strings.Join(values, " | "))
+	}
+
+	return nil
+}
+
+// formatRowsJSON formats rows as a JSON array, keeping only the given columns
+func formatRowsJSON(w io.Writer, columns []string, rows []map[string]any) error {
+	projected := make([]map[string]any, 0, len(rows))
+	for _, row := range rows {
+		item := make(map[string]any, len(columns))
+		for _, col := range columns {
+			item[col] = row[col]
+		}
+		projected = append(projected, item)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(projected); err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	return nil
+}
+
+// yamlValue converts whole numbers decoded from JSON to integers, which
+// YAML would otherwise print with an exponent
+func yamlValue(v any) any {
+	if f, ok := v.(float64); ok && f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return int64(f)
+	}
+
+	return v
+}
+
+// FormatValue formats a single column value for display. NULL is shown as
+// NULL, and whole numbers decoded from JSON are shown without an exponent.
+func FormatValue(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return "NULL"
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case string:
+		return val
+	case map[string]any, []any:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprintf("%v", val)
+		}
+
+		return string(data)
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
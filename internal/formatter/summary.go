@@ -0,0 +1,192 @@
+package formatter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rom8726/snapdiff/internal/diff"
+)
+
+// FormatSummary formats a diff summary according to the specified format
+func FormatSummary(summary *diff.Summary, opts Options) error {
+	writer, closeFn, err := openOutput(opts.OutputFile)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	switch opts.Format {
+	case FormatCLI:
+		return formatSummaryCLI(writer, summary)
+	case FormatYAML:
+		return formatSummaryYAML(writer, summary)
+	case FormatMarkdown:
+		return formatSummaryMarkdown(writer, summary)
+	case FormatJSON:
+		return formatSummaryJSON(writer, summary)
+	default:
+		return fmt.Errorf("unsupported format: %s", opts.Format)
+	}
+}
+
+// formatSummaryCLI formats a diff summary in CLI format
+func formatSummaryCLI(w io.Writer, summary *diff.Summary) error {
+	for _, table := range summary.Tables {
+		_, _ = fmt.Fprintf(w, "📄 Table: %s\n", summaryTitle(table))
+		_, _ = fmt.Fprintf(w, "  %s\n", summaryCounts(table))
+
+		for _, col := range table.Columns {
+			_, _ = fmt.Fprintf(w, "  ~ %s: %s rows\n", col.Column, formatCount(col.Rows))
+
+			for _, t := range col.Transitions {
+				_, _ = fmt.Fprintf(w, "      %s\n", formatTransition(t))
+			}
+
+			if more := col.Distinct - len(col.Transitions); more > 0 {
+				_, _ = fmt.Fprintf(w, "      ... and %s more\n", formatCount(more))
+			}
+
+			if nulls := formatNulls(col); nulls != "" {
+				_, _ = fmt.Fprintf(w, "      %s\n", nulls)
+			}
+
+			if col.Delta != nil {
+				_, _ = fmt.Fprintf(w, "      %s\n", formatDelta(col.Delta))
+			}
+		}
+
+		_, _ = fmt.Fprintln(w)
+	}
+
+	return nil
+}
+
+// formatSummaryYAML formats a diff summary in YAML format
+func formatSummaryYAML(w io.Writer, summary *diff.Summary) error {
+	data, err := yaml.Marshal(summary)
+	if err != nil {
+		return fmt.Errorf("failed to marshal YAML: %w", err)
+	}
+
+	_, err = w.Write(data)
+
+	return err
+}
+
+// formatSummaryMarkdown formats a diff summary in Markdown format
+func formatSummaryMarkdown(w io.Writer, summary *diff.Summary) error {
+	for _, table := range summary.Tables {
+		_, _ = fmt.Fprintf(w, "## Table: %s\n\n", summaryTitle(table))
+		_, _ = fmt.Fprintf(w, "%s\n\n", summaryCounts(table))
+
+		if len(table.Columns) == 0 {
+			continue
+		}
+
+		_, _ = fmt.Fprintln(w, "| Column | Rows | Top changes | NULL changes | Numeric change |")
+		_, _ = fmt.Fprintln(w, "|-----|-----|-----|-----|-----|")
+
+		for _, col := range table.Columns {
+			transitions := make([]string, 0, len(col.Transitions)+1)
+			for _, t := range col.Transitions {
+				transitions = append(transitions, formatTransition(t))
+			}
+			if more := col.Distinct - len(col.Transitions); more > 0 {
+				transitions = append(transitions, fmt.Sprintf("_... and %s more_", formatCount(more)))
+			}
+
+			var delta string
+			if col.Delta != nil {
+				delta = formatDelta(col.Delta)
+			}
+
+			_, _ = fmt.Fprintf(w, "| %s | %s | %s | %s | %s |\n",
+				col.Column,
+				formatCount(col.Rows),
+				strings.ReplaceAll(strings.Join(transitions, "<br>"), "|", "\\|"),
+				formatNulls(col),
+				delta,
+			)
+		}
+
+		_, _ = fmt.Fprintln(w)
+	}
+
+	return nil
+}
+
+// formatSummaryJSON formats a diff summary in JSON format
+func formatSummaryJSON(w io.Writer, summary *diff.Summary) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(summary); err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	return nil
+}
+
+// summaryTitle returns the name of a summarized table, with its old name if it was renamed
+func summaryTitle(table diff.TableSummary) string {
+	if table.RenamedFrom != "" {
+		return fmt.Sprintf("%s (renamed from %s)", table.TableName, table.RenamedFrom)
+	}
+
+	return table.TableName
+}
+
+// summaryCounts formats the numbers of changed rows of a table
+func summaryCounts(table diff.TableSummary) string {
+	counts := fmt.Sprintf("Inserted: %s, Updated: %s, Deleted: %s",
+		formatCount(table.Inserted), formatCount(table.Updated), formatCount(table.Deleted))
+	if table.Rekeyed > 0 {
+		counts += ", Rekeyed: " + formatCount(table.Rekeyed)
+	}
+	if table.Dangling > 0 {
+		counts += ", Dangling references: " + formatCount(table.Dangling)
+	}
+
+	return counts
+}
+
+// formatTransition formats a value transition, e.g. "pending → shipped ×4,211"
+func formatTransition(t diff.Transition) string {
+	return fmt.Sprintf("%s → %s ×%s", FormatValue(t.Before), FormatValue(t.After), formatCount(t.Count))
+}
+
+// formatNulls formats the NULL transitions of a column, or "" if there are none
+func formatNulls(col diff.ColumnSummary) string {
+	var parts []string
+	if col.NullToValue > 0 {
+		parts = append(parts, "NULL → value ×"+formatCount(col.NullToValue))
+	}
+	if col.ValueToNull > 0 {
+		parts = append(parts, "value → NULL ×"+formatCount(col.ValueToNull))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// formatDelta formats the change of numeric values of a column
+func formatDelta(delta *diff.Delta) string {
+	return fmt.Sprintf("Δ min %s, max %s, avg %s", FormatValue(delta.Min), FormatValue(delta.Max), FormatValue(delta.Avg))
+}
+
+// formatCount formats a count with thousands separators, e.g. 4,211
+func formatCount(n int) string {
+	s := strconv.Itoa(n)
+	if n < 0 {
+		return "-" + formatCount(-n)
+	}
+
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+
+	return s
+}
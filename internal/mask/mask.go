@@ -0,0 +1,239 @@
+package mask
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy is the masking configuration recorded in a snapshot manifest
+type Policy struct {
+	Rules []Rule `json:"rules"`
+	KeyID string `json:"key_id,omitempty"` // Fingerprint of the masking key, empty if none was used
+}
+
+// Masker applies masking rules to captured rows
+type Masker struct {
+	rules []Rule
+	key   []byte
+}
+
+// New creates a masker. The key is required by hash and fake rules; snapshots
+// masked with the same key can be compared, since equal values mask equally.
+func New(rules []Rule, key []byte) (*Masker, error) {
+	for i, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("masking rule #%d: %w", i+1, err)
+		}
+
+		if rule.Action.keyed() && len(key) == 0 {
+			return nil, fmt.Errorf("masking rule #%d: a masking key is required for %s", i+1, rule.Action)
+		}
+	}
+
+	return &Masker{rules: rules, key: key}, nil
+}
+
+// Policy returns the policy to record in the manifest
+func (m *Masker) Policy() *Policy {
+	policy := &Policy{Rules: m.rules}
+	if len(m.key) > 0 {
+		policy.KeyID = KeyID(m.key)
+	}
+
+	return policy
+}
+
+// Columns returns the rule applied to each masked column of a table. The
+// first matching rule wins.
+func (m *Masker) Columns(table string, columns []string) map[string]Rule {
+	masked := make(map[string]Rule)
+	for _, col := range columns {
+		for _, rule := range m.rules {
+			if rule.Matches(table, col) {
+				masked[col] = rule
+				break
+			}
+		}
+	}
+
+	return masked
+}
+
+// Describe returns a description of how a rule masks values, which changes
+// whenever the masked values would change
+func (m *Masker) Describe(rule Rule) string {
+	desc := fmt.Sprintf("%s %q %d", rule.Action, rule.Value, rule.Length)
+	if rule.Action.keyed() {
+		desc += " " + KeyID(m.key)
+	}
+
+	return desc
+}
+
+// Apply masks the values of the given columns in place. NULL values are kept.
+func (m *Masker) Apply(rows []map[string]any, columns map[string]Rule) {
+	if len(columns) == 0 {
+		return
+	}
+
+	for _, row := range rows {
+		for col, rule := range columns {
+			if value, ok := row[col]; ok && value != nil {
+				row[col] = m.Value(rule, value)
+			}
+		}
+	}
+}
+
+// Value masks a single value according to a rule
+func (m *Masker) Value(rule Rule, value any) any {
+	switch rule.Action {
+	case ActionConstant:
+		return rule.Value
+	case ActionHash:
+		return hex.EncodeToString(m.mac([]byte(text(value)))[:16])
+	case ActionFake:
+		return m.fake(value)
+	case ActionTruncate:
+		runes := []rune(text(value))
+		if len(runes) > rule.Length {
+			runes = runes[:rule.Length]
+		}
+
+		return string(runes)
+	default:
+		return value
+	}
+}
+
+// KeyID returns a short fingerprint of a masking key
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+
+	return hex.EncodeToString(sum[:8])
+}
+
+// fake replaces every letter and digit with a pseudo-random one derived from
+// the key and the value. Numbers stay numbers of the same magnitude, except
+// that integers beyond the int64 range wrap around to stay integers.
+func (m *Masker) fake(value any) any {
+	switch v := value.(type) {
+	case int64:
+		faked := m.fakeText(strconv.FormatInt(v, 10), true)
+		digits, negative := strings.CutPrefix(faked, "-")
+
+		// At most 19 digits always fit in a uint64
+		u, _ := strconv.ParseUint(digits, 10, 64)
+		n := int64(u % (1 << 63))
+		if negative {
+			n = -n
+		}
+
+		return n
+	case float64:
+		faked := m.fakeText(strconv.FormatFloat(v, 'f', -1, 64), true)
+		if f, err := strconv.ParseFloat(faked, 64); err == nil {
+			return f
+		}
+
+		return faked
+	default:
+		return m.fakeText(text(value), false)
+	}
+}
+
+// fakeText replaces the letters and digits of s, keeping case and all other
+// characters. The leading digit of a number stays zero or non-zero, so that
+// the number keeps its magnitude.
+func (m *Masker) fakeText(s string, number bool) string {
+	stream := m.keystream(s)
+
+	var b strings.Builder
+	leading := true
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			switch {
+			case number && leading && r == '0':
+				b.WriteRune('0')
+			case number && leading:
+				b.WriteRune('1' + rune(stream()%9))
+			default:
+				b.WriteRune('0' + rune(stream()%10))
+			}
+			leading = false
+		case r >= 'a' && r <= 'z':
+			b.WriteRune('a' + rune(stream()%26))
+		case r >= 'A' && r <= 'Z':
+			b.WriteRune('A' + rune(stream()%26))
+		default:
+			b.WriteRune(r)
+			leading = leading && r == '-'
+		}
+	}
+
+	return b.String()
+}
+
+// keystream returns a deterministic source of pseudo-random bytes for a value
+func (m *Masker) keystream(s string) func() byte {
+	seed := m.mac([]byte(s))
+
+	var block []byte
+	var counter uint64
+
+	return func() byte {
+		if len(block) == 0 {
+			var ctr [8]byte
+			binary.BigEndian.PutUint64(ctr[:], counter)
+			counter++
+
+			block = m.mac(append(append([]byte{}, seed...), ctr[:]...))
+		}
+
+		b := block[0]
+		block = block[1:]
+
+		return b
+	}
+}
+
+// mac returns the HMAC-SHA256 of data under the masking key
+func (m *Masker) mac(data []byte) []byte {
+	h := hmac.New(sha256.New, m.key)
+	h.Write(data)
+
+	return h.Sum(nil)
+}
+
+// text returns the text a value is masked as, which matches its JSON
+// representation in a snapshot
+func text(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+
+		return string(data)
+	}
+}
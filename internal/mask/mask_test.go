@@ -0,0 +1,158 @@
+package mask
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    Rule
+		key     []byte
+		wantErr string
+	}{
+		{name: "constant", rule: Rule{Column: "email", Action: ActionConstant, Value: "x"}},
+		{name: "hash with key", rule: Rule{Column: "email", Action: ActionHash}, key: []byte("k")},
+		{name: "hash without key", rule: Rule{Column: "email", Action: ActionHash}, wantErr: "masking key is required"},
+		{name: "fake without key", rule: Rule{Column: "email", Action: ActionFake}, wantErr: "masking key is required"},
+		{name: "truncate", rule: Rule{Column: "name", Action: ActionTruncate, Length: 1}},
+		{name: "truncate without length", rule: Rule{Column: "name", Action: ActionTruncate}, wantErr: "length must be positive"},
+		{name: "truncate with negative length", rule: Rule{Column: "name", Action: ActionTruncate, Length: -1}, wantErr: "length must be positive"},
+		{name: "no column", rule: Rule{Action: ActionConstant}, wantErr: "column is required"},
+		{name: "no action", rule: Rule{Column: "email"}, wantErr: "action is required"},
+		{name: "unknown action", rule: Rule{Column: "email", Action: "drop"}, wantErr: "unknown masking action"},
+		{name: "invalid pattern", rule: Rule{Column: "[", Action: ActionConstant}, wantErr: "invalid pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New([]Rule{tt.rule}, tt.key)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestColumns(t *testing.T) {
+	masker, err := New([]Rule{
+		{Table: "users", Column: "email", Action: ActionConstant, Value: "hidden"},
+		{Column: "*_token", Action: ActionConstant},
+		{Column: "email", Action: ActionTruncate, Length: 3},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := masker.Columns("users", []string{"id", "email", "api_token"})
+	want := map[string]Rule{
+		"email":     {Table: "users", Column: "email", Action: ActionConstant, Value: "hidden"},
+		"api_token": {Column: "*_token", Action: ActionConstant},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("columns of users = %v, want %v", got, want)
+	}
+
+	if got := masker.Columns("orders", []string{"email"})["email"].Action; got != ActionTruncate {
+		t.Errorf("rule of orders.email = %s, want %s", got, ActionTruncate)
+	}
+}
+
+func TestValue(t *testing.T) {
+	masker, err := New(nil, []byte("key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := New(nil, []byte("other key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash := Rule{Column: "c", Action: ActionHash}
+	fake := Rule{Column: "c", Action: ActionFake}
+
+	tests := []struct {
+		name  string
+		rule  Rule
+		value any
+		want  any
+	}{
+		{name: "constant", rule: Rule{Action: ActionConstant, Value: "x"}, value: "secret", want: "x"},
+		{name: "truncate", rule: Rule{Action: ActionTruncate, Length: 2}, value: "Ünïcode", want: "Ün"},
+		{name: "truncate short value", rule: Rule{Action: ActionTruncate, Length: 10}, value: "abc", want: "abc"},
+		{name: "truncate number", rule: Rule{Action: ActionTruncate, Length: 2}, value: int64(12345), want: "12"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := masker.Value(tt.rule, tt.value); got != tt.want {
+				t.Errorf("masked value = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("hash", func(t *testing.T) {
+		a := masker.Value(hash, "alice@example.com")
+		if a != masker.Value(hash, "alice@example.com") {
+			t.Errorf("equal values hash differently")
+		}
+		if a == masker.Value(hash, "bob@example.com") {
+			t.Errorf("different values hash equally")
+		}
+		if a == other.Value(hash, "alice@example.com") {
+			t.Errorf("different keys hash equally")
+		}
+	})
+
+	t.Run("fake", func(t *testing.T) {
+		email, ok := masker.Value(fake, "Alice.Smith@example.com").(string)
+		if !ok || len(email) != len("Alice.Smith@example.com") || email[5] != '.' || email[11] != '@' {
+			t.Errorf("faked email %q does not keep length and punctuation", email)
+		}
+		if email == "Alice.Smith@example.com" || email != masker.Value(fake, "Alice.Smith@example.com") {
+			t.Errorf("faked email %q is not a deterministic replacement", email)
+		}
+
+		n, ok := masker.Value(fake, int64(4200)).(int64)
+		if !ok || n < 1000 || n > 9999 {
+			t.Errorf("faked number %v does not keep its type and magnitude", n)
+		}
+
+		// Numbers near the int64 limits stay int64 of the same sign
+		for i := range int64(100) {
+			for _, v := range []int64{math.MaxInt64 - i, math.MinInt64 + i} {
+				faked, ok := masker.Value(fake, v).(int64)
+				if !ok || (faked < 0) != (v < 0) {
+					t.Errorf("faked %d = %#v, want an int64 of the same sign", v, masker.Value(fake, v))
+				}
+			}
+		}
+	})
+}
+
+func TestApplyKeepsNull(t *testing.T) {
+	masker, err := New([]Rule{{Column: "email", Action: ActionConstant, Value: "x"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := []map[string]any{{"id": 1, "email": nil}, {"id": 2, "email": "a@example.com"}}
+	masker.Apply(rows, masker.Columns("users", []string{"id", "email"}))
+
+	want := []map[string]any{{"id": 1, "email": nil}, {"id": 2, "email": "x"}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %v, want %v", rows, want)
+	}
+}
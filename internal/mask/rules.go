@@ -0,0 +1,100 @@
+package mask
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Action represents how a rule masks a value
+type Action string
+
+const (
+	ActionConstant Action = "constant" // Replace with a fixed value
+	ActionHash     Action = "hash"     // Replace with a keyed hash, so equal values stay equal
+	ActionFake     Action = "fake"     // Replace letters and digits, keeping length, case and punctuation
+	ActionTruncate Action = "truncate" // Keep only the first characters
+)
+
+// Rule masks the columns matching a table and column pattern. Patterns use
+// path.Match syntax, e.g. "*_token"; an empty table pattern matches every table.
+type Rule struct {
+	Table  string `yaml:"table" json:"table,omitempty"`
+	Column string `yaml:"column" json:"column"`
+	Action Action `yaml:"action" json:"action"`
+	Value  string `yaml:"value" json:"value,omitempty"`   // Replacement for constant
+	Length int    `yaml:"length" json:"length,omitempty"` // Characters kept by truncate
+}
+
+// RuleSet is the top-level structure of a masking rules file
+type RuleSet struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// LoadRules reads and validates a masking rules file
+func LoadRules(path string) (*RuleSet, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read masking rules file: %w", err)
+	}
+
+	var ruleSet RuleSet
+	if err := yaml.Unmarshal(content, &ruleSet); err != nil {
+		return nil, fmt.Errorf("failed to parse masking rules file: %w", err)
+	}
+
+	for i := range ruleSet.Rules {
+		if err := ruleSet.Rules[i].validate(); err != nil {
+			return nil, fmt.Errorf("masking rule #%d: %w", i+1, err)
+		}
+	}
+
+	return &ruleSet, nil
+}
+
+// Matches reports whether the rule applies to a column of a table
+func (r Rule) Matches(table, column string) bool {
+	if r.Table != "" {
+		if ok, _ := path.Match(r.Table, table); !ok {
+			return false
+		}
+	}
+
+	ok, _ := path.Match(r.Column, column)
+
+	return ok
+}
+
+// keyed reports whether the action needs a masking key
+func (a Action) keyed() bool {
+	return a == ActionHash || a == ActionFake
+}
+
+// validate checks that the rule has all the fields its action requires
+func (r Rule) validate() error {
+	if r.Column == "" {
+		return fmt.Errorf("column is required")
+	}
+
+	for _, pattern := range []string{r.Table, r.Column} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	switch r.Action {
+	case ActionConstant, ActionHash, ActionFake:
+	case ActionTruncate:
+		if r.Length <= 0 {
+			return fmt.Errorf("length must be positive")
+		}
+	case "":
+		return fmt.Errorf("action is required")
+	default:
+		return fmt.Errorf("unknown masking action: %s", r.Action)
+	}
+
+	return nil
+}
@@ -0,0 +1,11 @@
+package query
+
+// Options contains configuration for the query command
+type Options struct {
+	Label    string // Snapshot whose tables are loaded into the main schema
+	From     string // Snapshot whose tables are loaded into the "before" schema
+	To       string // Snapshot whose tables are loaded into the "after" schema
+	SQL      string // Query to run
+	BaseDir  string // Base directory for snapshots
+	Identity string // age identity file for encrypted snapshots (default: $SNAPDIFF_IDENTITY)
+}
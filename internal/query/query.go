@@ -0,0 +1,297 @@
+package query
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	_ "modernc.org/sqlite" // SQLite driver
+
+	"github.com/rom8726/snapdiff/internal/storage"
+)
+
+const (
+	// FromSchema is the schema holding the tables of the --from snapshot
+	FromSchema = "before"
+	// ToSchema is the schema holding the tables of the --to snapshot
+	ToSchema = "after"
+)
+
+// Result contains the rows returned by a query
+type Result struct {
+	Columns []string
+	Rows    []map[string]any
+}
+
+// Run loads the snapshots into an in-memory SQLite database and runs the query
+func Run(ctx context.Context, opts Options) (*Result, error) {
+	if opts.Label == "" && opts.From == "" && opts.To == "" {
+		return nil, fmt.Errorf("no snapshot to query")
+	}
+
+	store, err := storage.NewStorage(opts.BaseDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage: %w", err)
+	}
+
+	if err := store.UseIdentity(opts.Identity); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+	defer db.Close()
+
+	// Every connection to :memory: is a separate database
+	db.SetMaxOpenConns(1)
+
+	schemas := []struct{ name, label string }{
+		{"main", opts.Label},
+		{FromSchema, opts.From},
+		{ToSchema, opts.To},
+	}
+
+	for _, schema := range schemas {
+		if schema.label == "" {
+			continue
+		}
+
+		if schema.name != "main" {
+			if _, err := db.ExecContext(ctx, fmt.Sprintf("ATTACH DATABASE ':memory:' AS %s", schema.name)); err != nil {
+				return nil, fmt.Errorf("failed to attach schema %s: %w", schema.name, err)
+			}
+		}
+
+		if err := loadSnapshot(ctx, db, store, schema.name, schema.label); err != nil {
+			return nil, err
+		}
+	}
+
+	return runQuery(ctx, db, opts.SQL)
+}
+
+// loadSnapshot creates a table in the schema for every table of the snapshot
+func loadSnapshot(ctx context.Context, db *sql.DB, store *storage.Storage, schema, label string) error {
+	manifest, err := store.LoadManifest(ctx, label)
+	if err != nil {
+		return err
+	}
+
+	if !manifest.Complete {
+		return fmt.Errorf("snapshot %s is incomplete", label)
+	}
+
+	for _, table := range manifest.Tables {
+		rows, err := store.ReadTable(ctx, manifest, table.Name)
+		if err != nil {
+			return err
+		}
+
+		if err := loadTable(ctx, db, schema, table, rows); err != nil {
+			return fmt.Errorf("failed to load table %s of snapshot %s: %w", table.Name, label, err)
+		}
+	}
+
+	return nil
+}
+
+// loadTable creates and fills a table, typing its columns after the manifest
+func loadTable(ctx context.Context, db *sql.DB, schema string, table storage.TableInfo, rows []map[string]any) error {
+	columns := table.Columns
+	if len(columns) == 0 {
+		columns = columnsOf(rows)
+	}
+
+	if len(columns) == 0 {
+		return nil
+	}
+
+	defs := make([]string, 0, len(columns))
+	names := make([]string, 0, len(columns))
+	affinities := make([]string, 0, len(columns))
+	for _, col := range columns {
+		affinity := sqliteType(col.Type)
+		defs = append(defs, strings.TrimSpace(quoteIdent(col.Name)+" "+affinity))
+		names = append(names, quoteIdent(col.Name))
+		affinities = append(affinities, affinity)
+	}
+
+	qualified := schema + "." + quoteIdent(table.Name)
+
+	create := fmt.Sprintf("CREATE TABLE %s (%s)", qualified, strings.Join(defs, ", "))
+	if _, err := db.ExecContext(ctx, create); err != nil {
+		return fmt.Errorf("failed to create table: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		qualified, strings.Join(names, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", "))
+
+	stmt, err := tx.PrepareContext(ctx, insert)
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer stmt.Close()
+
+	args := make([]any, len(columns))
+	for _, row := range rows {
+		for i, col := range columns {
+			args[i] = sqliteValue(row[col.Name], affinities[i])
+		}
+
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return fmt.Errorf("failed to insert row: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// runQuery runs the query and collects its rows. Duplicate column names,
+// e.g. from joins, get a numeric suffix so that no value is lost.
+func runQuery(ctx context.Context, db *sql.DB, query string) (*Result, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run query: %w", err)
+	}
+	defer rows.Close()
+
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get result columns: %w", err)
+	}
+
+	result := &Result{Columns: uniqueNames(names)}
+
+	for rows.Next() {
+		values := make([]any, len(names))
+		valuePtrs := make([]any, len(names))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, fmt.Errorf("failed to scan result row: %w", err)
+		}
+
+		row := make(map[string]any, len(names))
+		for i, col := range result.Columns {
+			if b, ok := values[i].([]byte); ok {
+				row[col] = string(b)
+			} else {
+				row[col] = values[i]
+			}
+		}
+
+		result.Rows = append(result.Rows, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating result rows: %w", err)
+	}
+
+	return result, nil
+}
+
+// sqliteType maps a PostgreSQL column type to a SQLite column type.
+// JSON, arrays and everything else that has no SQLite equivalent are
+// stored as text, so that SQLite's JSON functions work on them.
+func sqliteType(pgType string) string {
+	t := strings.ToLower(pgType)
+
+	switch {
+	case t == "":
+		return ""
+	case strings.HasSuffix(t, "[]"):
+		return "TEXT"
+	case t == "smallint", t == "integer", t == "bigint", t == "boolean", t == "oid":
+		return "INTEGER"
+	case t == "real", t == "double precision":
+		return "REAL"
+	case strings.HasPrefix(t, "numeric"), strings.HasPrefix(t, "decimal"):
+		return "NUMERIC"
+	case t == "bytea":
+		return "BLOB"
+	default:
+		return "TEXT"
+	}
+}
+
+// sqliteValue converts a snapshot value for insertion into a column
+func sqliteValue(v any, affinity string) any {
+	switch val := v.(type) {
+	case float64:
+		if affinity != "REAL" && val == math.Trunc(val) && math.Abs(val) < 1<<53 {
+			return int64(val)
+		}
+
+		return val
+	case map[string]any, []any:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprintf("%v", val)
+		}
+
+		return string(data)
+	default:
+		return val
+	}
+}
+
+// columnsOf returns the columns of rows captured without column metadata
+func columnsOf(rows []map[string]any) []storage.Column {
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		for col := range row {
+			seen[col] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for col := range seen {
+		names = append(names, col)
+	}
+
+	sort.Strings(names)
+
+	columns := make([]storage.Column, 0, len(names))
+	for _, name := range names {
+		columns = append(columns, storage.Column{Name: name})
+	}
+
+	return columns
+}
+
+// uniqueNames makes column names unique by appending a suffix to repeated ones
+func uniqueNames(names []string) []string {
+	seen := make(map[string]int)
+	unique := make([]string, 0, len(names))
+
+	for _, name := range names {
+		seen[name]++
+		if n := seen[name]; n > 1 {
+			name = name + "_" + strconv.Itoa(n)
+		}
+
+		unique = append(unique, name)
+	}
+
+	return unique
+}
+
+// quoteIdent quotes an SQL identifier
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
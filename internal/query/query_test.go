@@ -0,0 +1,178 @@
+package query
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rom8726/snapdiff/internal/storage"
+)
+
+// writeOrders writes snapshots of an orders table by label to a new store
+// and returns its directory
+func writeOrders(t *testing.T, snapshots map[string][]map[string]any) string {
+	t.Helper()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store, err := storage.NewStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	table := storage.TableInfo{
+		Name: "orders",
+		Columns: []storage.Column{
+			{Name: "id", Type: "bigint"},
+			{Name: "total", Type: "numeric(10,2)"},
+			{Name: "meta", Type: "jsonb"},
+		},
+	}
+
+	for label, rows := range snapshots {
+		w, err := store.CreateSnapshot(ctx, label, storage.WriteOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := w.WriteTable(ctx, table, rows); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := w.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestRun(t *testing.T) {
+	dir := writeOrders(t, map[string][]map[string]any{
+		"a": {
+			{"id": 1, "total": 10, "meta": map[string]any{"source": "web"}},
+			{"id": 2, "total": 20, "meta": map[string]any{"source": "app"}},
+		},
+		"b": {
+			{"id": 1, "total": 15, "meta": map[string]any{"source": "web"}},
+			{"id": 3, "total": 30, "meta": map[string]any{"source": "web"}},
+		},
+	})
+
+	tests := []struct {
+		name    string
+		opts    Options
+		want    []map[string]any
+		wantErr string
+	}{
+		{
+			name: "single snapshot",
+			opts: Options{Label: "a", SQL: "SELECT id FROM orders WHERE total > 15"},
+			want: []map[string]any{{"id": int64(2)}},
+		},
+		{
+			name: "json functions",
+			opts: Options{Label: "b", SQL: "SELECT count(*) AS n FROM orders WHERE json_extract(meta, '$.source') = 'web'"},
+			want: []map[string]any{{"n": int64(2)}},
+		},
+		{
+			name: "join before and after",
+			opts: Options{From: "a", To: "b", SQL: "SELECT b.id, b.total, a.total FROM before.orders b JOIN after.orders a USING (id)"},
+			want: []map[string]any{{"id": int64(1), "total": int64(10), "total_2": int64(15)}},
+		},
+		{
+			name:    "no snapshot",
+			opts:    Options{SQL: "SELECT 1"},
+			wantErr: "no snapshot to query",
+		},
+		{
+			name:    "invalid query",
+			opts:    Options{Label: "a", SQL: "SELECT * FROM missing"},
+			wantErr: "failed to run query",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.BaseDir = dir
+
+			result, err := Run(context.Background(), opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(result.Rows, tt.want) {
+				t.Errorf("rows = %v, want %v", result.Rows, tt.want)
+			}
+		})
+	}
+}
+
+func TestSqliteType(t *testing.T) {
+	tests := []struct {
+		pgType string
+		want   string
+	}{
+		{pgType: "", want: ""},
+		{pgType: "integer", want: "INTEGER"},
+		{pgType: "BIGINT", want: "INTEGER"},
+		{pgType: "boolean", want: "INTEGER"},
+		{pgType: "integer[]", want: "TEXT"},
+		{pgType: "double precision", want: "REAL"},
+		{pgType: "numeric(10,2)", want: "NUMERIC"},
+		{pgType: "bytea", want: "BLOB"},
+		{pgType: "jsonb", want: "TEXT"},
+		{pgType: "timestamp with time zone", want: "TEXT"},
+	}
+
+	for _, tt := range tests {
+		if got := sqliteType(tt.pgType); got != tt.want {
+			t.Errorf("sqliteType(%q) = %q, want %q", tt.pgType, got, tt.want)
+		}
+	}
+}
+
+func TestSqliteValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		v        any
+		affinity string
+		want     any
+	}{
+		{name: "whole number", v: float64(42), affinity: "INTEGER", want: int64(42)},
+		{name: "whole number of untyped column", v: float64(42), want: int64(42)},
+		{name: "whole number of real column", v: float64(42), affinity: "REAL", want: float64(42)},
+		{name: "fraction", v: 1.5, affinity: "NUMERIC", want: 1.5},
+		{name: "object", v: map[string]any{"a": float64(1)}, affinity: "TEXT", want: `{"a":1}`},
+		{name: "array", v: []any{"x", "y"}, affinity: "TEXT", want: `["x","y"]`},
+		{name: "string", v: "text", affinity: "TEXT", want: "text"},
+		{name: "null", v: nil, affinity: "TEXT", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sqliteValue(tt.v, tt.affinity); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sqliteValue(%v, %q) = %#v, want %#v", tt.v, tt.affinity, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUniqueNames(t *testing.T) {
+	got := uniqueNames([]string{"id", "total", "id", "total", "id"})
+	want := []string{"id", "total", "id_2", "total_2", "id_3"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("uniqueNames = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,14 @@
+package show
+
+// Options contains configuration for the show command
+type Options struct {
+	Label      string   // Snapshot label
+	Table      string   // Table to print rows of
+	Where      []string // Filters as col=value, all of which must match
+	PrimaryKey string   // Primary key to look up: an id value, or col=value pairs for other keys
+	Columns    []string // Columns to print (all if empty)
+	Limit      int      // Maximum number of rows to return (all if zero)
+	Offset     int      // Number of matching rows to skip
+	BaseDir    string   // Base directory for snapshots
+	Identity   string   // age identity file for encrypted snapshots (default: $SNAPDIFF_IDENTITY)
+}
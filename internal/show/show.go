@@ -0,0 +1,146 @@
+package show
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rom8726/snapdiff/internal/formatter"
+	"github.com/rom8726/snapdiff/internal/storage"
+)
+
+// Page is a page of the rows of a table that match the filters
+type Page struct {
+	Columns []string // Columns to print, empty for all
+	Rows    []map[string]any
+	Offset  int // Position of the first row among the matching rows
+	Total   int // Number of matching rows
+}
+
+// condition requires a column to have a value
+type condition struct {
+	Column string
+	Value  string
+}
+
+// Run loads a table of a snapshot and returns the requested page of the matching rows
+func Run(ctx context.Context, opts Options) (*Page, error) {
+	conditions, err := parseConditions(opts.Where)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.PrimaryKey != "" {
+		keyConditions, err := parsePrimaryKey(opts.PrimaryKey)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, keyConditions...)
+	}
+
+	store, err := storage.NewStorage(opts.BaseDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage: %w", err)
+	}
+
+	if err := store.UseIdentity(opts.Identity); err != nil {
+		return nil, err
+	}
+
+	rows, err := store.LoadRows(ctx, opts.Label, opts.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkColumns(rows, opts.Columns, conditions); err != nil {
+		return nil, err
+	}
+
+	var matching []map[string]any
+	for _, row := range rows {
+		if matches(row, conditions) {
+			matching = append(matching, row)
+		}
+	}
+
+	page := &Page{
+		Columns: opts.Columns,
+		Offset:  opts.Offset,
+		Total:   len(matching),
+	}
+
+	if opts.Offset < len(matching) {
+		matching = matching[opts.Offset:]
+		if opts.Limit > 0 && len(matching) > opts.Limit {
+			matching = matching[:opts.Limit]
+		}
+		page.Rows = matching
+	}
+
+	return page, nil
+}
+
+// matches reports whether a row satisfies all conditions
+func matches(row map[string]any, conditions []condition) bool {
+	for _, cond := range conditions {
+		if formatter.FormatValue(row[cond.Column]) != cond.Value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkColumns makes sure that the columns used for projection and filtering exist
+func checkColumns(rows []map[string]any, columns []string, conditions []condition) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	known := make(map[string]bool)
+	for _, row := range rows {
+		for col := range row {
+			known[col] = true
+		}
+	}
+
+	for _, col := range columns {
+		if !known[col] {
+			return fmt.Errorf("unknown column: %s", col)
+		}
+	}
+
+	for _, cond := range conditions {
+		if !known[cond.Column] {
+			return fmt.Errorf("unknown column: %s", cond.Column)
+		}
+	}
+
+	return nil
+}
+
+// parseConditions parses col=value filters
+func parseConditions(filters []string) ([]condition, error) {
+	conditions := make([]condition, 0, len(filters))
+	for _, filter := range filters {
+		col, value, ok := strings.Cut(filter, "=")
+		if !ok || col == "" {
+			return nil, fmt.Errorf("invalid filter %q, expected col=value", filter)
+		}
+
+		conditions = append(conditions, condition{Column: strings.TrimSpace(col), Value: value})
+	}
+
+	return conditions, nil
+}
+
+// parsePrimaryKey parses a primary key lookup: a bare value refers to the
+// id column, like row matching in diffs, otherwise col=value pairs
+// separated by commas are expected
+func parsePrimaryKey(key string) ([]condition, error) {
+	if !strings.Contains(key, "=") {
+		return []condition{{Column: "id", Value: key}}, nil
+	}
+
+	return parseConditions(strings.Split(key, ","))
+}
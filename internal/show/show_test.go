@@ -0,0 +1,104 @@
+package show
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rom8726/snapdiff/internal/storage"
+)
+
+// writeUsers writes a snapshot with a users table to a new store and returns its directory
+func writeUsers(t *testing.T) string {
+	t.Helper()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store, err := storage.NewStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := store.CreateSnapshot(ctx, "a", storage.WriteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := []map[string]any{
+		{"id": 1, "org_id": 1, "name": "Ann", "status": "active"},
+		{"id": 2, "org_id": 1, "name": "Bob", "status": "blocked"},
+		{"id": 3, "org_id": 2, "name": "Eve", "status": "active"},
+		{"id": 4, "org_id": 2, "name": "Joe", "status": "active"},
+	}
+	if err := w.WriteTable(ctx, storage.TableInfo{Name: "users"}, rows); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+// names returns the names of the rows of a page
+func names(page *Page) []string {
+	var result []string
+	for _, row := range page.Rows {
+		result = append(result, row["name"].(string))
+	}
+
+	return result
+}
+
+func TestRun(t *testing.T) {
+	dir := writeUsers(t)
+
+	tests := []struct {
+		name      string
+		opts      Options
+		want      []string
+		wantTotal int
+		wantErr   string
+	}{
+		{name: "all rows", opts: Options{}, want: []string{"Ann", "Bob", "Eve", "Joe"}, wantTotal: 4},
+		{name: "filter", opts: Options{Where: []string{"status=active", "org_id=2"}}, want: []string{"Eve", "Joe"}, wantTotal: 2},
+		{name: "id lookup", opts: Options{PrimaryKey: "2"}, want: []string{"Bob"}, wantTotal: 1},
+		{name: "key lookup", opts: Options{PrimaryKey: "org_id=1,name=Ann"}, want: []string{"Ann"}, wantTotal: 1},
+		{name: "page", opts: Options{Where: []string{"status=active"}, Offset: 1, Limit: 1}, want: []string{"Eve"}, wantTotal: 3},
+		{name: "offset past the end", opts: Options{Offset: 10}, wantTotal: 4},
+		{name: "unknown column", opts: Options{Columns: []string{"email"}}, wantErr: "unknown column: email"},
+		{name: "unknown filter column", opts: Options{Where: []string{"email=a"}}, wantErr: "unknown column: email"},
+		{name: "invalid filter", opts: Options{Where: []string{"status"}}, wantErr: "expected col=value"},
+		{name: "unknown table", opts: Options{Table: "orders"}, wantErr: "does not exist"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.BaseDir = dir
+			opts.Label = "a"
+			if opts.Table == "" {
+				opts.Table = "users"
+			}
+
+			page, err := Run(context.Background(), opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := names(page); !reflect.DeepEqual(got, tt.want) || page.Total != tt.wantTotal {
+				t.Errorf("rows = %v of %d, want %v of %d", got, page.Total, tt.want, tt.wantTotal)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package snapshot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/rom8726/snapdiff/internal/mask"
+	"github.com/rom8726/snapdiff/internal/storage"
+)
+
+// findBase returns the manifest of the snapshot to reuse unchanged tables from:
+// the given label, or the most recent snapshot if label is empty. A most
+// recent snapshot that can't be decrypted is not used.
+func findBase(ctx context.Context, store *storage.Storage, label string) (*storage.Manifest, error) {
+	if label == "" {
+		base, err := store.LatestSnapshot(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find base snapshot: %w", err)
+		}
+
+		if base != nil && base.Sealed() {
+			base, err = store.LoadManifest(ctx, base.Label)
+			if errors.Is(err, storage.ErrNoIdentity) {
+				log.Printf("Not reusing tables from the most recent snapshot: %v", err)
+
+				return nil, nil
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to load base snapshot: %w", err)
+			}
+		}
+
+		return base, nil
+	}
+
+	base, err := store.LoadManifest(ctx, label)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load base snapshot: %w", err)
+	}
+
+	return base, nil
+}
+
+// baseTable returns the base snapshot's entry for a table
+func baseTable(base *storage.Manifest, tableName string) (*storage.TableInfo, bool) {
+	if base == nil {
+		return nil, false
+	}
+
+	return base.Table(tableName)
+}
+
+// fingerprintKey combines a database fingerprint with the captured columns,
+// their types and masking, so that capturing a different set of columns, or
+// columns whose values are captured or masked differently, never reuses a table
+func fingerprintKey(columns []string, types, masks map[string]string, fingerprint string) string {
+	parts := make([]string, 0, len(columns)+1)
+	for _, col := range columns {
+		part := col + " " + types[col]
+		if desc, ok := masks[col]; ok {
+			part += " masked " + desc
+		}
+		parts = append(parts, part)
+	}
+	parts = append(parts, fingerprint)
+
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// maskDescriptions describes how each masked column is masked
+func maskDescriptions(masker *mask.Masker, masked map[string]mask.Rule) map[string]string {
+	descs := make(map[string]string, len(masked))
+	for col, rule := range masked {
+		descs[col] = masker.Describe(rule)
+	}
+
+	return descs
+}
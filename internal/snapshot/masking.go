@@ -0,0 +1,37 @@
+package snapshot
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rom8726/snapdiff/internal/mask"
+)
+
+// maskKeyEnv is the environment variable the masking key is read from if no key file is given
+const maskKeyEnv = "SNAPDIFF_MASK_KEY"
+
+// loadMasker creates the masker for the configured rules, or returns nil if there are none
+func loadMasker(rulesFile, keyFile string) (*mask.Masker, error) {
+	if rulesFile == "" {
+		return nil, nil
+	}
+
+	ruleSet, err := mask.LoadRules(rulesFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var key []byte
+	if keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read masking key: %w", err)
+		}
+		key = []byte(strings.TrimSpace(string(data)))
+	} else {
+		key = []byte(os.Getenv(maskKeyEnv))
+	}
+
+	return mask.New(ruleSet.Rules, key)
+}
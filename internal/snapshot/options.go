@@ -7,4 +7,21 @@ type Options struct {
 	IgnoreColumns []string // Columns to ignore in snapshot
 	SortKeys      bool     // Sort keys in YAML output
 	OutputDir     string   // Output base directory (default ".snapdiff")
+	Compression   string   // Table file compression (none, gzip, zstd)
+	Incremental   string   // Fingerprint method for reusing unchanged tables (hash, stats); empty disables
+	Base          string   // Snapshot to reuse unchanged tables from (default: most recent)
+	SignKey       string   // Path to an Ed25519 private key to sign the snapshot with
+	Tags          []string // Tags to record in the manifest
+	Note          string   // Note to record in the manifest
+	MaskRules     string   // Path to a YAML file with masking rules
+	MaskKeyFile   string   // Path to the masking key (default: $SNAPDIFF_MASK_KEY)
+	Encrypt       []string // age recipients, recipient files or "passphrase" to encrypt the snapshot to
+	Identity      string   // age identity file to read an encrypted base snapshot with
+}
+
+// CaptureOptions contains configuration for capturing a snapshot into memory
+type CaptureOptions struct {
+	Schema        string   // Database schema (default "public")
+	Tables        []string // Specific tables to include
+	IgnoreColumns []string // Columns to ignore in snapshot
 }
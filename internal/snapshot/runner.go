@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"slices"
 
 	"github.com/rom8726/snapdiff/internal/db"
+	"github.com/rom8726/snapdiff/internal/mask"
 	"github.com/rom8726/snapdiff/internal/storage"
 )
 
@@ -34,13 +36,202 @@ func Run(ctx context.Context, opts Options) error {
 	}
 	defer database.Close()
 
+	return save(ctx, database, dbConfig.Source(), opts)
+}
+
+// save captures the tables of a database into a new stored snapshot
+func save(ctx context.Context, database db.Database, source string, opts Options) error {
+	compression, err := storage.ParseCompression(opts.Compression)
+	if err != nil {
+		return err
+	}
+
+	writeOpts := storage.WriteOptions{
+		Format:      storage.FormatJSON,
+		Compression: compression,
+		Source:      source,
+		Tags:        opts.Tags,
+		Note:        opts.Note,
+		Recipients:  opts.Encrypt,
+	}
+
+	if opts.SignKey != "" {
+		writeOpts.SigningKey, err = storage.LoadSigningKey(opts.SignKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	masker, err := loadMasker(opts.MaskRules, opts.MaskKeyFile)
+	if err != nil {
+		return err
+	}
+	if masker != nil {
+		writeOpts.Masking = masker.Policy()
+	}
+
 	store, err := storage.NewStorage(opts.OutputDir)
 	if err != nil {
 		return fmt.Errorf("failed to create storage: %w", err)
 	}
 
-	// Get schema (default to public)
-	schema := ""
+	if err := store.UseIdentity(opts.Identity); err != nil {
+		return err
+	}
+
+	writer, err := store.CreateSnapshot(ctx, opts.Label, writeOpts)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	// Discard the partial snapshot on failure or interruption; this is a no-op after Commit
+	defer func() { _ = writer.Abort(context.WithoutCancel(ctx)) }()
+
+	captureOpts := CaptureOptions{
+		Tables:        opts.Tables,
+		IgnoreColumns: opts.IgnoreColumns,
+	}
+
+	method := db.FingerprintMethod(opts.Incremental)
+
+	var base *storage.Manifest
+	if method != "" {
+		if method != db.FingerprintHash && method != db.FingerprintStats {
+			return fmt.Errorf("unsupported incremental method: %s", method)
+		}
+
+		base, err = findBase(ctx, store, opts.Base)
+		if err != nil {
+			return err
+		}
+
+		if base != nil && !writer.CanReuse(base) {
+			log.Printf("Not reusing tables from snapshot '%s': it is encrypted differently", base.Label)
+			base = nil
+		}
+
+		if base != nil {
+			log.Printf("Reusing unchanged tables from snapshot '%s'", base.Label)
+		}
+	}
+
+	err = captureTables(ctx, database, captureOpts, log.Printf, func(spec tableSpec, load func() (TableData, error)) error {
+		info := storage.TableInfo{Name: spec.Name, Keys: spec.Keys()}
+		for _, col := range spec.Columns {
+			info.Columns = append(info.Columns, storage.Column{Name: col, Type: spec.Types[col]})
+		}
+
+		var masked map[string]mask.Rule
+		if masker != nil {
+			masked = masker.Columns(spec.Name, spec.Columns)
+		}
+
+		if method != "" {
+			fingerprint, err := database.TableFingerprint(ctx, spec.Schema, spec.Name, spec.Columns, spec.PrimaryKey, method)
+			if err != nil {
+				return fmt.Errorf("failed to fingerprint table %s: %w", spec.Name, err)
+			}
+			info.Fingerprint = fingerprintKey(spec.Columns, spec.Types, maskDescriptions(masker, masked), fingerprint)
+
+			if prev, ok := baseTable(base, spec.Name); ok && prev.Fingerprint == info.Fingerprint {
+				reused := *prev
+				reused.Keys = info.Keys
+
+				if err := writer.ReuseTable(ctx, base, reused); err != nil {
+					return fmt.Errorf("failed to reuse table %s: %w", spec.Name, err)
+				}
+				log.Printf("Reused unchanged table %s with %d rows", spec.Name, prev.Rows)
+
+				return nil
+			}
+		}
+
+		tableData, err := load()
+		if err != nil {
+			return err
+		}
+
+		if masker != nil {
+			masker.Apply(tableData, masked)
+		}
+
+		if err := writer.WriteTable(ctx, info, tableData); err != nil {
+			return fmt.Errorf("failed to save snapshot for table %s: %w", spec.Name, err)
+		}
+
+		log.Printf("Saved snapshot for table %s with %d rows", spec.Name, len(tableData))
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := writer.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to save snapshot manifest: %w", err)
+	}
+
+	log.Printf("Snapshot '%s' created successfully", opts.Label)
+
+	return nil
+}
+
+// tableSpec describes a table selected for capture
+type tableSpec struct {
+	Schema      string
+	Name        string
+	Columns     []string          // Captured columns, without ignored ones
+	Types       map[string]string // Database type of each column
+	PrimaryKey  []string
+	UniqueKeys  [][]string
+	ForeignKeys []db.ForeignKey
+}
+
+// Keys returns the keys of the table that consist of captured columns
+func (s tableSpec) Keys() *storage.Keys {
+	captured := func(key []string) bool {
+		for _, col := range key {
+			if !slices.Contains(s.Columns, col) {
+				return false
+			}
+		}
+
+		return len(key) > 0
+	}
+
+	keys := &storage.Keys{}
+	if captured(s.PrimaryKey) {
+		keys.PrimaryKey = s.PrimaryKey
+	}
+
+	for _, key := range s.UniqueKeys {
+		if captured(key) {
+			keys.Unique = append(keys.Unique, key)
+		}
+	}
+
+	for _, key := range s.ForeignKeys {
+		if captured(key.Columns) {
+			keys.Foreign = append(keys.Foreign, storage.ForeignKey{
+				Columns:    key.Columns,
+				RefTable:   key.RefTable,
+				RefColumns: key.RefColumns,
+			})
+		}
+	}
+
+	return keys
+}
+
+// captureTables resolves the selected tables one by one and passes each to fn
+// together with a function that queries its data
+func captureTables(
+	ctx context.Context,
+	database db.Database,
+	opts CaptureOptions,
+	logf func(format string, args ...any),
+	fn func(spec tableSpec, load func() (TableData, error)) error,
+) error {
+	schema := opts.Schema
 
 	var tables []string
 	if len(opts.Tables) > 0 {
@@ -59,7 +250,7 @@ func Run(ctx context.Context, opts Options) error {
 	}
 
 	for _, tableName := range tables {
-		log.Printf("Processing table: %s", tableName)
+		logf("Processing table: %s", tableName)
 
 		columns, err := database.GetTableColumns(ctx, schema, tableName)
 		if err != nil {
@@ -74,25 +265,76 @@ func Run(ctx context.Context, opts Options) error {
 		}
 
 		if len(filteredColumns) == 0 {
-			log.Printf("Skipping table %s: all columns are ignored", tableName)
+			logf("Skipping table %s: all columns are ignored", tableName)
 			continue
 		}
 
-		// Use the new QueryTableData method instead of building and executing the query directly
-		tableData, err := database.QueryTableData(ctx, schema, tableName, filteredColumns)
+		types, err := database.GetColumnTypes(ctx, schema, tableName)
 		if err != nil {
-			return fmt.Errorf("failed to query table %s: %w", tableName, err)
+			return fmt.Errorf("failed to get column types for table %s: %w", tableName, err)
 		}
 
-		format := storage.FormatJSON
-		if err := store.SaveSnapshot(opts.Label, tableName, tableData, format); err != nil {
-			return fmt.Errorf("failed to save snapshot for table %s: %w", tableName, err)
+		pkColumns, err := database.GetPrimaryKeyColumns(ctx, schema, tableName)
+		if err != nil {
+			return fmt.Errorf("failed to get primary key for table %s: %w", tableName, err)
 		}
 
-		log.Printf("Saved snapshot for table %s with %d rows", tableName, len(tableData))
-	}
+		uniqueKeys, err := database.GetUniqueKeys(ctx, schema, tableName)
+		if err != nil {
+			return fmt.Errorf("failed to get unique keys for table %s: %w", tableName, err)
+		}
 
-	log.Printf("Snapshot '%s' created successfully", opts.Label)
+		foreignKeys, err := database.GetForeignKeys(ctx, schema, tableName)
+		if err != nil {
+			return fmt.Errorf("failed to get foreign keys for table %s: %w", tableName, err)
+		}
+		foreignKeys = capturedReferences(foreignKeys, tables, ignoreColumnsMap)
+
+		spec := tableSpec{
+			Schema:      schema,
+			Name:        tableName,
+			Columns:     filteredColumns,
+			Types:       types,
+			PrimaryKey:  pkColumns,
+			UniqueKeys:  uniqueKeys,
+			ForeignKeys: foreignKeys,
+		}
+
+		load := func() (TableData, error) {
+			tableData, err := database.QueryTableData(ctx, schema, tableName, filteredColumns, pkColumns)
+			if err != nil {
+				return nil, fmt.Errorf("failed to query table %s: %w", tableName, err)
+			}
+
+			normalizeValues(tableData, types)
+
+			return tableData, nil
+		}
+
+		if err := fn(spec, load); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
+
+// capturedReferences returns the foreign keys that reference captured
+// columns of a captured table. Tables in other schemas are referenced by
+// qualified names, so they never count as captured.
+func capturedReferences(foreignKeys []db.ForeignKey, tables []string, ignoreColumns map[string]bool) []db.ForeignKey {
+	var captured []db.ForeignKey
+	for _, fk := range foreignKeys {
+		if !slices.Contains(tables, fk.RefTable) {
+			continue
+		}
+
+		if slices.ContainsFunc(fk.RefColumns, func(col string) bool { return ignoreColumns[col] }) {
+			continue
+		}
+
+		captured = append(captured, fk)
+	}
+
+	return captured
+}
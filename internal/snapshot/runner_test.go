@@ -0,0 +1,273 @@
+package snapshot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"filippo.io/age"
+
+	"github.com/rom8726/snapdiff/internal/db"
+	"github.com/rom8726/snapdiff/internal/storage"
+)
+
+// fakeTable is a table of a fakeDatabase
+type fakeTable struct {
+	columns     []string
+	types       map[string]string
+	primaryKey  []string
+	foreignKeys []db.ForeignKey
+	rows        []map[string]any
+}
+
+// fakeDatabase is an in-memory db.Database
+type fakeDatabase struct {
+	tables map[string]*fakeTable
+	order  []string
+}
+
+func (d *fakeDatabase) GetTableNames(context.Context, string) ([]string, error) {
+	return d.order, nil
+}
+
+func (d *fakeDatabase) GetTableColumns(_ context.Context, _, tableName string) ([]string, error) {
+	return d.tables[tableName].columns, nil
+}
+
+func (d *fakeDatabase) GetColumnTypes(_ context.Context, _, tableName string) (map[string]string, error) {
+	return d.tables[tableName].types, nil
+}
+
+func (d *fakeDatabase) GetPrimaryKeyColumns(_ context.Context, _, tableName string) ([]string, error) {
+	return d.tables[tableName].primaryKey, nil
+}
+
+func (d *fakeDatabase) GetUniqueKeys(context.Context, string, string) ([][]string, error) {
+	return nil, nil
+}
+
+func (d *fakeDatabase) GetForeignKeys(_ context.Context, _, tableName string) ([]db.ForeignKey, error) {
+	return d.tables[tableName].foreignKeys, nil
+}
+
+func (d *fakeDatabase) QueryTableData(_ context.Context, _, tableName string, columns, _ []string) ([]map[string]any, error) {
+	rows := make([]map[string]any, 0, len(d.tables[tableName].rows))
+	for _, row := range d.tables[tableName].rows {
+		projected := make(map[string]any, len(columns))
+		for _, col := range columns {
+			projected[col] = row[col]
+		}
+		rows = append(rows, projected)
+	}
+
+	return rows, nil
+}
+
+func (d *fakeDatabase) TableFingerprint(_ context.Context, _, tableName string, _, _ []string, _ db.FingerprintMethod) (string, error) {
+	data, err := json.Marshal(d.tables[tableName].rows)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// newFakeDatabase returns a database with an orders and a customers table
+func newFakeDatabase() *fakeDatabase {
+	return &fakeDatabase{
+		order: []string{"customers", "orders"},
+		tables: map[string]*fakeTable{
+			"customers": {
+				columns:    []string{"id", "email"},
+				types:      map[string]string{"id": "integer", "email": "text"},
+				primaryKey: []string{"id"},
+				rows:       []map[string]any{{"id": 1, "email": "a@example.com"}},
+			},
+			"orders": {
+				columns:    []string{"id", "customer_id", "status"},
+				types:      map[string]string{"id": "integer", "customer_id": "integer", "status": "text"},
+				primaryKey: []string{"id"},
+				foreignKeys: []db.ForeignKey{
+					{Columns: []string{"customer_id"}, RefTable: "customers", RefColumns: []string{"id"}},
+				},
+				rows: []map[string]any{{"id": 1, "customer_id": 1, "status": "new"}},
+			},
+		},
+	}
+}
+
+// writeIdentity writes a new age identity to a file and returns the file and its recipient
+func writeIdentity(t *testing.T) (string, string) {
+	t.Helper()
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "key.txt")
+	if err := os.WriteFile(path, []byte(identity.String()+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path, identity.Recipient().String()
+}
+
+func TestSaveIncremental(t *testing.T) {
+	identityFile, recipient := writeIdentity(t)
+
+	tests := []struct {
+		name    string
+		encrypt []string
+	}{
+		{name: "plain"},
+		{name: "encrypted", encrypt: []string{recipient}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+			database := newFakeDatabase()
+
+			opts := Options{
+				OutputDir:   dir,
+				Incremental: string(db.FingerprintHash),
+				Encrypt:     tt.encrypt,
+				Identity:    identityFile,
+			}
+
+			opts.Label = "a"
+			if err := save(ctx, database, "test", opts); err != nil {
+				t.Fatalf("save a: %v", err)
+			}
+
+			database.tables["orders"].rows[0]["status"] = "paid"
+
+			opts.Label = "b"
+			if err := save(ctx, database, "test", opts); err != nil {
+				t.Fatalf("save b: %v", err)
+			}
+
+			store, err := storage.NewStorage(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := store.UseIdentity(identityFile); err != nil {
+				t.Fatal(err)
+			}
+
+			a, err := store.LoadManifest(ctx, "a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := store.LoadManifest(ctx, "b")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			aCustomers, _ := a.Table("customers")
+			bCustomers, _ := b.Table("customers")
+			if aCustomers.Object != bCustomers.Object {
+				t.Errorf("unchanged table was not reused: %s != %s", aCustomers.Object, bCustomers.Object)
+			}
+
+			aOrders, _ := a.Table("orders")
+			bOrders, _ := b.Table("orders")
+			if aOrders.Object == bOrders.Object {
+				t.Errorf("changed table was reused")
+			}
+
+			wantRows := map[string][]map[string]any{
+				"customers": {{"id": float64(1), "email": "a@example.com"}},
+				"orders":    {{"id": float64(1), "customer_id": float64(1), "status": "paid"}},
+			}
+			for table, want := range wantRows {
+				got, err := store.ReadTable(ctx, b, table)
+				if err != nil {
+					t.Fatalf("read %s of b: %v", table, err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("rows of %s = %v, want %v", table, got, want)
+				}
+			}
+
+			// A snapshot reusing a reused table can still read it
+			opts.Label = "c"
+			opts.Base = "b"
+			if err := save(ctx, database, "test", opts); err != nil {
+				t.Fatalf("save c: %v", err)
+			}
+
+			c, err := store.LoadManifest(ctx, "c")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for table := range wantRows {
+				if _, err := store.ReadTable(ctx, c, table); err != nil {
+					t.Errorf("read %s of c: %v", table, err)
+				}
+			}
+		})
+	}
+}
+
+func TestCapturedReferences(t *testing.T) {
+	fk := func(refTable string, refColumns ...string) db.ForeignKey {
+		return db.ForeignKey{Columns: []string{"ref"}, RefTable: refTable, RefColumns: refColumns}
+	}
+
+	tests := []struct {
+		name   string
+		fk     db.ForeignKey
+		ignore map[string]bool
+		want   bool
+	}{
+		{name: "captured", fk: fk("orders", "id"), want: true},
+		{name: "table not captured", fk: fk("payments", "id")},
+		{name: "table in another schema", fk: fk("billing.orders", "id")},
+		{name: "column ignored", fk: fk("orders", "code"), ignore: map[string]bool{"code": true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := capturedReferences([]db.ForeignKey{tt.fk}, []string{"orders", "order_items"}, tt.ignore)
+			if (len(got) == 1) != tt.want {
+				t.Errorf("captured references = %v, want kept: %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeValues(t *testing.T) {
+	types := map[string]string{"amount": "numeric", "data": "jsonb", "photo": "bytea", "tags": "text[]"}
+
+	rows := TableData{{
+		"amount": []byte("12.50"),
+		"data":   []byte(`{"a": 1}`),
+		"photo":  []byte{0xff, 0x00},
+		"tags":   []byte("{a,b}"),
+		"id":     int64(1),
+		"note":   nil,
+	}}
+	normalizeValues(rows, types)
+
+	want := TableData{{
+		"amount": "12.50",
+		"data":   `{"a": 1}`,
+		"photo":  []byte{0xff, 0x00},
+		"tags":   "{a,b}",
+		"id":     int64(1),
+		"note":   nil,
+	}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %v, want %v", rows, want)
+	}
+}
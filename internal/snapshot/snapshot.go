@@ -0,0 +1,110 @@
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/rom8726/snapdiff/internal/db"
+	"github.com/rom8726/snapdiff/internal/storage"
+)
+
+// Snapshot is an in-memory snapshot of database tables
+type Snapshot struct {
+	Tables map[string]TableData
+	Types  map[string]map[string]string // Database type of each column by table, empty if unknown
+	Keys   map[string]*storage.Keys     // Key constraints by table, nil if unknown
+}
+
+// Capture reads the selected tables into an in-memory snapshot.
+// Values are normalized the same way as when a snapshot is saved to
+// and loaded from disk, so in-memory and stored snapshots compare alike.
+func Capture(ctx context.Context, database db.Database, opts CaptureOptions) (*Snapshot, error) {
+	snap := &Snapshot{
+		Tables: make(map[string]TableData),
+		Types:  make(map[string]map[string]string),
+		Keys:   make(map[string]*storage.Keys),
+	}
+
+	noLog := func(string, ...any) {}
+
+	err := captureTables(ctx, database, opts, noLog, func(spec tableSpec, load func() (TableData, error)) error {
+		tableData, err := load()
+		if err != nil {
+			return err
+		}
+
+		normalized, err := normalizeRows(tableData)
+		if err != nil {
+			return fmt.Errorf("failed to normalize table %s: %w", spec.Name, err)
+		}
+
+		snap.Tables[spec.Name] = normalized
+		snap.Types[spec.Name] = spec.Types
+		snap.Keys[spec.Name] = spec.Keys()
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return snap, nil
+}
+
+// TableNames returns the sorted names of the tables in the snapshot
+func (s *Snapshot) TableNames(context.Context) ([]string, error) {
+	names := make([]string, 0, len(s.Tables))
+	for name := range s.Tables {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// LoadTable returns the rows of a table in the snapshot
+func (s *Snapshot) LoadTable(_ context.Context, tableName string) ([]map[string]any, error) {
+	rows, ok := s.Tables[tableName]
+	if !ok {
+		return nil, fmt.Errorf("table does not exist in snapshot: %s", tableName)
+	}
+
+	return rows, nil
+}
+
+// ColumnTypes returns the database type of each column of a table
+func (s *Snapshot) ColumnTypes(_ context.Context, tableName string) map[string]string {
+	return s.Types[tableName]
+}
+
+// TableKeys returns the key constraints of a table
+func (s *Snapshot) TableKeys(_ context.Context, tableName string) *storage.Keys {
+	return s.Keys[tableName]
+}
+
+// TableHash returns "" because in-memory snapshots are not content-addressed
+func (s *Snapshot) TableHash(context.Context, string) string {
+	return ""
+}
+
+// normalizeRows converts driver values to their JSON representation
+func normalizeRows(rows TableData) (TableData, error) {
+	content, err := json.Marshal(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	var normalized TableData
+	if err := json.Unmarshal(content, &normalized); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+
+	if normalized == nil {
+		normalized = TableData{}
+	}
+
+	return normalized, nil
+}
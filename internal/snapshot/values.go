@@ -0,0 +1,16 @@
+package snapshot
+
+// normalizeValues converts driver values in place to the representation
+// stored in snapshots. Drivers return many types, such as numeric, json
+// and arrays, as their text in a byte slice, which would otherwise be
+// stored base64-encoded; only bytea columns hold binary data. Snapshots
+// without a manifest predate this and still hold those values base64-encoded.
+func normalizeValues(rows TableData, types map[string]string) {
+	for _, row := range rows {
+		for col, value := range row {
+			if b, ok := value.([]byte); ok && types[col] != "bytea" {
+				row[col] = string(b)
+			}
+		}
+	}
+}
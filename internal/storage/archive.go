@@ -0,0 +1,552 @@
+package storage
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// archiveObjectsDir is the directory of table payloads inside an archive
+	archiveObjectsDir = "objects/"
+	// archiveChecksums is the name of the checksum list inside an archive, in sha256sum format
+	archiveChecksums = "SHA256SUMS"
+)
+
+// ArchiveCompression returns the compression of a snapshot archive by its file name
+func ArchiveCompression(name string) Compression {
+	if strings.HasSuffix(name, ".tgz") {
+		return CompressionGzip
+	}
+
+	if strings.HasSuffix(name, ".tzst") {
+		return CompressionZstd
+	}
+
+	return compressionFromExtension(name)
+}
+
+// ExportSnapshot writes a snapshot as a self-describing tar archive: the
+// manifest, the table payloads as stored, and the SHA-256 of every entry.
+// Encrypted snapshots are exported as they are, without decrypting them.
+func (s *Storage) ExportSnapshot(ctx context.Context, label string, w io.Writer, compression Compression) error {
+	manifest, err := s.loadManifest(ctx, label)
+	if err != nil {
+		return err
+	}
+
+	if !manifest.Complete {
+		return fmt.Errorf("snapshot %s is incomplete", label)
+	}
+
+	cw, err := compressWriter(w, compression)
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(cw)
+	checksums := make(map[string]string)
+
+	// Table payloads are addressed by their name inside the archive
+	exported := *manifest
+	exported.Version = manifestVersion
+
+	var objects []string
+	entries := make(map[string]string)
+	addEntry := func(key string) (string, bool) {
+		entry := archiveObjectsDir + path.Base(key)
+		if prev, ok := entries[entry]; ok {
+			return entry, prev == key
+		}
+
+		entries[entry] = key
+		objects = append(objects, entry)
+
+		return entry, true
+	}
+
+	if manifest.Encryption != nil {
+		encryption := *manifest.Encryption
+		encryption.Objects = make([]string, len(manifest.Encryption.Objects))
+		for i, key := range manifest.Encryption.Objects {
+			encryption.Objects[i], _ = addEntry(key)
+		}
+		exported.Encryption = &encryption
+	} else {
+		exported.Tables = make([]TableInfo, len(manifest.Tables))
+		for i, table := range manifest.Tables {
+			entry, ok := addEntry(table.Object)
+			if !ok {
+				return fmt.Errorf("table %s can't be exported: duplicate file name %s", table.Name, entry)
+			}
+
+			table.Object = entry
+			exported.Tables[i] = table
+		}
+	}
+
+	manifestData, err := json.MarshalIndent(exported.storedView(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	if err := writeArchiveEntry(tw, manifestName, manifest.CreatedAt, bytes.NewReader(manifestData), int64(len(manifestData)), checksums); err != nil {
+		return err
+	}
+
+	for _, entry := range objects {
+		if err := s.exportObject(ctx, tw, entry, entries[entry], manifest.CreatedAt, checksums); err != nil {
+			return err
+		}
+	}
+
+	names := make([]string, 0, len(checksums))
+	for name := range checksums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sums strings.Builder
+	for _, name := range names {
+		_, _ = fmt.Fprintf(&sums, "%s  %s\n", checksums[name], name)
+	}
+
+	sumsData := []byte(sums.String())
+	if err := writeArchiveEntry(tw, archiveChecksums, manifest.CreatedAt, bytes.NewReader(sumsData), int64(len(sumsData)), nil); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	if err := cw.Close(); err != nil {
+		return fmt.Errorf("failed to compress archive: %w", err)
+	}
+
+	return nil
+}
+
+// exportObject copies a stored object into the archive
+func (s *Storage) exportObject(ctx context.Context, tw *tar.Writer, entry, key string, modTime time.Time, checksums map[string]string) error {
+	objects, err := s.backend.List(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to check object %s: %w", key, err)
+	}
+
+	size := int64(-1)
+	for _, obj := range objects {
+		if obj.Key == key {
+			size = obj.Size
+		}
+	}
+
+	if size < 0 {
+		return fmt.Errorf("snapshot file does not exist: %s", key)
+	}
+
+	r, err := s.backend.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot file: %w", err)
+	}
+	defer r.Close()
+
+	return writeArchiveEntry(tw, entry, modTime, r, size, checksums)
+}
+
+// writeArchiveEntry writes a file to the archive and records its checksum
+func writeArchiveEntry(tw *tar.Writer, name string, modTime time.Time, r io.Reader, size int64, checksums map[string]string) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    size,
+		ModTime: modTime,
+		Format:  tar.FormatPAX,
+	}
+
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	hasher := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tw, hasher), r); err != nil {
+		return fmt.Errorf("failed to write %s to archive: %w", name, err)
+	}
+
+	if checksums != nil {
+		checksums[name] = hex.EncodeToString(hasher.Sum(nil))
+	}
+
+	return nil
+}
+
+// ImportSnapshot reads a snapshot archive written by ExportSnapshot and
+// registers it under the label, or under its original label if empty.
+// The archive checksums and table digests are verified before the
+// snapshot becomes visible; the tables of encrypted snapshots are only
+// checked when they are decrypted.
+func (s *Storage) ImportSnapshot(ctx context.Context, r io.Reader, compression Compression, label string) (*Manifest, error) {
+	dr, err := decompressReader(bufio.NewReader(r), compression)
+	if err != nil {
+		return nil, err
+	}
+	defer dr.Close()
+
+	tr := tar.NewReader(dr)
+
+	header, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	if header.Name != manifestName {
+		return nil, fmt.Errorf("invalid archive: %s must be the first entry", manifestName)
+	}
+
+	checksums := make(map[string]string)
+	hasher := sha256.New()
+
+	var manifest Manifest
+	if err := json.NewDecoder(io.TeeReader(tr, hasher)).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("invalid archive: failed to parse manifest: %w", err)
+	}
+	if _, err := io.Copy(hasher, tr); err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	checksums[manifestName] = hex.EncodeToString(hasher.Sum(nil))
+
+	if manifest.Version > manifestVersion {
+		return nil, fmt.Errorf("archive manifest version %d is not supported", manifest.Version)
+	}
+
+	if !manifest.Complete {
+		return nil, fmt.Errorf("invalid archive: snapshot %s is incomplete", manifest.Label)
+	}
+
+	if label == "" {
+		label = manifest.Label
+	}
+
+	if err := validateLabel(label); err != nil {
+		return nil, err
+	}
+
+	lock, err := s.LockSnapshot(ctx, label)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = lock.Release(ctx) }()
+
+	existing, err := s.backend.List(ctx, snapshotPrefix(label))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot directory: %w", err)
+	}
+
+	if len(existing) > 0 {
+		return nil, fmt.Errorf("snapshot already exists: %s", label)
+	}
+
+	manifest.Version = manifestVersion
+	manifest.Label = label
+	manifest.sealed = manifest.Encryption != nil
+
+	imp := &snapshotImport{store: s, manifest: &manifest, keys: make(map[string]string)}
+	for _, table := range manifest.Tables {
+		key, err := imp.key(table)
+		if err != nil {
+			return nil, err
+		}
+
+		imp.keys[table.Object] = key
+	}
+
+	if manifest.Encryption != nil {
+		for _, entry := range manifest.Encryption.Objects {
+			name := path.Base(entry)
+			id, _, _ := strings.Cut(name, ".")
+			if !isHexDigest(id, encryptedObjectIDSize) || !strings.HasSuffix(name, encryptedExtension) {
+				return nil, fmt.Errorf("invalid archive: unexpected encrypted object %s", entry)
+			}
+
+			imp.keys[entry] = objectsPrefix + name[:2] + "/" + name
+		}
+	}
+
+	if err := imp.run(ctx, tr, checksums); err != nil {
+		imp.cleanup(ctx)
+
+		return nil, err
+	}
+
+	if err := s.backend.Rename(ctx, stagingManifestKey(label), manifestKey(label)); err != nil {
+		imp.cleanup(ctx)
+
+		return nil, fmt.Errorf("failed to move snapshot into place: %w", err)
+	}
+
+	return &manifest, nil
+}
+
+// snapshotImport tracks the payloads written while importing an archive
+type snapshotImport struct {
+	store    *Storage
+	manifest *Manifest
+	keys     map[string]string // Archive entry name to store key
+	written  []string          // Keys of the payloads written so far
+}
+
+// run stores the table payloads of the archive, verifies them and stages
+// the manifest of the imported snapshot
+func (imp *snapshotImport) run(ctx context.Context, tr *tar.Reader, checksums map[string]string) error {
+	s := imp.store
+
+	var sums []byte
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+
+		if header.Name == archiveChecksums {
+			sums, err = io.ReadAll(tr)
+			if err != nil {
+				return fmt.Errorf("failed to read archive: %w", err)
+			}
+
+			continue
+		}
+
+		key, ok := imp.keys[header.Name]
+		if !ok {
+			return fmt.Errorf("invalid archive: unexpected entry %s", header.Name)
+		}
+
+		sum, err := imp.storeObject(ctx, tr, key)
+		if err != nil {
+			return err
+		}
+		checksums[header.Name] = sum
+	}
+
+	if err := verifyChecksums(sums, checksums); err != nil {
+		return err
+	}
+
+	for i := range imp.manifest.Tables {
+		table := &imp.manifest.Tables[i]
+		entry := table.Object
+		table.Object = imp.keys[entry]
+
+		// Plain payloads must match their hash before they are trusted in the
+		// object store; compressed ones are checked when they are decoded below
+		if strings.HasPrefix(table.Object, objectsPrefix) && compressionFromExtension(table.Object) == CompressionNone &&
+			!strings.HasSuffix(table.Object, encryptedExtension) && checksums[entry] != table.Hash {
+			return fmt.Errorf("table %s is %w: payload does not match its hash", table.Name, ErrCorrupted)
+		}
+
+		// Payloads of legacy snapshots have no hash yet; store them by content like new ones
+		if strings.HasPrefix(table.Object, stagingPrefix) {
+			if err := imp.moveToObjects(ctx, table); err != nil {
+				return err
+			}
+		}
+	}
+
+	if encryption := imp.manifest.Encryption; encryption != nil {
+		for i, entry := range encryption.Objects {
+			encryption.Objects[i] = imp.keys[entry]
+		}
+	}
+
+	// Stage the snapshot so that its payloads stay referenced while the tables are verified
+	if err := s.saveManifest(ctx, stagingManifestKey(imp.manifest.Label), imp.manifest); err != nil {
+		return err
+	}
+
+	for _, table := range imp.manifest.Tables {
+		if _, err := s.readTableData(ctx, imp.manifest, table.Name, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// key returns the store key for the payload of a table. Payloads named
+// after a valid hash go straight to the object store; others are hashed
+// after they have been written to the staging area.
+func (imp *snapshotImport) key(table TableInfo) (string, error) {
+	name := path.Base(table.Object)
+	if name == "." || name == ".." || name == "/" {
+		return "", fmt.Errorf("invalid archive: unexpected object %s of table %s", table.Object, table.Name)
+	}
+
+	if table.Hash == "" {
+		return stagingPrefix + imp.manifest.Label + "/" + name, nil
+	}
+
+	if !isHexDigest(table.Hash, sha256.Size) {
+		return "", fmt.Errorf("invalid archive: table %s has malformed hash %q", table.Name, table.Hash)
+	}
+
+	key := objectKey(table.Hash, imp.manifest.Format, compressionFromExtension(name))
+	if path.Base(key) == name {
+		return key, nil
+	}
+
+	return stagingPrefix + imp.manifest.Label + "/" + name, nil
+}
+
+// isHexDigest reports whether s is the lowercase hex encoding of size bytes
+func isHexDigest(s string, size int) bool {
+	if len(s) != 2*size {
+		return false
+	}
+
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// storeObject stores an archive entry under the key and returns its SHA-256.
+// An object that is already in the object store is not overwritten.
+func (imp *snapshotImport) storeObject(ctx context.Context, r io.Reader, key string) (string, error) {
+	hasher := sha256.New()
+
+	exists, err := imp.store.objectExists(ctx, key)
+	if err != nil {
+		return "", err
+	}
+
+	if exists {
+		if _, err := io.Copy(hasher, r); err != nil {
+			return "", fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		return hex.EncodeToString(hasher.Sum(nil)), nil
+	}
+
+	w, err := imp.store.backend.Put(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	imp.written = append(imp.written, key)
+
+	if _, err := io.Copy(io.MultiWriter(w, hasher), r); err != nil {
+		_ = w.Abort()
+
+		return "", fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("failed to write snapshot file: %w", err)
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// moveToObjects hashes a staged payload and moves it to the object store
+func (imp *snapshotImport) moveToObjects(ctx context.Context, table *TableInfo) error {
+	s := imp.store
+	compression := compressionFromExtension(table.Object)
+
+	r, err := s.backend.Get(ctx, table.Object)
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot file: %w", err)
+	}
+	defer r.Close()
+
+	dr, err := decompressReader(r, compression)
+	if err != nil {
+		return err
+	}
+	defer dr.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, dr); err != nil {
+		return fmt.Errorf("table %s is %w: %w", table.Name, ErrCorrupted, err)
+	}
+
+	table.Hash = hex.EncodeToString(hasher.Sum(nil))
+	key := objectKey(table.Hash, imp.manifest.Format, compression)
+
+	if err := s.backend.Rename(ctx, table.Object, key); err != nil {
+		return fmt.Errorf("failed to move snapshot file: %w", err)
+	}
+	imp.written = append(imp.written, key)
+
+	table.Object = key
+
+	return nil
+}
+
+// cleanup removes the staged manifest and the payloads written by a failed
+// import that no other snapshot references
+func (imp *snapshotImport) cleanup(ctx context.Context) {
+	s := imp.store
+	_ = s.backend.Delete(ctx, stagingManifestKey(imp.manifest.Label))
+
+	referenced, err := s.referencedObjects(ctx, nil)
+	if err != nil {
+		return
+	}
+
+	for _, key := range imp.written {
+		if !referenced[key] {
+			_ = s.backend.Delete(ctx, key)
+		}
+	}
+}
+
+// verifyChecksums compares the checksums of the archive entries with the checksum list
+func verifyChecksums(sums []byte, checksums map[string]string) error {
+	if sums == nil {
+		return fmt.Errorf("invalid archive: %s is missing", archiveChecksums)
+	}
+
+	expected := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(sums)), "\n") {
+		sum, name, ok := strings.Cut(line, "  ")
+		if !ok {
+			return fmt.Errorf("invalid archive: malformed %s", archiveChecksums)
+		}
+
+		expected[name] = sum
+	}
+
+	for name, sum := range checksums {
+		if expected[name] != sum {
+			return fmt.Errorf("archive entry %s is %w: checksum mismatch", name, ErrCorrupted)
+		}
+	}
+
+	for name := range expected {
+		if _, ok := checksums[name]; !ok {
+			return fmt.Errorf("invalid archive: %s is missing", name)
+		}
+	}
+
+	return nil
+}
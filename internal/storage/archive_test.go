@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestArchiveCompression(t *testing.T) {
+	tests := []struct {
+		name string
+		want Compression
+	}{
+		{name: "orders.tar", want: CompressionNone},
+		{name: "orders.tar.gz", want: CompressionGzip},
+		{name: "orders.tgz", want: CompressionGzip},
+		{name: "orders.tar.zst", want: CompressionZstd},
+		{name: "orders.tzst", want: CompressionZstd},
+	}
+
+	for _, tt := range tests {
+		if got := ArchiveCompression(tt.name); got != tt.want {
+			t.Errorf("ArchiveCompression(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExportImportSnapshot(t *testing.T) {
+	for _, compression := range []Compression{CompressionNone, CompressionGzip, CompressionZstd} {
+		t.Run(string(compression), func(t *testing.T) {
+			ctx := context.Background()
+			source := newTestStorage(t)
+
+			writeSnapshot(t, source, "a", WriteOptions{Compression: CompressionGzip, Tags: []string{"release"}},
+				map[string][]map[string]any{"users": testRows, "orders": {{"id": "1"}}})
+
+			var archive bytes.Buffer
+			if err := source.ExportSnapshot(ctx, "a", &archive, compression); err != nil {
+				t.Fatal(err)
+			}
+
+			target := newTestStorage(t)
+			data := archive.Bytes()
+
+			// Imported under the original label, then under a new one sharing its payloads
+			for _, label := range []string{"", "restored"} {
+				if _, err := target.ImportSnapshot(ctx, bytes.NewReader(data), compression, label); err != nil {
+					t.Fatalf("import %q: %v", label, err)
+				}
+			}
+
+			if _, err := target.ImportSnapshot(ctx, bytes.NewReader(data), compression, "restored"); err == nil {
+				t.Errorf("expected an error importing over an existing snapshot")
+			}
+
+			for _, label := range []string{"a", "restored"} {
+				manifest, err := target.LoadManifest(ctx, label)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !reflect.DeepEqual(manifest.Tags, []string{"release"}) {
+					t.Errorf("snapshot %s: tags = %v, want [release]", label, manifest.Tags)
+				}
+
+				rows, err := target.ReadTable(ctx, manifest, "users")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !reflect.DeepEqual(rows, testRows) {
+					t.Errorf("snapshot %s: rows = %v, want %v", label, rows, testRows)
+				}
+			}
+		})
+	}
+}
+
+func TestImportTamperedArchive(t *testing.T) {
+	ctx := context.Background()
+	source := newTestStorage(t)
+
+	writeSnapshot(t, source, "a", WriteOptions{}, map[string][]map[string]any{"users": testRows})
+
+	var archive bytes.Buffer
+	if err := source.ExportSnapshot(ctx, "a", &archive, CompressionNone); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rewrite the archive with a payload that no longer matches its checksum
+	var tampered bytes.Buffer
+	tr := tar.NewReader(&archive)
+	tw := tar.NewWriter(&tampered)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.HasPrefix(header.Name, archiveObjectsDir) {
+			data = bytes.Replace(data, []byte("a@example.com"), []byte("b@example.com"), 1)
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	target := newTestStorage(t)
+	if _, err := target.ImportSnapshot(ctx, &tampered, CompressionNone, ""); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("import error = %v, want ErrCorrupted", err)
+	}
+
+	// Nothing of the failed import is left behind
+	objects, err := target.backend.List(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 0 {
+		t.Errorf("objects = %v, want none", objects)
+	}
+}
+
+func TestImportCraftedArchive(t *testing.T) {
+	tests := []struct {
+		name        string
+		hash        string
+		wantCorrupt bool
+	}{
+		{name: "path in hash", hash: "../../../outside"},
+		{name: "short hash", hash: "abcd"},
+		{name: "uppercase hash", hash: strings.Repeat("AB", 32)},
+		{name: "hash of other content", hash: strings.Repeat("ab", 32), wantCorrupt: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			source := newTestStorage(t)
+
+			writeSnapshot(t, source, "a", WriteOptions{}, map[string][]map[string]any{"users": testRows})
+
+			manifest, err := source.LoadManifest(ctx, "a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			table, _ := manifest.Table("users")
+
+			var archive bytes.Buffer
+			if err := source.ExportSnapshot(ctx, "a", &archive, CompressionNone); err != nil {
+				t.Fatal(err)
+			}
+
+			crafted := replaceInArchive(t, archive.Bytes(), table.Hash, tt.hash)
+
+			target := newTestStorage(t)
+			_, err = target.ImportSnapshot(ctx, bytes.NewReader(crafted), CompressionNone, "")
+			if err == nil {
+				t.Fatal("expected an error importing a crafted archive")
+			}
+			if tt.wantCorrupt && !errors.Is(err, ErrCorrupted) {
+				t.Errorf("import error = %v, want ErrCorrupted", err)
+			}
+
+			objects, err := target.backend.List(ctx, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(objects) != 0 {
+				t.Errorf("objects = %v, want none", objects)
+			}
+		})
+	}
+}
+
+// replaceInArchive replaces old with new in the names and contents of the
+// archive entries and rewrites the checksum list to match
+func replaceInArchive(t *testing.T, archive []byte, old, new string) []byte {
+	t.Helper()
+
+	var result bytes.Buffer
+	var sums strings.Builder
+	tr := tar.NewReader(bytes.NewReader(archive))
+	tw := tar.NewWriter(&result)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		header.Name = strings.ReplaceAll(header.Name, old, new)
+		if header.Name == archiveChecksums {
+			data = []byte(sums.String())
+		} else {
+			data = bytes.ReplaceAll(data, []byte(old), []byte(new))
+			sum := sha256.Sum256(data)
+			_, _ = fmt.Fprintf(&sums, "%s  %s\n", hex.EncodeToString(sum[:]), header.Name)
+		}
+		header.Size = int64(len(data))
+
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return result.Bytes()
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when an object does not exist
+var ErrNotFound = errors.New("object not found")
+
+// ErrExists is returned when an object that must be new already exists
+var ErrExists = errors.New("object already exists")
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Writer writes an object. Close commits the object, Abort discards it.
+type Writer interface {
+	io.Writer
+
+	Close() error
+	Abort() error
+}
+
+// Backend stores objects addressed by slash-separated keys
+type Backend interface {
+	// Put returns a writer for the object; the object becomes visible when the writer is closed
+	Put(ctx context.Context, key string) (Writer, error)
+
+	// Get returns a reader for the object, or ErrNotFound
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// List returns all objects whose keys start with prefix, sorted by key
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+
+	// Delete removes the object; deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+
+	// Rename moves an object to a new key, atomically replacing any object there
+	Rename(ctx context.Context, from, to string) error
+
+	// CreateExclusive creates a small object only if it does not exist yet, or returns ErrExists
+	CreateExclusive(ctx context.Context, key string, data []byte) error
+}
+
+// OpenBackend creates a backend from a location, which is either a local
+// directory or an s3://bucket/prefix URL
+func OpenBackend(location string) (Backend, error) {
+	if !strings.HasPrefix(location, "s3://") {
+		return NewLocalBackend(location)
+	}
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid store URL: %w", err)
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid store URL %q: bucket is required", location)
+	}
+
+	query := u.Query()
+
+	return NewS3Backend(S3Config{
+		Bucket:   u.Host,
+		Prefix:   strings.Trim(u.Path, "/"),
+		Endpoint: query.Get("endpoint"),
+		Region:   query.Get("region"),
+		Insecure: query.Get("insecure") == "true",
+	})
+}
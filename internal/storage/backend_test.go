@@ -0,0 +1,255 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+// testBackends returns a fresh backend of each kind by name
+func testBackends(t *testing.T) map[string]Backend {
+	t.Helper()
+
+	local, err := NewLocalBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]Backend{
+		"local":       local,
+		"s3":          newFakeS3Backend(t, "snapshots", ""),
+		"s3 prefixed": newFakeS3Backend(t, "snapshots", "team/db"),
+	}
+}
+
+// putObject writes data to the key of the backend
+func putObject(t *testing.T, backend Backend, key, data string) {
+	t.Helper()
+
+	w, err := backend.Put(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := io.WriteString(w, data); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// getObject returns the data stored at the key of the backend
+func getObject(t *testing.T, backend Backend, key string) (string, error) {
+	t.Helper()
+
+	r, err := backend.Get(context.Background(), key)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data), nil
+}
+
+// listKeys returns the keys of the objects under prefix
+func listKeys(t *testing.T, backend Backend, prefix string) []string {
+	t.Helper()
+
+	objects, err := backend.List(context.Background(), prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		keys = append(keys, obj.Key)
+	}
+
+	return keys
+}
+
+func TestBackend(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, backend Backend)
+	}{
+		{
+			name: "put and get",
+			run: func(t *testing.T, backend Backend) {
+				putObject(t, backend, "objects/ab/cd", "payload")
+
+				data, err := getObject(t, backend, "objects/ab/cd")
+				if err != nil || data != "payload" {
+					t.Errorf("get = %q, %v, want payload", data, err)
+				}
+			},
+		},
+		{
+			name: "get missing",
+			run: func(t *testing.T, backend Backend) {
+				if _, err := getObject(t, backend, "objects/missing"); !errors.Is(err, ErrNotFound) {
+					t.Errorf("get error = %v, want ErrNotFound", err)
+				}
+			},
+		},
+		{
+			name: "aborted put creates nothing",
+			run: func(t *testing.T, backend Backend) {
+				w, err := backend.Put(context.Background(), "objects/aborted")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if _, err := io.WriteString(w, "partial"); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := w.Abort(); err != nil {
+					t.Fatal(err)
+				}
+
+				if keys := listKeys(t, backend, ""); len(keys) != 0 {
+					t.Errorf("keys = %v, want none", keys)
+				}
+			},
+		},
+		{
+			name: "list under prefix",
+			run: func(t *testing.T, backend Backend) {
+				for _, key := range []string{"snapshots/b/manifest.json", "snapshots/a/manifest.json", "staging/a/manifest.json", "snapshots-old"} {
+					putObject(t, backend, key, key)
+				}
+
+				got := listKeys(t, backend, "snapshots/")
+				want := []string{"snapshots/a/manifest.json", "snapshots/b/manifest.json"}
+				if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+					t.Errorf("keys = %v, want %v", got, want)
+				}
+
+				objects, err := backend.List(context.Background(), "snapshots/a/")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if len(objects) != 1 || objects[0].Size != int64(len("snapshots/a/manifest.json")) || objects[0].ModTime.IsZero() {
+					t.Errorf("objects = %+v, want one with its size and time", objects)
+				}
+			},
+		},
+		{
+			name: "delete",
+			run: func(t *testing.T, backend Backend) {
+				putObject(t, backend, "objects/ab/cd", "payload")
+
+				if err := backend.Delete(context.Background(), "objects/ab/cd"); err != nil {
+					t.Fatal(err)
+				}
+
+				if _, err := getObject(t, backend, "objects/ab/cd"); !errors.Is(err, ErrNotFound) {
+					t.Errorf("get error = %v, want ErrNotFound", err)
+				}
+
+				// Deleting a missing object is not an error
+				if err := backend.Delete(context.Background(), "objects/ab/cd"); err != nil {
+					t.Errorf("delete missing: %v", err)
+				}
+			},
+		},
+		{
+			name: "rename replaces the target",
+			run: func(t *testing.T, backend Backend) {
+				putObject(t, backend, "staging/a/manifest.json", "new")
+				putObject(t, backend, "snapshots/a/manifest.json", "old")
+
+				if err := backend.Rename(context.Background(), "staging/a/manifest.json", "snapshots/a/manifest.json"); err != nil {
+					t.Fatal(err)
+				}
+
+				data, err := getObject(t, backend, "snapshots/a/manifest.json")
+				if err != nil || data != "new" {
+					t.Errorf("get = %q, %v, want new", data, err)
+				}
+
+				if keys := listKeys(t, backend, "staging/"); len(keys) != 0 {
+					t.Errorf("keys = %v, want none", keys)
+				}
+			},
+		},
+		{
+			name: "rename missing",
+			run: func(t *testing.T, backend Backend) {
+				if err := backend.Rename(context.Background(), "staging/a/manifest.json", "snapshots/a/manifest.json"); !errors.Is(err, ErrNotFound) {
+					t.Errorf("rename error = %v, want ErrNotFound", err)
+				}
+			},
+		},
+		{
+			name: "create exclusive",
+			run: func(t *testing.T, backend Backend) {
+				ctx := context.Background()
+
+				if err := backend.CreateExclusive(ctx, "locks/a.lock", []byte("first")); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := backend.CreateExclusive(ctx, "locks/a.lock", []byte("second")); !errors.Is(err, ErrExists) {
+					t.Errorf("second create error = %v, want ErrExists", err)
+				}
+
+				data, err := getObject(t, backend, "locks/a.lock")
+				if err != nil || data != "first" {
+					t.Errorf("get = %q, %v, want first", data, err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, backend := range testBackends(t) {
+				t.Run(name, func(t *testing.T) {
+					tt.run(t, backend)
+				})
+			}
+		})
+	}
+}
+
+func TestSnapshotOnBackends(t *testing.T) {
+	ctx := context.Background()
+
+	for name, backend := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			store := New(backend)
+
+			writeSnapshot(t, store, "a", WriteOptions{Compression: CompressionGzip}, map[string][]map[string]any{"users": testRows})
+
+			labels, err := store.ListSnapshots(ctx)
+			if err != nil || len(labels) != 1 || labels[0] != "a" {
+				t.Fatalf("snapshots = %v, %v, want [a]", labels, err)
+			}
+
+			rows, err := store.LoadRows(ctx, "a", "users")
+			if err != nil || len(rows) != len(testRows) {
+				t.Errorf("rows = %v, %v, want %d rows", rows, err, len(testRows))
+			}
+
+			if err := store.DeleteSnapshot(ctx, "a"); err != nil {
+				t.Fatal(err)
+			}
+
+			// The lock and the unshared payload are gone with the snapshot
+			if keys := listKeys(t, backend, ""); len(keys) != 0 {
+				t.Errorf("keys = %v, want none", keys)
+			}
+		})
+	}
+}
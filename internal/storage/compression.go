@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression represents the compression of snapshot table files
+type Compression string
+
+const (
+	CompressionNone Compression = "none"
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+// ParseCompression parses a compression name; an empty name means no compression
+func ParseCompression(name string) (Compression, error) {
+	switch Compression(strings.ToLower(name)) {
+	case "", CompressionNone:
+		return CompressionNone, nil
+	case CompressionGzip, "gz":
+		return CompressionGzip, nil
+	case CompressionZstd, "zst":
+		return CompressionZstd, nil
+	default:
+		return "", fmt.Errorf("unsupported compression: %s", name)
+	}
+}
+
+// extension returns the file extension suffix for the compression
+func (c Compression) extension() string {
+	switch c {
+	case CompressionGzip:
+		return ".gz"
+	case CompressionZstd:
+		return ".zst"
+	default:
+		return ""
+	}
+}
+
+// compressionFromExtension detects the compression of a file by its name
+func compressionFromExtension(name string) Compression {
+	name = strings.TrimSuffix(name, encryptedExtension)
+
+	switch {
+	case strings.HasSuffix(name, CompressionGzip.extension()):
+		return CompressionGzip
+	case strings.HasSuffix(name, CompressionZstd.extension()):
+		return CompressionZstd
+	default:
+		return CompressionNone
+	}
+}
+
+// compressWriter wraps w so that data written to it is compressed.
+// Closing the returned writer flushes it but does not close w.
+func compressWriter(w io.Writer, c Compression) (io.WriteCloser, error) {
+	switch c {
+	case "", CompressionNone:
+		return nopWriteCloser{w}, nil
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		encoder, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd writer: %w", err)
+		}
+
+		return encoder, nil
+	default:
+		return nil, fmt.Errorf("unsupported compression: %s", c)
+	}
+}
+
+// decompressReader wraps r so that data read from it is decompressed.
+// Closing the returned reader does not close r.
+func decompressReader(r io.Reader, c Compression) (io.ReadCloser, error) {
+	switch c {
+	case "", CompressionNone:
+		return io.NopCloser(r), nil
+	case CompressionGzip:
+		reader, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+		}
+
+		return reader, nil
+	case CompressionZstd:
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd reader: %w", err)
+		}
+
+		return decoder.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression: %s", c)
+	}
+}
+
+// nopWriteCloser adds a no-op Close to a writer
+type nopWriteCloser struct {
+	io.Writer
+}
+
+// Close does nothing
+func (nopWriteCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCompression(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Compression
+		wantErr bool
+	}{
+		{name: "", want: CompressionNone},
+		{name: "none", want: CompressionNone},
+		{name: "gzip", want: CompressionGzip},
+		{name: "GZ", want: CompressionGzip},
+		{name: "zstd", want: CompressionZstd},
+		{name: "zst", want: CompressionZstd},
+		{name: "lz4", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseCompression(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseCompression(%q) = %q, %v, want %q, error %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestCompressionRoundTrip(t *testing.T) {
+	data := []byte(strings.Repeat(`{"id": 1, "status": "active"}`, 100))
+
+	tests := []struct {
+		compression Compression
+		magic       []byte
+	}{
+		{compression: CompressionNone, magic: data[:2]},
+		{compression: CompressionGzip, magic: []byte{0x1f, 0x8b}},
+		{compression: CompressionZstd, magic: []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.compression), func(t *testing.T) {
+			var buf bytes.Buffer
+
+			w, err := compressWriter(&buf, tt.compression)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := w.Write(data); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.HasPrefix(buf.Bytes(), tt.magic) {
+				t.Errorf("compressed data starts with %x, want %x", buf.Bytes()[:len(tt.magic)], tt.magic)
+			}
+
+			r, err := decompressReader(&buf, tt.compression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(got, data) {
+				t.Errorf("round trip = %q, want the original data", got)
+			}
+		})
+	}
+}
+
+func TestCompressedSnapshots(t *testing.T) {
+	ctx := context.Background()
+
+	store, err := NewStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := []map[string]any{
+		{"id": float64(1), "name": "Ann", "tags": []any{"a", "b"}},
+		{"id": float64(2), "name": "Bob", "tags": nil},
+	}
+
+	// Snapshots with different compression live side by side in one store
+	compressions := map[string]Compression{"plain": CompressionNone, "gz": CompressionGzip, "zst": CompressionZstd}
+
+	for label, compression := range compressions {
+		w, err := store.CreateSnapshot(ctx, label, WriteOptions{Compression: compression})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := w.WriteTable(ctx, TableInfo{Name: "users"}, rows); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := w.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for label, compression := range compressions {
+		t.Run(label, func(t *testing.T) {
+			manifest, err := store.LoadManifest(ctx, label)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if manifest.Compression != compression {
+				t.Errorf("manifest compression = %q, want %q", manifest.Compression, compression)
+			}
+
+			got, err := store.LoadRows(ctx, label, "users")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, rows) {
+				t.Errorf("rows = %v, want %v", got, rows)
+			}
+		})
+	}
+}
@@ -0,0 +1,372 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"filippo.io/age"
+
+	"github.com/rom8726/snapdiff/internal/mask"
+)
+
+const (
+	// IdentityEnv is the environment variable naming the age identity file used to decrypt snapshots
+	IdentityEnv = "SNAPDIFF_IDENTITY"
+	// PassphraseEnv is the environment variable holding the passphrase of passphrase-encrypted snapshots
+	PassphraseEnv = "SNAPDIFF_PASSPHRASE"
+	// PassphraseRecipient is the recipient that encrypts a snapshot with the passphrase from PassphraseEnv
+	PassphraseRecipient = "passphrase"
+)
+
+// encryptedExtension is the file extension suffix of encrypted table payloads
+const encryptedExtension = ".age"
+
+// encryptedObjectIDSize is the number of random bytes naming an encrypted table payload
+const encryptedObjectIDSize = 16
+
+// ErrNoIdentity is returned when an encrypted snapshot is read without an identity that can decrypt it
+var ErrNoIdentity = errors.New("no matching identity")
+
+// Encryption describes how a snapshot is encrypted. The stored manifest keeps
+// only what is needed to manage the snapshot in the clear; its source,
+// tables, masking and signature are sealed.
+type Encryption struct {
+	Recipients []string `json:"recipients"`       // age recipients the snapshot is encrypted to, or "passphrase"
+	Objects    []string `json:"objects"`          // Keys of the table payloads, so they stay referenced while sealed
+	Sealed     string   `json:"sealed,omitempty"` // age-encrypted sealed fields, base64-encoded; empty while staging
+}
+
+// sealedManifest contains the fields of an encrypted manifest that are sealed
+type sealedManifest struct {
+	Source    string            `json:"source,omitempty"`
+	Tables    []TableInfo       `json:"tables"`
+	Masking   *mask.Policy      `json:"masking,omitempty"`
+	Signature *Signature        `json:"signature,omitempty"`
+	Key       string            `json:"key"`              // Per-snapshot age identity the table payloads are encrypted to
+	Reused    map[string]string `json:"reused,omitempty"` // Identities of payloads reused from other snapshots, by object key
+}
+
+// parseRecipients parses age recipients: public keys ("age1..."), files
+// listing public keys, or "passphrase" to use the passphrase from PassphraseEnv
+func parseRecipients(specs []string) ([]age.Recipient, []string, error) {
+	var recipients []age.Recipient
+	var names []string
+
+	for _, spec := range specs {
+		switch {
+		case spec == PassphraseRecipient:
+			if len(specs) > 1 {
+				return nil, nil, fmt.Errorf("a passphrase can't be combined with other recipients")
+			}
+
+			passphrase := os.Getenv(PassphraseEnv)
+			if passphrase == "" {
+				return nil, nil, fmt.Errorf("$%s is required to encrypt with a passphrase", PassphraseEnv)
+			}
+
+			recipient, err := age.NewScryptRecipient(passphrase)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to create passphrase recipient: %w", err)
+			}
+
+			recipients = append(recipients, recipient)
+			names = append(names, PassphraseRecipient)
+		case strings.HasPrefix(spec, "age1"):
+			recipient, err := age.ParseX25519Recipient(spec)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid recipient %s: %w", spec, err)
+			}
+
+			recipients = append(recipients, recipient)
+			names = append(names, recipient.String())
+		default:
+			data, err := os.ReadFile(spec)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to read recipients file: %w", err)
+			}
+
+			parsed, err := age.ParseRecipients(bytes.NewReader(data))
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to parse recipients file %s: %w", spec, err)
+			}
+
+			for _, recipient := range parsed {
+				recipients = append(recipients, recipient)
+				names = append(names, fmt.Sprint(recipient))
+			}
+		}
+	}
+
+	return recipients, names, nil
+}
+
+// UseIdentity makes the storage decrypt snapshots with the age identities in
+// a file. Without one, the file named by IdentityEnv is used. The passphrase
+// from PassphraseEnv is tried in addition to the identities.
+func (s *Storage) UseIdentity(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	identities, err := readIdentities(path)
+	if err != nil {
+		return err
+	}
+
+	s.identities = identities
+
+	return nil
+}
+
+// decryptionIdentities returns the identities to decrypt snapshots with
+func (s *Storage) decryptionIdentities() ([]age.Identity, error) {
+	identities := s.identities
+
+	if identities == nil {
+		if path := os.Getenv(IdentityEnv); path != "" {
+			var err error
+			identities, err = readIdentities(path)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if passphrase := os.Getenv(PassphraseEnv); passphrase != "" {
+		identity, err := age.NewScryptIdentity(passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create passphrase identity: %w", err)
+		}
+
+		identities = append(slices.Clip(identities), identity)
+	}
+
+	return identities, nil
+}
+
+// readIdentities reads the age identities in a file
+func readIdentities(path string) ([]age.Identity, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity: %w", err)
+	}
+
+	identities, err := age.ParseIdentities(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse identity %s: %w", path, err)
+	}
+
+	return identities, nil
+}
+
+// encryptManifest prepares a new manifest for encryption: its table payloads
+// are encrypted to a fresh key that is sealed together with the tables
+func (m *Manifest) encryptManifest(names []string) error {
+	key, err := age.GenerateX25519Identity()
+	if err != nil {
+		return fmt.Errorf("failed to generate snapshot key: %w", err)
+	}
+
+	m.Encryption = &Encryption{Recipients: names, Objects: []string{}}
+	m.dataKey = key
+
+	return nil
+}
+
+// seal encrypts the sealed fields of the manifest to the recipients
+func (m *Manifest) seal(recipients []age.Recipient) error {
+	sealed := sealedManifest{
+		Source:    m.Source,
+		Tables:    m.Tables,
+		Masking:   m.Masking,
+		Signature: m.Signature,
+		Key:       m.dataKey.String(),
+	}
+
+	if len(m.reusedKeys) > 0 {
+		sealed.Reused = make(map[string]string, len(m.reusedKeys))
+		for object, key := range m.reusedKeys {
+			sealed.Reused[object] = key.String()
+		}
+	}
+
+	data, err := json.Marshal(sealed)
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, recipients...)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt manifest: %w", err)
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to encrypt manifest: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to encrypt manifest: %w", err)
+	}
+
+	m.Encryption.Sealed = base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	return nil
+}
+
+// unseal decrypts the sealed fields of a manifest read from the store
+func (s *Storage) unseal(m *Manifest) error {
+	if !m.sealed {
+		return nil
+	}
+
+	if m.Encryption.Sealed == "" {
+		return fmt.Errorf("snapshot %s is incomplete", m.Label)
+	}
+
+	identities, err := s.decryptionIdentities()
+	if err != nil {
+		return err
+	}
+
+	noIdentity := fmt.Errorf("snapshot %s is encrypted: %w; pass an age identity file with --identity or $%s, or the passphrase in $%s",
+		m.Label, ErrNoIdentity, IdentityEnv, PassphraseEnv)
+	if len(identities) == 0 {
+		return noIdentity
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(m.Encryption.Sealed)
+	if err != nil {
+		return fmt.Errorf("snapshot %s is %w: malformed sealed manifest", m.Label, ErrCorrupted)
+	}
+
+	r, err := age.Decrypt(bytes.NewReader(ciphertext), identities...)
+	if err != nil {
+		var noMatch *age.NoIdentityMatchError
+		if errors.As(err, &noMatch) {
+			return noIdentity
+		}
+
+		return fmt.Errorf("failed to decrypt snapshot %s: %w", m.Label, err)
+	}
+
+	var sealed sealedManifest
+	if err := json.NewDecoder(r).Decode(&sealed); err != nil {
+		return fmt.Errorf("snapshot %s is %w: failed to decrypt manifest: %w", m.Label, ErrCorrupted, err)
+	}
+
+	key, err := age.ParseX25519Identity(sealed.Key)
+	if err != nil {
+		return fmt.Errorf("snapshot %s is %w: invalid snapshot key", m.Label, ErrCorrupted)
+	}
+
+	var reusedKeys map[string]*age.X25519Identity
+	if len(sealed.Reused) > 0 {
+		reusedKeys = make(map[string]*age.X25519Identity, len(sealed.Reused))
+		for object, reused := range sealed.Reused {
+			reusedKey, err := age.ParseX25519Identity(reused)
+			if err != nil {
+				return fmt.Errorf("snapshot %s is %w: invalid key of object %s", m.Label, ErrCorrupted, object)
+			}
+
+			reusedKeys[object] = reusedKey
+		}
+	}
+
+	m.Source = sealed.Source
+	m.Tables = sealed.Tables
+	m.Masking = sealed.Masking
+	m.Signature = sealed.Signature
+	m.dataKey = key
+	m.reusedKeys = reusedKeys
+	m.sealed = false
+
+	return nil
+}
+
+// Sealed reports whether the manifest is encrypted and its sealed fields
+// have not been decrypted
+func (m *Manifest) Sealed() bool {
+	return m.sealed
+}
+
+// storedView returns the manifest as it is stored, without the sealed fields
+// if it is encrypted
+func (m *Manifest) storedView() *Manifest {
+	if m.Encryption == nil {
+		return m
+	}
+
+	stored := *m
+	stored.Source = ""
+	stored.Tables = nil
+	stored.Masking = nil
+	stored.Signature = nil
+
+	return &stored
+}
+
+// objectKeys returns the keys of the table payloads the manifest references
+func (m *Manifest) objectKeys() []string {
+	if m.Encryption != nil {
+		return m.Encryption.Objects
+	}
+
+	keys := make([]string, 0, len(m.Tables))
+	for _, table := range m.Tables {
+		keys = append(keys, table.Object)
+	}
+
+	return keys
+}
+
+// decryptTable returns a reader of the decrypted payload of a table
+func (m *Manifest) decryptTable(r io.Reader, table *TableInfo) (io.Reader, error) {
+	if !strings.HasSuffix(table.Object, encryptedExtension) {
+		return r, nil
+	}
+
+	key := m.payloadKey(table.Object)
+	if key == nil {
+		return nil, fmt.Errorf("table %s of snapshot %s is encrypted: %w", table.Name, m.Label, ErrNoIdentity)
+	}
+
+	dr, err := age.Decrypt(r, key)
+	if err != nil {
+		return nil, fmt.Errorf("table %s of snapshot %s is %w: %w", table.Name, m.Label, ErrCorrupted, err)
+	}
+
+	return dr, nil
+}
+
+// payloadKey returns the identity an encrypted payload of the snapshot is
+// encrypted to, or nil if the manifest has not been decrypted
+func (m *Manifest) payloadKey(object string) *age.X25519Identity {
+	if key, ok := m.reusedKeys[object]; ok {
+		return key
+	}
+
+	return m.dataKey
+}
+
+// encryptedObjectKey returns a new random key for an encrypted table payload.
+// Encrypted payloads are not addressed by content, which would reveal equal tables.
+func encryptedObjectKey(format SnapshotFormat, compression Compression) (string, error) {
+	id := make([]byte, encryptedObjectIDSize)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate object key: %w", err)
+	}
+
+	name := hex.EncodeToString(id)
+
+	return fmt.Sprintf("%s%s/%s.%s%s%s", objectsPrefix, name[:2], name, format, compression.extension(), encryptedExtension), nil
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+// newTestIdentity generates an age identity, writes it to a file and
+// returns the file and the recipient of the identity
+func newTestIdentity(t *testing.T) (string, string) {
+	t.Helper()
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "identity.txt")
+	if err := os.WriteFile(path, []byte(identity.String()+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path, identity.Recipient().String()
+}
+
+func TestParseRecipients(t *testing.T) {
+	_, recipient := newTestIdentity(t)
+
+	recipientsFile := filepath.Join(t.TempDir(), "recipients.txt")
+	if err := os.WriteFile(recipientsFile, []byte("# team\n"+recipient+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(PassphraseEnv, "secret")
+
+	tests := []struct {
+		name      string
+		specs     []string
+		wantNames []string
+		wantErr   bool
+	}{
+		{name: "public key", specs: []string{recipient}, wantNames: []string{recipient}},
+		{name: "recipients file", specs: []string{recipientsFile}, wantNames: []string{recipient}},
+		{name: "passphrase", specs: []string{PassphraseRecipient}, wantNames: []string{PassphraseRecipient}},
+		{name: "passphrase with a key", specs: []string{PassphraseRecipient, recipient}, wantErr: true},
+		{name: "invalid key", specs: []string{"age1invalid"}, wantErr: true},
+		{name: "missing file", specs: []string{filepath.Join(t.TempDir(), "missing.txt")}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recipients, names, err := parseRecipients(tt.specs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parse error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if len(recipients) != len(tt.wantNames) || strings.Join(names, ",") != strings.Join(tt.wantNames, ",") {
+				t.Errorf("names = %v, want %v", names, tt.wantNames)
+			}
+		})
+	}
+
+	t.Setenv(PassphraseEnv, "")
+	if _, _, err := parseRecipients([]string{PassphraseRecipient}); err == nil {
+		t.Errorf("expected an error encrypting with a passphrase without %s", PassphraseEnv)
+	}
+}
+
+func TestEncryptedSnapshot(t *testing.T) {
+	t.Setenv(IdentityEnv, "")
+	t.Setenv(PassphraseEnv, "")
+
+	ctx := context.Background()
+	store := newTestStorage(t)
+	identityFile, recipient := newTestIdentity(t)
+	otherIdentityFile, _ := newTestIdentity(t)
+
+	writeSnapshot(t, store, "a", WriteOptions{Recipients: []string{recipient}, Source: "postgres://db/app"},
+		map[string][]map[string]any{"users": testRows})
+
+	// Only what is needed to manage the snapshot is stored in the clear
+	stored, err := store.LoadMetadata(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stored.Sealed() || stored.Source != "" || len(stored.Tables) != 0 || len(stored.Encryption.Objects) != 1 {
+		t.Errorf("stored manifest = %+v, want it sealed", stored)
+	}
+
+	r, err := store.backend.Get(ctx, stored.Encryption.Objects[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := io.ReadAll(r)
+	_ = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(payload), "a@example.com") {
+		t.Errorf("payload is stored in the clear")
+	}
+
+	if _, err := store.LoadManifest(ctx, "a"); !errors.Is(err, ErrNoIdentity) {
+		t.Errorf("load without identity error = %v, want ErrNoIdentity", err)
+	}
+
+	if err := store.UseIdentity(otherIdentityFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.LoadManifest(ctx, "a"); !errors.Is(err, ErrNoIdentity) {
+		t.Errorf("load with another identity error = %v, want ErrNoIdentity", err)
+	}
+
+	if err := store.UseIdentity(identityFile); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := store.LoadManifest(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Source != "postgres://db/app" {
+		t.Errorf("source = %q, want it unsealed", manifest.Source)
+	}
+
+	rows, err := store.ReadTable(ctx, manifest, "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(testRows) {
+		t.Errorf("rows = %v, want %v", rows, testRows)
+	}
+}
+
+func TestPassphraseEncryptedSnapshot(t *testing.T) {
+	t.Setenv(IdentityEnv, "")
+	t.Setenv(PassphraseEnv, "correct horse")
+
+	ctx := context.Background()
+	store := newTestStorage(t)
+
+	writeSnapshot(t, store, "a", WriteOptions{Recipients: []string{PassphraseRecipient}},
+		map[string][]map[string]any{"users": testRows})
+
+	if _, err := store.LoadRows(ctx, "a", "users"); err != nil {
+		t.Errorf("read with the passphrase: %v", err)
+	}
+
+	t.Setenv(PassphraseEnv, "")
+	if _, err := store.LoadRows(ctx, "a", "users"); !errors.Is(err, ErrNoIdentity) {
+		t.Errorf("read without the passphrase error = %v, want ErrNoIdentity", err)
+	}
+}
@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// orphanGracePeriod is how long an unreferenced object is kept, since a
+// writer stores table payloads before it records them in its manifest
+const orphanGracePeriod = time.Hour
+
+// GCOptions contains the retention policy for garbage collection. A
+// snapshot is removed if any policy selects it and it is not protected.
+type GCOptions struct {
+	KeepLast   int           // Remove all but the N most recent snapshots (0 disables)
+	OlderThan  time.Duration // Remove snapshots older than this (0 disables)
+	KeepTagged bool          // Never remove snapshots that have tags
+	DryRun     bool          // Only report what would be removed
+}
+
+// GCReport describes what garbage collection removed
+type GCReport struct {
+	Snapshots []string // Removed snapshot labels
+	Staging   []string // Labels of abandoned partial snapshots that were removed
+	Objects   int      // Number of removed table payloads
+	Bytes     int64    // Storage freed
+}
+
+// GC removes snapshots selected by the retention policy, partial snapshots
+// left behind by crashed writers and table payloads no snapshot references
+func (s *Storage) GC(ctx context.Context, opts GCOptions) (*GCReport, error) {
+	report := &GCReport{}
+
+	victims, err := s.gcVictims(ctx, opts, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	stale, err := s.staleStaging(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Objects referenced before anything is removed; unreferenced ones are orphans
+	before, err := s.referencedObjects(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	skip := make(map[string]bool)
+	for _, label := range victims {
+		skip[manifestKey(label)] = true
+	}
+	for _, label := range stale {
+		skip[stagingManifestKey(label)] = true
+	}
+
+	if !opts.DryRun {
+		for _, label := range victims {
+			freed, err := s.removeSnapshot(ctx, label)
+			if err != nil {
+				return report, err
+			}
+
+			report.Snapshots = append(report.Snapshots, label)
+			report.Bytes += freed
+		}
+
+		for _, label := range stale {
+			if err := s.backend.Delete(ctx, stagingManifestKey(label)); err != nil {
+				return report, fmt.Errorf("failed to delete staging manifest: %w", err)
+			}
+
+			report.Staging = append(report.Staging, label)
+		}
+	} else {
+		report.Snapshots = victims
+		report.Staging = stale
+
+		for _, label := range victims {
+			objects, err := s.backend.List(ctx, snapshotPrefix(label))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read snapshot directory: %w", err)
+			}
+
+			for _, obj := range objects {
+				report.Bytes += obj.Size
+			}
+		}
+	}
+
+	// Re-read the references after removal, so that snapshots written in the meantime keep their objects
+	after, err := s.referencedObjects(ctx, skip)
+	if err != nil {
+		return report, err
+	}
+
+	objects, err := s.backend.List(ctx, objectsPrefix)
+	if err != nil {
+		return report, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	cutoff := time.Now().Add(-orphanGracePeriod)
+	for _, obj := range objects {
+		if after[obj.Key] || (!before[obj.Key] && obj.ModTime.After(cutoff)) {
+			continue
+		}
+
+		if !opts.DryRun {
+			if err := s.backend.Delete(ctx, obj.Key); err != nil {
+				return report, fmt.Errorf("failed to delete snapshot object: %w", err)
+			}
+		}
+
+		report.Objects++
+		report.Bytes += obj.Size
+	}
+
+	return report, nil
+}
+
+// gcVictims returns the labels of the snapshots the retention policy selects
+func (s *Storage) gcVictims(ctx context.Context, opts GCOptions, now time.Time) ([]string, error) {
+	if opts.KeepLast <= 0 && opts.OlderThan <= 0 {
+		return nil, nil
+	}
+
+	labels, err := s.ListSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	manifests := make([]*Manifest, 0, len(labels))
+	for _, label := range labels {
+		manifest, err := s.loadManifest(ctx, label)
+		if err != nil {
+			return nil, err
+		}
+
+		manifests = append(manifests, manifest)
+	}
+
+	// Newest first
+	sort.SliceStable(manifests, func(i, j int) bool {
+		return manifests[i].CreatedAt.After(manifests[j].CreatedAt)
+	})
+
+	var victims []string
+	for i, manifest := range manifests {
+		if opts.KeepTagged && len(manifest.Tags) > 0 {
+			continue
+		}
+
+		expired := opts.OlderThan > 0 && now.Sub(manifest.CreatedAt) > opts.OlderThan
+		surplus := opts.KeepLast > 0 && i >= opts.KeepLast
+
+		if expired || surplus {
+			victims = append(victims, manifest.Label)
+		}
+	}
+
+	sort.Strings(victims)
+
+	return victims, nil
+}
+
+// staleStaging returns the labels of partial snapshots whose writer is gone.
+// A writer holds the label's lock for as long as its staging manifest exists.
+func (s *Storage) staleStaging(ctx context.Context) ([]string, error) {
+	objects, err := s.backend.List(ctx, stagingPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list staging manifests: %w", err)
+	}
+
+	var stale []string
+	for _, obj := range objects {
+		if path.Base(obj.Key) != manifestName {
+			continue
+		}
+
+		label := strings.TrimSuffix(strings.TrimPrefix(obj.Key, stagingPrefix), "/"+manifestName)
+
+		locked, err := s.objectExists(ctx, lockKey(label))
+		if err != nil {
+			return nil, err
+		}
+
+		if !locked {
+			stale = append(stale, label)
+		}
+	}
+
+	return stale, nil
+}
+
+// removeSnapshot deletes the manifest and any legacy table files of a
+// snapshot, leaving shared objects to the sweep, and returns the bytes freed
+func (s *Storage) removeSnapshot(ctx context.Context, label string) (int64, error) {
+	lock, err := s.LockSnapshot(ctx, label)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = lock.Release(ctx) }()
+
+	objects, err := s.snapshotObjects(ctx, label)
+	if err != nil {
+		return 0, err
+	}
+
+	var freed int64
+	for _, obj := range objects {
+		if err := s.backend.Delete(ctx, obj.Key); err != nil {
+			return freed, fmt.Errorf("failed to delete snapshot: %w", err)
+		}
+
+		freed += obj.Size
+	}
+
+	return freed, nil
+}
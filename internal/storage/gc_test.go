@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGCVictims(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStorage(t)
+	now := time.Now()
+
+	// Snapshot ages in days; tagged ones carry a tag
+	ages := map[string]int{"d1": 1, "d2": 2, "d10": 10, "d20": 20}
+	for label, days := range ages {
+		writeSnapshot(t, store, label, WriteOptions{}, map[string][]map[string]any{"users": testRows})
+
+		if err := store.UpdateManifest(ctx, label, func(m *Manifest) error {
+			m.CreatedAt = now.Add(-time.Duration(days) * 24 * time.Hour)
+			if label == "d20" {
+				m.AddTags("release")
+			}
+
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		opts GCOptions
+		want []string
+	}{
+		{name: "no policy", opts: GCOptions{}},
+		{name: "keep last", opts: GCOptions{KeepLast: 2}, want: []string{"d10", "d20"}},
+		{name: "older than", opts: GCOptions{OlderThan: 5 * 24 * time.Hour}, want: []string{"d10", "d20"}},
+		{name: "keep tagged", opts: GCOptions{KeepLast: 1, KeepTagged: true}, want: []string{"d10", "d2"}},
+		{name: "either policy", opts: GCOptions{KeepLast: 3, OlderThan: 36 * time.Hour}, want: []string{"d10", "d2", "d20"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			victims, err := store.gcVictims(ctx, tt.opts, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(victims, tt.want) {
+				t.Errorf("victims = %v, want %v", victims, tt.want)
+			}
+		})
+	}
+}
+
+func TestGC(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store, err := NewStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeSnapshot(t, store, "a", WriteOptions{}, map[string][]map[string]any{"users": testRows})
+
+	// An abandoned partial snapshot, an old orphan and an orphan that may belong to a running writer
+	for key, age := range map[string]time.Duration{
+		stagingManifestKey("crashed"): 0,
+		objectsPrefix + "aa/old.json": 2 * orphanGracePeriod,
+		objectsPrefix + "bb/new.json": 0,
+	} {
+		w, err := store.backend.Put(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, "{}"); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		modTime := time.Now().Add(-age)
+		if err := os.Chtimes(filepath.Join(dir, filepath.FromSlash(key)), modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	listKeys := func() []string {
+		objects, err := store.backend.List(ctx, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		keys := make([]string, 0, len(objects))
+		for _, obj := range objects {
+			keys = append(keys, obj.Key)
+		}
+
+		return keys
+	}
+
+	before := listKeys()
+
+	report, err := store.GC(ctx, GCOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report.Staging, []string{"crashed"}) || report.Objects != 1 {
+		t.Errorf("dry run report = %+v, want the crashed snapshot and one orphan", report)
+	}
+	if keys := listKeys(); !reflect.DeepEqual(keys, before) {
+		t.Errorf("dry run removed objects: %v, want %v", keys, before)
+	}
+
+	if _, err := store.GC(ctx, GCOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := store.LoadManifest(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{manifest.Tables[0].Object, objectsPrefix + "bb/new.json", manifestKey("a")}
+	if keys := listKeys(); !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SnapshotInfo summarizes a snapshot for listings
+type SnapshotInfo struct {
+	Label     string    `json:"label"`
+	CreatedAt time.Time `json:"created_at"`
+	Source    string    `json:"source,omitempty"`
+	Tables    int       `json:"tables"`
+	Rows      int       `json:"rows"` // Total number of rows, -1 if unknown
+	Size      int64     `json:"size"` // Stored size of the table data, including data shared with other snapshots
+	Tags      []string  `json:"tags,omitempty"`
+	Note      string    `json:"note,omitempty"`
+	Signed    bool      `json:"signed"`
+	Encrypted bool      `json:"encrypted"`
+}
+
+// ListSnapshotInfo returns a summary of every snapshot, sorted by label
+func (s *Storage) ListSnapshotInfo(ctx context.Context) ([]SnapshotInfo, error) {
+	labels, err := s.ListSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sizes, err := s.objectSizes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]SnapshotInfo, 0, len(labels))
+	for _, label := range labels {
+		manifest, err := s.loadManifest(ctx, label)
+		if err != nil {
+			return nil, err
+		}
+
+		infos = append(infos, manifest.Info(sizes))
+	}
+
+	return infos, nil
+}
+
+// Info summarizes the manifest, taking table sizes from sizes. The row count
+// of an encrypted snapshot whose manifest is sealed is unknown.
+func (m *Manifest) Info(sizes map[string]int64) SnapshotInfo {
+	info := SnapshotInfo{
+		Label:     m.Label,
+		CreatedAt: m.CreatedAt,
+		Source:    m.Source,
+		Tables:    len(m.Tables),
+		Tags:      m.Tags,
+		Note:      m.Note,
+		Signed:    m.Signature != nil,
+		Encrypted: m.Encryption != nil,
+	}
+
+	if m.sealed {
+		info.Tables = len(m.Encryption.Objects)
+		info.Rows = -1
+		for _, key := range m.Encryption.Objects {
+			info.Size += sizes[key]
+		}
+
+		return info
+	}
+
+	seen := make(map[string]bool)
+	for _, table := range m.Tables {
+		if info.Rows >= 0 {
+			if table.Rows < 0 {
+				info.Rows = -1
+			} else {
+				info.Rows += table.Rows
+			}
+		}
+
+		if !seen[table.Object] {
+			seen[table.Object] = true
+			info.Size += sizes[table.Object]
+		}
+	}
+
+	return info
+}
+
+// TableSizes returns the stored size of every object a manifest references
+func (s *Storage) TableSizes(ctx context.Context, manifest *Manifest) (map[string]int64, error) {
+	sizes := make(map[string]int64, len(manifest.Tables))
+	for _, table := range manifest.Tables {
+		objects, err := s.backend.List(ctx, table.Object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check object %s: %w", table.Object, err)
+		}
+
+		for _, obj := range objects {
+			if obj.Key == table.Object {
+				sizes[obj.Key] = obj.Size
+			}
+		}
+	}
+
+	return sizes, nil
+}
+
+// objectSizes returns the size of every table payload and legacy table file
+func (s *Storage) objectSizes(ctx context.Context) (map[string]int64, error) {
+	sizes := make(map[string]int64)
+
+	for _, prefix := range []string{objectsPrefix, snapshotsPrefix} {
+		objects, err := s.backend.List(ctx, prefix)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+
+		for _, obj := range objects {
+			sizes[obj.Key] = obj.Size
+		}
+	}
+
+	return sizes, nil
+}
@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+)
+
+// RenameSnapshot gives a snapshot a new label. Table payloads shared
+// through the object store are not touched.
+func (s *Storage) RenameSnapshot(ctx context.Context, from, to string) error {
+	if err := checkTargetLabel(from, to); err != nil {
+		return err
+	}
+
+	lock, err := s.LockSnapshot(ctx, from)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = lock.Release(ctx) }()
+
+	if err := s.copySnapshot(ctx, from, to, true); err != nil {
+		return err
+	}
+
+	// What is left is the old manifest; legacy table files were moved
+	objects, err := s.backend.List(ctx, snapshotPrefix(from))
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot directory: %w", err)
+	}
+
+	for _, obj := range objects {
+		if err := s.backend.Delete(ctx, obj.Key); err != nil {
+			return fmt.Errorf("failed to delete snapshot: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// CopySnapshot creates a snapshot with a new label and the same content,
+// tags and notes as an existing one
+func (s *Storage) CopySnapshot(ctx context.Context, from, to string) error {
+	if err := checkTargetLabel(from, to); err != nil {
+		return err
+	}
+
+	lock, err := s.LockSnapshot(ctx, from)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = lock.Release(ctx) }()
+
+	return s.copySnapshot(ctx, from, to, false)
+}
+
+// copySnapshot writes the manifest of snapshot from under the label to.
+// Table files stored inside a legacy snapshot directory are copied along,
+// or moved if the source snapshot is going away. The caller holds the
+// lock of the source snapshot.
+func (s *Storage) copySnapshot(ctx context.Context, from, to string, move bool) error {
+	lock, err := s.LockSnapshot(ctx, to)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = lock.Release(ctx) }()
+
+	existing, err := s.backend.List(ctx, snapshotPrefix(to))
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot directory: %w", err)
+	}
+
+	if len(existing) > 0 {
+		return fmt.Errorf("snapshot already exists: %s", to)
+	}
+
+	manifest, err := s.loadManifest(ctx, from)
+	if err != nil {
+		return err
+	}
+
+	if !manifest.Complete {
+		return fmt.Errorf("snapshot %s is incomplete", from)
+	}
+
+	for i := range manifest.Tables {
+		table := &manifest.Tables[i]
+		if !strings.HasPrefix(table.Object, snapshotPrefix(from)) {
+			continue
+		}
+
+		key := snapshotPrefix(to) + path.Base(table.Object)
+		if move {
+			err = s.backend.Rename(ctx, table.Object, key)
+		} else {
+			err = s.copyObject(ctx, table.Object, key)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to copy table %s: %w", table.Name, err)
+		}
+
+		table.Object = key
+	}
+
+	manifest.Version = manifestVersion
+	manifest.Label = to
+
+	return s.saveManifest(ctx, manifestKey(to), manifest)
+}
+
+// checkTargetLabel checks the label a snapshot is renamed or copied to
+func checkTargetLabel(from, to string) error {
+	if from == to {
+		return fmt.Errorf("source and target snapshot are the same: %s", from)
+	}
+
+	return validateLabel(to)
+}
+
+// validateLabel checks that a label can be used as a snapshot name
+func validateLabel(label string) error {
+	if label == "" || label == "." || label == ".." || strings.ContainsAny(label, `/\`) {
+		return fmt.Errorf("invalid snapshot label: %q", label)
+	}
+
+	return nil
+}
+
+// copyObject copies the content of an object to a new key
+func (s *Storage) copyObject(ctx context.Context, from, to string) error {
+	r, err := s.backend.Get(ctx, from)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := s.backend.Put(ctx, to)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(w, r); err != nil {
+		_ = w.Abort()
+
+		return err
+	}
+
+	return w.Close()
+}
+
+// UpdateManifest applies fn to the manifest of a snapshot and saves it
+func (s *Storage) UpdateManifest(ctx context.Context, label string, fn func(m *Manifest) error) error {
+	lock, err := s.LockSnapshot(ctx, label)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = lock.Release(ctx) }()
+
+	manifest, err := s.loadManifest(ctx, label)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(manifest); err != nil {
+		return err
+	}
+
+	manifest.Version = manifestVersion
+
+	return s.saveManifest(ctx, manifestKey(label), manifest)
+}
+
+// AddTags adds tags to the manifest, ignoring ones it already has
+func (m *Manifest) AddTags(tags ...string) {
+	for _, tag := range tags {
+		if !slices.Contains(m.Tags, tag) {
+			m.Tags = append(m.Tags, tag)
+		}
+	}
+
+	slices.Sort(m.Tags)
+}
+
+// RemoveTags removes tags from the manifest
+func (m *Manifest) RemoveTags(tags ...string) {
+	m.Tags = slices.DeleteFunc(m.Tags, func(tag string) bool {
+		return slices.Contains(tags, tag)
+	})
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestRenameSnapshot(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStorage(t)
+
+	writeSnapshot(t, store, "a", WriteOptions{Tags: []string{"keep"}}, map[string][]map[string]any{"users": testRows})
+	writeSnapshot(t, store, "b", WriteOptions{}, map[string][]map[string]any{"users": testRows})
+
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		wantErr bool
+	}{
+		{name: "same label", from: "a", to: "a", wantErr: true},
+		{name: "invalid label", from: "a", to: "x/y", wantErr: true},
+		{name: "existing label", from: "a", to: "b", wantErr: true},
+		{name: "missing snapshot", from: "missing", to: "c", wantErr: true},
+		{name: "new label", from: "a", to: "c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := store.RenameSnapshot(ctx, tt.from, tt.to); (err != nil) != tt.wantErr {
+				t.Errorf("rename error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	labels, err := store.ListSnapshots(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"b", "c"}; !reflect.DeepEqual(labels, want) {
+		t.Errorf("snapshots = %v, want %v", labels, want)
+	}
+
+	manifest, err := store.LoadManifest(ctx, "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Label != "c" || !reflect.DeepEqual(manifest.Tags, []string{"keep"}) {
+		t.Errorf("manifest = %s %v, want c with its tags", manifest.Label, manifest.Tags)
+	}
+
+	if _, err := store.LoadRows(ctx, "c", "users"); err != nil {
+		t.Errorf("read renamed snapshot: %v", err)
+	}
+}
+
+func TestCopySnapshot(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStorage(t)
+
+	writeSnapshot(t, store, "a", WriteOptions{Note: "before"}, map[string][]map[string]any{"users": testRows})
+
+	if err := store.CopySnapshot(ctx, "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The copy shares the payload and outlives the original
+	if err := store.DeleteSnapshot(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := store.LoadManifest(ctx, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Note != "before" {
+		t.Errorf("note = %q, want before", manifest.Note)
+	}
+
+	if _, err := store.ReadTable(ctx, manifest, "users"); err != nil {
+		t.Errorf("read copy: %v", err)
+	}
+}
+
+func TestRenameLegacySnapshot(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStorage(t)
+
+	// Snapshots written before manifests keep their table files in their directory
+	w, err := store.backend.Put(ctx, snapshotPrefix("old")+"users.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(w, `[{"id":"1"}]`); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.RenameSnapshot(ctx, "old", "new"); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := store.LoadRows(ctx, "new", "users")
+	if err != nil || len(rows) != 1 {
+		t.Fatalf("rows = %v, %v, want one row", rows, err)
+	}
+
+	if objects, _ := store.backend.List(ctx, snapshotPrefix("old")); len(objects) != 0 {
+		t.Errorf("objects left under old label: %v", objects)
+	}
+}
+
+func TestManifestTags(t *testing.T) {
+	m := &Manifest{Tags: []string{"release"}}
+
+	m.AddTags("nightly", "release", "audit")
+	if want := []string{"audit", "nightly", "release"}; !reflect.DeepEqual(m.Tags, want) {
+		t.Errorf("tags = %v, want %v", m.Tags, want)
+	}
+
+	m.RemoveTags("nightly", "missing")
+	if want := []string{"audit", "release"}; !reflect.DeepEqual(m.Tags, want) {
+		t.Errorf("tags = %v, want %v", m.Tags, want)
+	}
+}
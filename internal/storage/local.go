@@ -0,0 +1,227 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LocalBackend stores objects as files under a base directory
+type LocalBackend struct {
+	baseDir string
+}
+
+// NewLocalBackend creates a backend rooted at baseDir
+func NewLocalBackend(baseDir string) (*LocalBackend, error) {
+	if err := os.MkdirAll(baseDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create base directory: %w", err)
+	}
+
+	return &LocalBackend{
+		baseDir: baseDir,
+	}, nil
+}
+
+// Put returns a writer that writes to a temporary file and renames it into place on Close
+func (b *LocalBackend) Put(_ context.Context, key string) (Writer, error) {
+	path := b.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+
+	return &localWriter{file: file, path: path}, nil
+}
+
+// Get opens the object file for reading
+func (b *LocalBackend) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	file, err := os.Open(b.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	return file, nil
+}
+
+// List walks the base directory and returns the objects under prefix
+func (b *LocalBackend) List(_ context.Context, prefix string) ([]ObjectInfo, error) {
+	// Walk from the deepest directory covered by the prefix
+	root := b.baseDir
+	if idx := strings.LastIndex(prefix, "/"); idx >= 0 {
+		root = b.path(prefix[:idx])
+	}
+
+	var objects []ObjectInfo
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+
+			return err
+		}
+
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".tmp-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(b.baseDir, path)
+		if err != nil {
+			return err
+		}
+
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		objects = append(objects, ObjectInfo{
+			Key:     key,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+
+	return objects, nil
+}
+
+// Delete removes the object file and any directories left empty
+func (b *LocalBackend) Delete(_ context.Context, key string) error {
+	path := b.path(key)
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+
+	for dir := filepath.Dir(path); dir != filepath.Clean(b.baseDir); dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Rename renames the object file
+func (b *LocalBackend) Rename(_ context.Context, from, to string) error {
+	toPath := b.path(to)
+	if err := os.MkdirAll(filepath.Dir(toPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if err := os.Rename(b.path(from), toPath); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%w: %s", ErrNotFound, from)
+		}
+
+		return fmt.Errorf("failed to rename file: %w", err)
+	}
+
+	return nil
+}
+
+// CreateExclusive creates the object file with O_EXCL
+func (b *LocalBackend) CreateExclusive(_ context.Context, key string, data []byte) error {
+	path := b.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%w: %s", ErrExists, key)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
+
+// path converts an object key to a file path
+func (b *LocalBackend) path(key string) string {
+	return filepath.Join(b.baseDir, filepath.FromSlash(key))
+}
+
+// localWriter writes an object to a temporary file
+type localWriter struct {
+	file *os.File
+	path string
+}
+
+// Write writes to the temporary file
+func (w *localWriter) Write(p []byte) (int, error) {
+	return w.file.Write(p)
+}
+
+// Abort closes and removes the temporary file
+func (w *localWriter) Abort() error {
+	_ = w.file.Close()
+
+	if err := os.Remove(w.file.Name()); err != nil {
+		return fmt.Errorf("failed to remove temporary file: %w", err)
+	}
+
+	return nil
+}
+
+// Close closes the temporary file and renames it to the object path
+func (w *localWriter) Close() error {
+	if err := w.file.Chmod(0644); err != nil {
+		_ = w.file.Close()
+		_ = os.Remove(w.file.Name())
+
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+
+	if err := w.file.Close(); err != nil {
+		_ = os.Remove(w.file.Name())
+
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	if err := os.Rename(w.file.Name(), w.path); err != nil {
+		_ = os.Remove(w.file.Name())
+
+		return fmt.Errorf("failed to rename file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// locksPrefix is the key prefix under which snapshot locks are stored
+const locksPrefix = "locks/"
+
+// ErrLocked is returned when a snapshot label is locked by another writer
+var ErrLocked = errors.New("snapshot is locked")
+
+// Lock is an exclusive lock on a snapshot label
+type Lock struct {
+	store *Storage
+	key   string
+}
+
+// lockInfo is stored in a lock file to identify its owner
+type lockInfo struct {
+	PID       int       `json:"pid"`
+	Host      string    `json:"host"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LockSnapshot acquires the lock for a snapshot label, so that two writers
+// never modify the same label at the same time
+func (s *Storage) LockSnapshot(ctx context.Context, label string) (*Lock, error) {
+	host, _ := os.Hostname()
+	info := lockInfo{
+		PID:       os.Getpid(),
+		Host:      host,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal lock: %w", err)
+	}
+
+	key := lockKey(label)
+
+	err = s.backend.CreateExclusive(ctx, key, data)
+	if errors.Is(err, ErrExists) {
+		return nil, s.lockedError(ctx, label, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock snapshot %s: %w", label, err)
+	}
+
+	return &Lock{store: s, key: key}, nil
+}
+
+// Release releases the lock
+func (l *Lock) Release(ctx context.Context) error {
+	if err := l.store.backend.Delete(ctx, l.key); err != nil {
+		return fmt.Errorf("failed to release lock: %w", err)
+	}
+
+	return nil
+}
+
+// lockedError describes the current owner of a lock
+func (s *Storage) lockedError(ctx context.Context, label, key string) error {
+	r, err := s.backend.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrLocked, label)
+	}
+	defer r.Close()
+
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrLocked, label)
+	}
+
+	var info lockInfo
+	if err := json.Unmarshal(content, &info); err != nil {
+		return fmt.Errorf("%w: %s", ErrLocked, label)
+	}
+
+	return fmt.Errorf("%w: %s is being written by pid %d on %s since %s (remove %s if that process is gone)",
+		ErrLocked, label, info.PID, info.Host, info.CreatedAt.Format(time.RFC3339), key)
+}
+
+// lockKey returns the key of the lock file for a snapshot label
+func lockKey(label string) string {
+	return locksPrefix + label + ".lock"
+}
@@ -0,0 +1,247 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+
+	"github.com/rom8726/snapdiff/internal/mask"
+)
+
+// manifestName is the file name of a snapshot manifest
+const manifestName = "manifest.json"
+
+// manifestVersion is the current manifest format version. Snapshots written
+// before manifests were introduced have none (see legacyManifest).
+const manifestVersion = 1
+
+// stagingPrefix is the key prefix under which snapshots are built before they are complete
+const stagingPrefix = "staging/"
+
+// Manifest describes a snapshot and its table files
+type Manifest struct {
+	Version     int            `json:"version"`
+	Label       string         `json:"label"`
+	CreatedAt   time.Time      `json:"created_at"`
+	Source      string         `json:"source,omitempty"` // Database the snapshot was captured from
+	Format      SnapshotFormat `json:"format"`
+	Compression Compression    `json:"compression"`
+	Complete    bool           `json:"complete"` // Set once all tables have been written
+	Tables      []TableInfo    `json:"tables"`
+	Tags        []string       `json:"tags,omitempty"`       // Free-form tags, e.g. for retention
+	Note        string         `json:"note,omitempty"`       // Free-form description
+	Signature   *Signature     `json:"signature,omitempty"`  // Set if the snapshot was signed
+	Masking     *mask.Policy   `json:"masking,omitempty"`    // Masking rules applied at capture, nil if none
+	Encryption  *Encryption    `json:"encryption,omitempty"` // Set if the snapshot is encrypted
+
+	sealed     bool                           // The sealed fields of an encrypted manifest have not been decrypted
+	dataKey    *age.X25519Identity            // Key the table payloads of an encrypted snapshot are encrypted to
+	reusedKeys map[string]*age.X25519Identity // Keys of payloads reused from other encrypted snapshots, by object key
+}
+
+// TableInfo describes a table stored in a snapshot
+type TableInfo struct {
+	Name   string `json:"name"`
+	Object string `json:"object"`         // Key of the table payload in the store
+	Hash   string `json:"hash,omitempty"` // SHA-256 of the uncompressed payload, empty if unknown
+	Rows   int    `json:"rows"`           // Number of rows, -1 if unknown
+
+	Columns     []Column `json:"columns,omitempty"`     // Captured columns in table order, empty if unknown
+	Keys        *Keys    `json:"keys,omitempty"`        // Key constraints from the catalog, nil if unknown
+	Fingerprint string   `json:"fingerprint,omitempty"` // Database-side fingerprint used by incremental snapshots
+}
+
+// ForeignKey describes a foreign key of a table
+type ForeignKey struct {
+	Columns    []string `json:"columns"`
+	RefTable   string   `json:"ref_table"`
+	RefColumns []string `json:"ref_columns"`
+}
+
+// Column describes a captured column
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"` // Database type, e.g. "integer" or "text[]"
+}
+
+// Keys describes the constraints that identify the rows of a table and
+// that reference other tables. Keys with columns that were not captured
+// are left out.
+type Keys struct {
+	PrimaryKey []string     `json:"primary_key,omitempty"`
+	Unique     [][]string   `json:"unique,omitempty"`  // Unique constraints and indexes, narrowest first
+	Foreign    []ForeignKey `json:"foreign,omitempty"` // Foreign keys
+}
+
+// Table returns the table with the given name
+func (m *Manifest) Table(name string) (*TableInfo, bool) {
+	for i := range m.Tables {
+		if m.Tables[i].Name == name {
+			return &m.Tables[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// TableNames returns the names of the tables in the snapshot
+func (m *Manifest) TableNames() []string {
+	names := make([]string, 0, len(m.Tables))
+	for _, table := range m.Tables {
+		names = append(names, table.Name)
+	}
+
+	return names
+}
+
+// LatestSnapshot returns the manifest of the most recently created snapshot, or nil if there are none
+func (s *Storage) LatestSnapshot(ctx context.Context) (*Manifest, error) {
+	labels, err := s.ListSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *Manifest
+	for _, label := range labels {
+		manifest, err := s.loadManifest(ctx, label)
+		if err != nil {
+			return nil, err
+		}
+
+		if latest == nil || manifest.CreatedAt.After(latest.CreatedAt) {
+			latest = manifest
+		}
+	}
+
+	return latest, nil
+}
+
+// LoadManifest loads the manifest of a snapshot, decrypting it if it is
+// encrypted. Snapshots created before manifests were introduced get one
+// synthesized from their table files.
+func (s *Storage) LoadManifest(ctx context.Context, label string) (*Manifest, error) {
+	manifest, err := s.loadManifest(ctx, label)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.unseal(manifest); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// LoadMetadata loads the manifest of a snapshot without decrypting it. The
+// source, tables, masking and signature of an encrypted snapshot are empty.
+func (s *Storage) LoadMetadata(ctx context.Context, label string) (*Manifest, error) {
+	return s.loadManifest(ctx, label)
+}
+
+// loadManifest loads the manifest of a snapshot as stored
+func (s *Storage) loadManifest(ctx context.Context, label string) (*Manifest, error) {
+	manifest, err := s.readManifest(ctx, manifestKey(label))
+	if errors.Is(err, ErrNotFound) {
+		return s.legacyManifest(ctx, label)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// readManifest reads and parses the manifest stored at key
+func (s *Storage) readManifest(ctx context.Context, key string) (*Manifest, error) {
+	r, err := s.backend.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	defer r.Close()
+
+	var manifest Manifest
+	if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", key, err)
+	}
+
+	manifest.sealed = manifest.Encryption != nil
+
+	return &manifest, nil
+}
+
+// saveManifest writes a manifest to the key
+func (s *Storage) saveManifest(ctx context.Context, key string, manifest *Manifest) error {
+	w, err := s.backend.Put(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to create manifest: %w", err)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(manifest.storedView()); err != nil {
+		_ = w.Abort()
+
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	return nil
+}
+
+// legacyManifest builds a manifest for a snapshot without one by listing its files
+func (s *Storage) legacyManifest(ctx context.Context, label string) (*Manifest, error) {
+	objects, err := s.snapshotObjects(ctx, label)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{
+		Version:  manifestVersion,
+		Label:    label,
+		Complete: true,
+	}
+
+	for _, obj := range objects {
+		file := path.Base(obj.Key)
+		compression := compressionFromExtension(file)
+		name := strings.TrimSuffix(file, compression.extension())
+		ext := path.Ext(name)
+
+		manifest.Format = SnapshotFormat(strings.TrimPrefix(ext, "."))
+		manifest.Compression = compression
+		manifest.Tables = append(manifest.Tables, TableInfo{
+			Name:   strings.TrimSuffix(name, ext),
+			Object: obj.Key,
+			Rows:   -1,
+		})
+
+		if manifest.CreatedAt.IsZero() || obj.ModTime.Before(manifest.CreatedAt) {
+			manifest.CreatedAt = obj.ModTime
+		}
+	}
+
+	return manifest, nil
+}
+
+// manifestKey returns the key of a committed snapshot manifest
+func manifestKey(label string) string {
+	return snapshotPrefix(label) + manifestName
+}
+
+// stagingManifestKey returns the key of the manifest of a snapshot being built
+func stagingManifestKey(label string) string {
+	return stagingPrefix + label + "/" + manifestName
+}
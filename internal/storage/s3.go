@@ -0,0 +1,270 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// defaultS3Endpoint is used when no endpoint is configured
+const defaultS3Endpoint = "s3.amazonaws.com"
+
+// s3PartSize is the part size of multipart uploads. Objects up to this
+// size, such as manifests, are buffered and uploaded in a single request.
+const s3PartSize = 16 << 20
+
+// S3Config contains configuration for an S3-compatible object store
+type S3Config struct {
+	Bucket   string // Bucket name
+	Prefix   string // Key prefix inside the bucket
+	Endpoint string // host[:port]; SNAPDIFF_S3_ENDPOINT or AWS S3 if empty
+	Region   string // Bucket region; AWS_REGION if empty
+	Insecure bool   // Use plain HTTP, e.g. for a local MinIO
+}
+
+// S3Backend stores objects in an S3-compatible object store such as AWS S3 or MinIO
+type S3Backend struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// NewS3Backend creates a backend for the bucket. Credentials are read from
+// the AWS_* or MINIO_* environment variables, ~/.aws/credentials or IAM.
+func NewS3Backend(cfg S3Config) (*S3Backend, error) {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = os.Getenv("SNAPDIFF_S3_ENDPOINT")
+	}
+	if endpoint == "" {
+		endpoint = defaultS3Endpoint
+	}
+
+	region := cfg.Region
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+
+	creds := credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.EnvAWS{},
+		&credentials.EnvMinio{},
+		&credentials.FileAWSCredentials{},
+		&credentials.IAM{},
+	})
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  creds,
+		Secure: !cfg.Insecure,
+		Region: region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	return &S3Backend{
+		client: client,
+		bucket: cfg.Bucket,
+		prefix: cfg.Prefix,
+	}, nil
+}
+
+// Put streams the object to the bucket; the upload completes when the writer is closed
+func (b *S3Backend) Put(ctx context.Context, key string) (Writer, error) {
+	return &s3Writer{ctx: ctx, backend: b, name: b.objectName(key)}, nil
+}
+
+// Get returns a reader for the object
+func (b *S3Backend) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	name := b.objectName(key)
+
+	// GetObject is lazy, so stat first to report missing objects
+	if _, err := b.client.StatObject(ctx, b.bucket, name, minio.StatObjectOptions{}); err != nil {
+		if isS3NotFound(err) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+		}
+
+		return nil, fmt.Errorf("failed to stat object: %w", err)
+	}
+
+	obj, err := b.client.GetObject(ctx, b.bucket, name, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+
+	return obj, nil
+}
+
+// List returns the objects under prefix
+func (b *S3Backend) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+
+	for obj := range b.client.ListObjects(ctx, b.bucket, minio.ListObjectsOptions{
+		Prefix:    b.objectName(prefix),
+		Recursive: true,
+	}) {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", obj.Err)
+		}
+
+		objects = append(objects, ObjectInfo{
+			Key:     strings.TrimPrefix(strings.TrimPrefix(obj.Key, b.prefix), "/"),
+			Size:    obj.Size,
+			ModTime: obj.LastModified,
+		})
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+
+	return objects, nil
+}
+
+// Delete removes the object from the bucket
+func (b *S3Backend) Delete(ctx context.Context, key string) error {
+	err := b.client.RemoveObject(ctx, b.bucket, b.objectName(key), minio.RemoveObjectOptions{})
+	if err != nil && !isS3NotFound(err) {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+
+	return nil
+}
+
+// Rename copies the object to the new key and deletes the original;
+// the copy appears atomically at the destination
+func (b *S3Backend) Rename(ctx context.Context, from, to string) error {
+	_, err := b.client.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: b.bucket, Object: b.objectName(to)},
+		minio.CopySrcOptions{Bucket: b.bucket, Object: b.objectName(from)},
+	)
+	if err != nil {
+		if isS3NotFound(err) {
+			return fmt.Errorf("%w: %s", ErrNotFound, from)
+		}
+
+		return fmt.Errorf("failed to copy object: %w", err)
+	}
+
+	return b.Delete(ctx, from)
+}
+
+// CreateExclusive uploads the object with a conditional write
+func (b *S3Backend) CreateExclusive(ctx context.Context, key string, data []byte) error {
+	opts := minio.PutObjectOptions{}
+	opts.SetMatchETagExcept("*")
+
+	_, err := b.client.PutObject(ctx, b.bucket, b.objectName(key), bytes.NewReader(data), int64(len(data)), opts)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "PreconditionFailed" {
+			return fmt.Errorf("%w: %s", ErrExists, key)
+		}
+
+		return fmt.Errorf("failed to create object: %w", err)
+	}
+
+	return nil
+}
+
+// objectName converts a key to an object name inside the bucket
+func (b *S3Backend) objectName(key string) string {
+	if b.prefix == "" {
+		return key
+	}
+
+	return b.prefix + "/" + key
+}
+
+// isS3NotFound reports whether err means the object or key does not exist
+func isS3NotFound(err error) bool {
+	code := minio.ToErrorResponse(err).Code
+
+	return code == "NoSuchKey" || code == "NotFound"
+}
+
+// errUploadAborted fails an upload that was aborted by the writer
+var errUploadAborted = errors.New("upload aborted")
+
+// s3Writer buffers a small object and streams a larger one as a multipart upload
+type s3Writer struct {
+	ctx     context.Context
+	backend *S3Backend
+	name    string
+	buf     bytes.Buffer
+	pw      *io.PipeWriter // Set once the multipart upload has started
+	done    chan error
+}
+
+// Write buffers data until it exceeds a part, then sends it to a multipart upload
+func (w *s3Writer) Write(p []byte) (int, error) {
+	if w.pw == nil {
+		if w.buf.Len()+len(p) <= s3PartSize {
+			return w.buf.Write(p)
+		}
+
+		w.startUpload()
+	}
+
+	return w.pw.Write(p)
+}
+
+// startUpload starts a multipart upload of the buffered data followed by
+// the data written next
+func (w *s3Writer) startUpload() {
+	pr, pw := io.Pipe()
+	w.pw = pw
+	w.done = make(chan error, 1)
+
+	buffered := bytes.NewReader(w.buf.Bytes())
+	w.buf = bytes.Buffer{}
+
+	go func() {
+		_, err := w.backend.client.PutObject(w.ctx, w.backend.bucket, w.name, io.MultiReader(buffered, pr), -1, w.options())
+		_ = pr.CloseWithError(err)
+		w.done <- err
+	}()
+}
+
+// options returns the options of the upload
+func (w *s3Writer) options() minio.PutObjectOptions {
+	// Unsigned payloads are accepted by more S3-compatible servers than streaming signatures
+	return minio.PutObjectOptions{PartSize: s3PartSize, DisableContentSha256: true}
+}
+
+// Abort cancels the upload so no object is created
+func (w *s3Writer) Abort() error {
+	if w.pw == nil {
+		w.buf.Reset()
+
+		return nil
+	}
+
+	_ = w.pw.CloseWithError(errUploadAborted)
+	<-w.done
+
+	return nil
+}
+
+// Close finishes the upload and waits for its result
+func (w *s3Writer) Close() error {
+	var err error
+	if w.pw == nil {
+		_, err = w.backend.client.PutObject(w.ctx, w.backend.bucket, w.name, bytes.NewReader(w.buf.Bytes()), int64(w.buf.Len()), w.options())
+	} else {
+		_ = w.pw.Close()
+		err = <-w.done
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to upload object: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,330 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is an in-memory stand-in for MinIO that serves the path-style
+// requests S3Backend makes. Signatures are not checked.
+type fakeS3 struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string]fakeObject
+	uploads map[string]map[int][]byte // Parts of multipart uploads by upload ID
+	nextID  int
+}
+
+// fakeObject is an object stored in a fakeS3
+type fakeObject struct {
+	data    []byte
+	modTime time.Time
+}
+
+// newFakeS3Backend starts a fakeS3 serving bucket and returns a backend for it
+func newFakeS3Backend(t *testing.T, bucket, prefix string) *S3Backend {
+	t.Helper()
+
+	_, backend := startFakeS3(t, bucket, prefix)
+
+	return backend
+}
+
+// startFakeS3 starts a fakeS3 serving bucket and returns it with a backend for it
+func startFakeS3(t *testing.T, bucket, prefix string) (*fakeS3, *S3Backend) {
+	t.Helper()
+
+	fake := &fakeS3{
+		bucket:  bucket,
+		objects: make(map[string]fakeObject),
+		uploads: make(map[string]map[int][]byte),
+	}
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "testsecret")
+
+	backend, err := NewS3Backend(S3Config{
+		Bucket:   bucket,
+		Prefix:   prefix,
+		Endpoint: strings.TrimPrefix(server.URL, "http://"),
+		Region:   "us-east-1",
+		Insecure: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fake, backend
+}
+
+// ServeHTTP handles an S3 request for an object or the bucket
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != s.bucket {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket")
+
+		return
+	}
+
+	query := r.URL.Query()
+
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		s.list(w, query.Get("prefix"))
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		s.nextID++
+		uploadID := strconv.Itoa(s.nextID)
+		s.uploads[uploadID] = make(map[int][]byte)
+		writeS3XML(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string
+			Key      string
+			UploadId string
+		}{Bucket: bucket, Key: key, UploadId: uploadID})
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		parts, ok := s.uploads[query.Get("uploadId")]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchUpload")
+
+			return
+		}
+
+		partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+		data := readS3Body(r)
+		parts[partNumber] = data
+		w.Header().Set("ETag", etag(data))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		parts, ok := s.uploads[query.Get("uploadId")]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchUpload")
+
+			return
+		}
+		delete(s.uploads, query.Get("uploadId"))
+
+		numbers := make([]int, 0, len(parts))
+		for n := range parts {
+			numbers = append(numbers, n)
+		}
+		sort.Ints(numbers)
+
+		var data []byte
+		for _, n := range numbers {
+			data = append(data, parts[n]...)
+		}
+		s.objects[key] = fakeObject{data: data, modTime: time.Now()}
+
+		writeS3XML(w, struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			Bucket  string
+			Key     string
+			ETag    string
+		}{Bucket: bucket, Key: key, ETag: etag(data)})
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(s.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		source, _ := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+		_, sourceKey, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+
+		obj, ok := s.objects[sourceKey]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+
+			return
+		}
+		s.objects[key] = fakeObject{data: obj.data, modTime: time.Now()}
+
+		writeS3XML(w, struct {
+			XMLName      xml.Name `xml:"CopyObjectResult"`
+			LastModified string
+			ETag         string
+		}{LastModified: time.Now().UTC().Format(time.RFC3339), ETag: etag(obj.data)})
+	case r.Method == http.MethodPut:
+		if _, ok := s.objects[key]; ok && r.Header.Get("If-None-Match") == "*" {
+			writeS3Error(w, http.StatusPreconditionFailed, "PreconditionFailed")
+
+			return
+		}
+
+		data := readS3Body(r)
+		s.objects[key] = fakeObject{data: data, modTime: time.Now()}
+		w.Header().Set("ETag", etag(data))
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		obj, ok := s.objects[key]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+
+			return
+		}
+
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj.data)))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("ETag", etag(obj.data))
+		w.Header().Set("Last-Modified", obj.modTime.UTC().Format(http.TimeFormat))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(obj.data)
+		}
+	case r.Method == http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// list writes the objects under prefix as a ListObjectsV2 response
+func (s *fakeS3) list(w http.ResponseWriter, prefix string) {
+	type content struct {
+		Key          string
+		LastModified string
+		ETag         string
+		Size         int
+		StorageClass string
+	}
+
+	var contents []content
+	for key, obj := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			contents = append(contents, content{
+				Key:          key,
+				LastModified: obj.modTime.UTC().Format(time.RFC3339Nano),
+				ETag:         etag(obj.data),
+				Size:         len(obj.data),
+				StorageClass: "STANDARD",
+			})
+		}
+	}
+
+	sort.Slice(contents, func(i, j int) bool {
+		return contents[i].Key < contents[j].Key
+	})
+
+	writeS3XML(w, struct {
+		XMLName     xml.Name `xml:"ListBucketResult"`
+		Name        string
+		Prefix      string
+		KeyCount    int
+		MaxKeys     int
+		IsTruncated bool
+		Contents    []content
+	}{Name: s.bucket, Prefix: prefix, KeyCount: len(contents), MaxKeys: 1000, Contents: contents})
+}
+
+// readS3Body returns the payload of a request, decoding the chunks of a
+// streaming-signed upload
+func readS3Body(r *http.Request) []byte {
+	data, _ := io.ReadAll(r.Body)
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return data
+	}
+
+	var payload []byte
+	for len(data) > 0 {
+		header, rest, _ := bytes.Cut(data, []byte("\r\n"))
+		sizeHex, _, _ := strings.Cut(string(header), ";")
+
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil || size == 0 || int(size) > len(rest) {
+			break
+		}
+
+		payload = append(payload, rest[:size]...)
+		data = bytes.TrimPrefix(rest[size:], []byte("\r\n"))
+	}
+
+	return payload
+}
+
+// writeS3XML writes v as an XML response
+func writeS3XML(w http.ResponseWriter, v any) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if err := xml.NewEncoder(&buf).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// writeS3Error writes an S3 error response with the code
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, "%s<Error><Code>%s</Code><Message>%s</Message></Error>", xml.Header, code, code)
+}
+
+// etag returns the quoted MD5 of data, as S3 computes it for single-part objects
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func TestS3Put(t *testing.T) {
+	tests := []struct {
+		name        string
+		size        int
+		wantUploads int
+	}{
+		{name: "small object in a single request", size: 1 << 10, wantUploads: 0},
+		{name: "object of one part in a single request", size: s3PartSize, wantUploads: 0},
+		{name: "large object in parts", size: s3PartSize + 1<<20, wantUploads: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, backend := startFakeS3(t, "snapshots", "")
+
+			data := bytes.Repeat([]byte("0123456789abcdef"), tt.size/16+1)[:tt.size]
+
+			w, err := backend.Put(context.Background(), "objects/ab/cd")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Write in chunks, as encoders do
+			for chunk := range slices.Chunk(data, 64<<10) {
+				if _, err := w.Write(chunk); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if fake.nextID != tt.wantUploads {
+				t.Errorf("multipart uploads = %d, want %d", fake.nextID, tt.wantUploads)
+			}
+
+			if got := fake.objects["objects/ab/cd"].data; !bytes.Equal(got, data) {
+				t.Errorf("object has %d bytes, want the %d written", len(got), len(data))
+			}
+		})
+	}
+}
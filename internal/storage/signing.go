@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrBadSignature is returned when a manifest signature does not verify
+var ErrBadSignature = errors.New("signature verification failed")
+
+// Signature is an Ed25519 signature over a manifest
+type Signature struct {
+	KeyID string `json:"key_id"` // Fingerprint of the public key
+	Value string `json:"value"`  // Base64-encoded signature
+}
+
+// GenerateSigningKey creates a new Ed25519 key pair and writes the private
+// key to path and the public key to path + ".pub", both PEM-encoded
+func GenerateSigningKey(path string) error {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return fmt.Errorf("failed to marshal private key: %w", err)
+	}
+
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return fmt.Errorf("failed to marshal public key: %w", err)
+	}
+
+	privPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER})
+	if err := os.WriteFile(path, privPEM, 0o600); err != nil {
+		return fmt.Errorf("failed to write private key: %w", err)
+	}
+
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})
+	if err := os.WriteFile(path+".pub", pubPEM, 0o644); err != nil {
+		return fmt.Errorf("failed to write public key: %w", err)
+	}
+
+	return nil
+}
+
+// LoadSigningKey reads a PEM-encoded Ed25519 private key
+func LoadSigningKey(path string) (ed25519.PrivateKey, error) {
+	der, err := readPEM(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", path, err)
+	}
+
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key %s is not an Ed25519 key", path)
+	}
+
+	return priv, nil
+}
+
+// LoadVerifyKey reads a PEM-encoded Ed25519 public key
+func LoadVerifyKey(path string) (ed25519.PublicKey, error) {
+	der, err := readPEM(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key %s: %w", path, err)
+	}
+
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key %s is not an Ed25519 key", path)
+	}
+
+	return pub, nil
+}
+
+// Sign signs the manifest with the private key
+func (m *Manifest) Sign(key ed25519.PrivateKey) error {
+	payload, err := m.signingPayload()
+	if err != nil {
+		return err
+	}
+
+	m.Signature = &Signature{
+		KeyID: keyID(key.Public().(ed25519.PublicKey)),
+		Value: base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload)),
+	}
+
+	return nil
+}
+
+// VerifySignature checks the manifest signature against the public key.
+// Since table digests are part of the signed manifest, a valid signature
+// together with verified tables covers the whole snapshot.
+func (m *Manifest) VerifySignature(key ed25519.PublicKey) error {
+	if m.Signature == nil {
+		return fmt.Errorf("snapshot %s is not signed", m.Label)
+	}
+
+	if id := keyID(key); m.Signature.KeyID != id {
+		return fmt.Errorf("%w: snapshot %s is signed by key %s, not %s", ErrBadSignature, m.Label, m.Signature.KeyID, id)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(m.Signature.Value)
+	if err != nil {
+		return fmt.Errorf("%w: malformed signature of snapshot %s", ErrBadSignature, m.Label)
+	}
+
+	payload, err := m.signingPayload()
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(key, payload, sig) {
+		return fmt.Errorf("%w: snapshot %s", ErrBadSignature, m.Label)
+	}
+
+	return nil
+}
+
+// signingPayload returns the bytes a manifest signature covers. The label,
+// tags and note can be changed after capture, so they are not signed, and
+// neither is the encryption, which wraps the signed content.
+func (m *Manifest) signingPayload() ([]byte, error) {
+	unsigned := *m
+	unsigned.Version = 0
+	unsigned.Label = ""
+	unsigned.Tags = nil
+	unsigned.Note = ""
+	unsigned.Signature = nil
+	unsigned.Encryption = nil
+
+	payload, err := json.Marshal(unsigned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	return payload, nil
+}
+
+// keyID returns a short fingerprint of a public key
+func keyID(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+
+	return hex.EncodeToString(sum[:8])
+}
+
+// readPEM reads the first PEM block of the given type from a file
+func readPEM(path, blockType string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("%s does not contain a PEM %s", path, blockType)
+	}
+
+	return block.Bytes, nil
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestSignature(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	keyPath := filepath.Join(dir, "signing.pem")
+	if err := GenerateSigningKey(keyPath); err != nil {
+		t.Fatal(err)
+	}
+
+	signingKey, err := LoadSigningKey(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifyKey, err := LoadVerifyKey(keyPath + ".pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherPath := filepath.Join(dir, "other.pem")
+	if err := GenerateSigningKey(otherPath); err != nil {
+		t.Fatal(err)
+	}
+
+	otherKey, err := LoadVerifyKey(otherPath + ".pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadVerifyKey(keyPath); err == nil {
+		t.Errorf("expected an error loading a private key as a public key")
+	}
+
+	store := newTestStorage(t)
+	writeSnapshot(t, store, "signed", WriteOptions{SigningKey: signingKey}, map[string][]map[string]any{"users": testRows})
+	writeSnapshot(t, store, "unsigned", WriteOptions{}, map[string][]map[string]any{"users": testRows})
+
+	// The label, tags and note can change without breaking the signature
+	if err := store.RenameSnapshot(ctx, "signed", "release"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.UpdateManifest(ctx, "release", func(m *Manifest) error {
+		m.AddTags("keep")
+		m.Note = "before the migration"
+
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := store.LoadManifest(ctx, "release")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unsigned, err := store.LoadManifest(ctx, "unsigned")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered, err := store.LoadManifest(ctx, "release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered.Tables[0].Rows++
+
+	tests := []struct {
+		name       string
+		manifest   *Manifest
+		key        ed25519.PublicKey
+		wantErr    bool
+		wantBadSig bool
+	}{
+		{name: "valid", manifest: signed, key: verifyKey},
+		{name: "unsigned", manifest: unsigned, key: verifyKey, wantErr: true},
+		{name: "tampered", manifest: tampered, key: verifyKey, wantErr: true, wantBadSig: true},
+		{name: "another key", manifest: signed, key: otherKey, wantErr: true, wantBadSig: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.manifest.VerifySignature(tt.key)
+			if (err != nil) != tt.wantErr || errors.Is(err, ErrBadSignature) != tt.wantBadSig {
+				t.Errorf("verify error = %v", err)
+			}
+		})
+	}
+}
@@ -1,12 +1,18 @@
 package storage
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"io"
+	"path"
+	"sort"
 	"strings"
 
+	"filippo.io/age"
 	"gopkg.in/yaml.v3"
 )
 
@@ -18,82 +24,110 @@ const (
 	FormatJSON SnapshotFormat = "json"
 )
 
+// snapshotsPrefix is the key prefix under which snapshot manifests are stored
+const snapshotsPrefix = "snapshots/"
+
+// objectsPrefix is the key prefix under which table payloads are stored by content hash
+const objectsPrefix = "objects/"
+
 // Storage handles saving and loading snapshots
 type Storage struct {
-	baseDir string
+	backend    Backend
+	identities []age.Identity // Identities to decrypt snapshots with, nil to use IdentityEnv
 }
 
-// NewStorage creates a new storage instance
-func NewStorage(baseDir string) (*Storage, error) {
-	if err := os.MkdirAll(baseDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create base directory: %w", err)
+// NewStorage creates a new storage instance for a location, which is
+// either a local base directory or an s3://bucket/prefix URL
+func NewStorage(location string) (*Storage, error) {
+	backend, err := OpenBackend(location)
+	if err != nil {
+		return nil, err
 	}
 
-	return &Storage{
-		baseDir: baseDir,
-	}, nil
+	return New(backend), nil
 }
 
-// SaveSnapshot saves a snapshot of a table to disk
-func (s *Storage) SaveSnapshot(label, tableName string, data any, format SnapshotFormat) error {
-	snapshotDir := filepath.Join(s.baseDir, "snapshots", label)
-	if err := os.MkdirAll(snapshotDir, 0755); err != nil {
-		return fmt.Errorf("failed to create snapshot directory: %w", err)
+// New creates a new storage instance on top of a backend
+func New(backend Backend) *Storage {
+	return &Storage{
+		backend: backend,
 	}
+}
 
-	ext := string(format)
+// LoadSnapshot loads a snapshot of a table, decompressing it if needed
+func (s *Storage) LoadSnapshot(ctx context.Context, label, tableName string) (any, error) {
+	manifest, err := s.LoadManifest(ctx, label)
+	if err != nil {
+		return nil, err
+	}
 
-	filePath := filepath.Join(snapshotDir, fmt.Sprintf("%s.%s", tableName, ext))
+	return s.readTableData(ctx, manifest, tableName, false)
+}
 
-	var content []byte
-	var err error
+// readTableData reads and decodes the payload of a table listed in the
+// manifest. With exactNumbers, JSON numbers are decoded as json.Number.
+func (s *Storage) readTableData(ctx context.Context, manifest *Manifest, tableName string, exactNumbers bool) (any, error) {
+	table, ok := manifest.Table(tableName)
+	if !ok {
+		return nil, fmt.Errorf("table %s does not exist in snapshot %s", tableName, manifest.Label)
+	}
 
-	switch format {
-	case FormatJSON:
-		content, err = json.MarshalIndent(data, "", "  ")
-		if err != nil {
-			return fmt.Errorf("failed to marshal JSON: %w", err)
-		}
-	case FormatYAML:
-		content, err = yaml.Marshal(data)
-		if err != nil {
-			return fmt.Errorf("failed to marshal YAML: %w", err)
-		}
-	default:
-		return fmt.Errorf("unsupported format: %s", format)
+	r, err := s.backend.Get(ctx, table.Object)
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("table %s of snapshot %s is %w: file %s is missing", table.Name, manifest.Label, ErrCorrupted, table.Object)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot file: %w", err)
 	}
+	defer r.Close()
 
-	if err := os.WriteFile(filePath, content, 0644); err != nil {
-		return fmt.Errorf("failed to write snapshot file: %w", err)
+	er, err := manifest.decryptTable(r, table)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
-}
+	dr, err := decompressReader(er, compressionFromExtension(table.Object))
+	if err != nil {
+		return nil, err
+	}
+	defer dr.Close()
 
-// LoadSnapshot loads a snapshot from disk
-func (s *Storage) LoadSnapshot(label, tableName string, format SnapshotFormat) (any, error) {
-	ext := string(format)
+	// Hash the payload while it is decoded, so it is read only once
+	hasher := sha256.New()
+	tr := io.TeeReader(dr, hasher)
 
-	filePath := filepath.Join(s.baseDir, "snapshots", label, fmt.Sprintf("%s.%s", tableName, ext))
+	data, err := decodeTable(tr, manifest.Format, exactNumbers)
+	if err != nil {
+		return nil, fmt.Errorf("table %s of snapshot %s is %w: %w", table.Name, manifest.Label, ErrCorrupted, err)
+	}
 
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("snapshot file does not exist: %s", filePath)
+	if _, err := io.Copy(io.Discard, tr); err != nil {
+		return nil, fmt.Errorf("table %s of snapshot %s is %w: %w", table.Name, manifest.Label, ErrCorrupted, err)
 	}
 
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read snapshot file: %w", err)
+	if err := table.verify(hex.EncodeToString(hasher.Sum(nil)), data); err != nil {
+		return nil, fmt.Errorf("table %s of snapshot %s is %w: %w", table.Name, manifest.Label, ErrCorrupted, err)
 	}
 
+	return data, nil
+}
+
+// decodeTable decodes decompressed table data from r
+func decodeTable(r io.Reader, format SnapshotFormat, exactNumbers bool) (any, error) {
 	var data any
 
 	switch format {
 	case FormatJSON:
-		if err := json.Unmarshal(content, &data); err != nil {
+		decoder := json.NewDecoder(r)
+		if exactNumbers {
+			decoder.UseNumber()
+		}
+
+		if err := decoder.Decode(&data); err != nil && !errors.Is(err, io.EOF) {
 			return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
 		}
 	case FormatYAML:
-		if err := yaml.Unmarshal(content, &data); err != nil {
+		if err := yaml.NewDecoder(r).Decode(&data); err != nil && !errors.Is(err, io.EOF) {
 			return nil, fmt.Errorf("failed to unmarshal YAML: %w", err)
 		}
 	default:
@@ -103,65 +137,210 @@ func (s *Storage) LoadSnapshot(label, tableName string, format SnapshotFormat) (
 	return data, nil
 }
 
-// ListSnapshots returns a list of all available snapshots
-func (s *Storage) ListSnapshots() ([]string, error) {
-	snapshotsDir := filepath.Join(s.baseDir, "snapshots")
+// LoadRows loads a table snapshot as a slice of rows
+func (s *Storage) LoadRows(ctx context.Context, label, tableName string) ([]map[string]any, error) {
+	manifest, err := s.LoadManifest(ctx, label)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.ReadTable(ctx, manifest, tableName)
+}
+
+// ReadTable reads the rows of a table listed in an already loaded manifest
+func (s *Storage) ReadTable(ctx context.Context, manifest *Manifest, tableName string) ([]map[string]any, error) {
+	return s.readRows(ctx, manifest, tableName, false)
+}
 
-	if err := os.MkdirAll(snapshotsDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create snapshots directory: %w", err)
+// ReadTableNumbers reads the rows of a table like ReadTable, but keeps the
+// numbers of JSON payloads as they are stored (json.Number) rather than as
+// float64, which can't hold integers beyond 2^53 exactly
+func (s *Storage) ReadTableNumbers(ctx context.Context, manifest *Manifest, tableName string) ([]map[string]any, error) {
+	return s.readRows(ctx, manifest, tableName, true)
+}
+
+// readRows reads the rows of a table listed in a manifest
+func (s *Storage) readRows(ctx context.Context, manifest *Manifest, tableName string, exactNumbers bool) ([]map[string]any, error) {
+	data, err := s.readTableData(ctx, manifest, tableName, exactNumbers)
+	if err != nil {
+		return nil, err
+	}
+
+	items, ok := data.([]any)
+	if !ok {
+		if data == nil {
+			return []map[string]any{}, nil
+		}
+
+		return nil, fmt.Errorf("unexpected snapshot content for table %s", tableName)
 	}
 
-	entries, err := os.ReadDir(snapshotsDir)
+	rows := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		if row, ok := item.(map[string]any); ok {
+			rows = append(rows, row)
+		}
+	}
+
+	return rows, nil
+}
+
+// ListSnapshots returns a list of all available snapshots
+func (s *Storage) ListSnapshots(ctx context.Context) ([]string, error) {
+	objects, err := s.backend.List(ctx, snapshotsPrefix)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read snapshots directory: %w", err)
 	}
 
+	seen := make(map[string]bool)
 	var snapshots []string
-	for _, entry := range entries {
-		if entry.IsDir() {
-			snapshots = append(snapshots, entry.Name())
+	for _, obj := range objects {
+		label, _, ok := strings.Cut(strings.TrimPrefix(obj.Key, snapshotsPrefix), "/")
+		if !ok || seen[label] {
+			continue
 		}
+
+		seen[label] = true
+		snapshots = append(snapshots, label)
 	}
 
+	sort.Strings(snapshots)
+
 	return snapshots, nil
 }
 
 // ListSnapshotTables returns a list of all tables in a snapshot
-func (s *Storage) ListSnapshotTables(label string) ([]string, error) {
-	snapshotDir := filepath.Join(s.baseDir, "snapshots", label)
+func (s *Storage) ListSnapshotTables(ctx context.Context, label string) ([]string, error) {
+	manifest, err := s.LoadManifest(ctx, label)
+	if err != nil {
+		return nil, err
+	}
 
-	if _, err := os.Stat(snapshotDir); os.IsNotExist(err) {
-		return nil, fmt.Errorf("snapshot does not exist: %s", label)
+	return manifest.TableNames(), nil
+}
+
+// DeleteSnapshot deletes a snapshot together with the table payloads
+// that no other snapshot references
+func (s *Storage) DeleteSnapshot(ctx context.Context, label string) error {
+	lock, err := s.LockSnapshot(ctx, label)
+	if err != nil {
+		return err
 	}
+	defer func() { _ = lock.Release(ctx) }()
 
-	entries, err := os.ReadDir(snapshotDir)
+	manifest, err := s.loadManifest(ctx, label)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read snapshot directory: %w", err)
+		return err
 	}
 
-	var tables []string
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			name := entry.Name()
-			tableName := strings.TrimSuffix(name, filepath.Ext(name))
-			tables = append(tables, tableName)
+	objects, err := s.snapshotObjects(ctx, label)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objects {
+		if err := s.backend.Delete(ctx, obj.Key); err != nil {
+			return fmt.Errorf("failed to delete snapshot: %w", err)
 		}
 	}
 
-	return tables, nil
+	return s.deleteUnreferenced(ctx, manifest.objectKeys())
 }
 
-// DeleteSnapshot deletes a snapshot
-func (s *Storage) DeleteSnapshot(label string) error {
-	snapshotDir := filepath.Join(s.baseDir, "snapshots", label)
-
-	if _, err := os.Stat(snapshotDir); os.IsNotExist(err) {
-		return fmt.Errorf("snapshot does not exist: %s", label)
+// deleteUnreferenced deletes the table payloads that no snapshot references anymore
+func (s *Storage) deleteUnreferenced(ctx context.Context, keys []string) error {
+	referenced, err := s.referencedObjects(ctx, nil)
+	if err != nil {
+		return err
 	}
 
-	if err := os.RemoveAll(snapshotDir); err != nil {
-		return fmt.Errorf("failed to delete snapshot: %w", err)
+	for _, key := range keys {
+		if !strings.HasPrefix(key, objectsPrefix) || referenced[key] {
+			continue
+		}
+
+		if err := s.backend.Delete(ctx, key); err != nil {
+			return fmt.Errorf("failed to delete snapshot object: %w", err)
+		}
 	}
 
 	return nil
 }
+
+// referencedObjects returns the keys of all objects referenced by snapshot
+// manifests, including those of snapshots still being built, except for
+// the manifests in skip
+func (s *Storage) referencedObjects(ctx context.Context, skip map[string]bool) (map[string]bool, error) {
+	var manifestKeys []string
+
+	for _, prefix := range []string{snapshotsPrefix, stagingPrefix} {
+		objects, err := s.backend.List(ctx, prefix)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list manifests: %w", err)
+		}
+
+		for _, obj := range objects {
+			if path.Base(obj.Key) == manifestName && !skip[obj.Key] {
+				manifestKeys = append(manifestKeys, obj.Key)
+			}
+		}
+	}
+
+	referenced := make(map[string]bool)
+	for _, key := range manifestKeys {
+		manifest, err := s.readManifest(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			// Committed or aborted in the meantime
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range manifest.objectKeys() {
+			referenced[key] = true
+		}
+	}
+
+	return referenced, nil
+}
+
+// objectExists reports whether an object with the key exists
+func (s *Storage) objectExists(ctx context.Context, key string) (bool, error) {
+	objects, err := s.backend.List(ctx, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to check object %s: %w", key, err)
+	}
+
+	for _, obj := range objects {
+		if obj.Key == key {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// snapshotObjects returns the objects of a snapshot, or an error if it does not exist
+func (s *Storage) snapshotObjects(ctx context.Context, label string) ([]ObjectInfo, error) {
+	objects, err := s.backend.List(ctx, snapshotPrefix(label))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot directory: %w", err)
+	}
+
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("snapshot does not exist: %s", label)
+	}
+
+	return objects, nil
+}
+
+// snapshotPrefix returns the key prefix of a snapshot
+func snapshotPrefix(label string) string {
+	return snapshotsPrefix + label + "/"
+}
+
+// objectKey returns the content-addressed key of a table payload
+func objectKey(hash string, format SnapshotFormat, compression Compression) string {
+	return fmt.Sprintf("%s%s/%s.%s%s", objectsPrefix, hash[:2], hash, format, compression.extension())
+}
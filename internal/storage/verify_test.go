@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestTableInfoVerify(t *testing.T) {
+	rows := []any{map[string]any{"id": "1"}, map[string]any{"id": "2"}}
+
+	tests := []struct {
+		name    string
+		table   TableInfo
+		hash    string
+		data    any
+		wantErr string
+	}{
+		{name: "intact", table: TableInfo{Hash: "abc", Rows: 2}, hash: "abc", data: rows},
+		{name: "unknown hash and rows", table: TableInfo{Rows: -1}, hash: "abc", data: rows},
+		{name: "empty table", table: TableInfo{Hash: "abc", Rows: 0}, hash: "abc", data: nil},
+		{name: "digest mismatch", table: TableInfo{Hash: "abc", Rows: 2}, hash: "def", data: rows, wantErr: "digest mismatch"},
+		{name: "row count mismatch", table: TableInfo{Hash: "abc", Rows: 3}, hash: "abc", data: rows, wantErr: "row count mismatch"},
+		{name: "not a list of rows", table: TableInfo{Rows: 1}, data: map[string]any{"id": "1"}, wantErr: "unexpected content"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.table.verify(tt.hash, tt.data)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("verify error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifySnapshot(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStorage(t)
+
+	writeSnapshot(t, store, "a", WriteOptions{}, map[string][]map[string]any{
+		"users":  testRows,
+		"orders": {{"id": "1"}},
+	})
+
+	_, checks, err := store.VerifySnapshot(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, check := range checks {
+		if check.Err != nil {
+			t.Errorf("table %s: %v", check.Table, check.Err)
+		}
+	}
+
+	manifest, err := store.LoadManifest(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replace the payload of orders with one that is valid but different
+	orders, _ := manifest.Table("orders")
+	w, err := store.backend.Put(ctx, orders.Object)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(w, `[{"id":"2"}]`); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, checks, err = store.VerifySnapshot(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, check := range checks {
+		corrupted := errors.Is(check.Err, ErrCorrupted)
+		if corrupted != (check.Table == "orders") {
+			t.Errorf("table %s: error = %v", check.Table, check.Err)
+		}
+	}
+}
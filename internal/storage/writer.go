@@ -0,0 +1,369 @@
+package storage
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"filippo.io/age"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rom8726/snapdiff/internal/mask"
+)
+
+// WriteOptions contains configuration for writing a snapshot
+type WriteOptions struct {
+	Format      SnapshotFormat     // Table file format (default JSON)
+	Compression Compression        // Table file compression (default none)
+	SigningKey  ed25519.PrivateKey // Key to sign the manifest with, nil for unsigned snapshots
+	Source      string             // Database the snapshot is captured from
+	Tags        []string           // Tags to record in the manifest
+	Note        string             // Note to record in the manifest
+	Masking     *mask.Policy       // Masking rules applied to the captured data, nil if none
+	Recipients  []string           // age recipients to encrypt the snapshot to (see parseRecipients), empty for no encryption
+}
+
+// SnapshotWriter writes the tables of a new snapshot. The snapshot is built
+// under staging/ and moved into place by Commit, so readers never see a
+// partially written snapshot, and its label is locked until then.
+type SnapshotWriter struct {
+	store      *Storage
+	manifest   *Manifest
+	signingKey ed25519.PrivateKey
+	recipients []age.Recipient // Recipients of an encrypted snapshot
+	lock       *Lock
+	written    []string // Keys of the payloads this writer stored
+	done       bool
+}
+
+// CreateSnapshot locks the label and starts writing a new snapshot
+func (s *Storage) CreateSnapshot(ctx context.Context, label string, opts WriteOptions) (*SnapshotWriter, error) {
+	if err := validateLabel(label); err != nil {
+		return nil, err
+	}
+
+	if opts.Format == "" {
+		opts.Format = FormatJSON
+	}
+
+	if opts.Compression == "" {
+		opts.Compression = CompressionNone
+	}
+
+	if opts.Format != FormatJSON && opts.Format != FormatYAML {
+		return nil, fmt.Errorf("unsupported format: %s", opts.Format)
+	}
+
+	var recipients []age.Recipient
+	var names []string
+	if len(opts.Recipients) > 0 {
+		var err error
+		recipients, names, err = parseRecipients(opts.Recipients)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	lock, err := s.LockSnapshot(ctx, label)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{
+		Version:     manifestVersion,
+		Label:       label,
+		CreatedAt:   time.Now().UTC(),
+		Source:      opts.Source,
+		Format:      opts.Format,
+		Compression: opts.Compression,
+		Note:        opts.Note,
+		Masking:     opts.Masking,
+	}
+	manifest.AddTags(opts.Tags...)
+
+	if recipients != nil {
+		if err := manifest.encryptManifest(names); err != nil {
+			_ = lock.Release(ctx)
+
+			return nil, err
+		}
+	}
+
+	return &SnapshotWriter{
+		store:      s,
+		manifest:   manifest,
+		signingKey: opts.SigningKey,
+		recipients: recipients,
+		lock:       lock,
+	}, nil
+}
+
+// WriteTable writes the rows of a table to the snapshot. The payload is
+// stored by the hash of its content, so a table that is unchanged since
+// an earlier snapshot is not written again. The table's name and metadata
+// come from info; its object, hash and row count are filled in.
+func (w *SnapshotWriter) WriteTable(ctx context.Context, info TableInfo, rows []map[string]any) error {
+	m := w.manifest
+
+	// Encode once to hash the content; this trades CPU for not buffering the payload
+	hasher := sha256.New()
+	if err := encodeTable(hasher, rows, m.Format, CompressionNone); err != nil {
+		return err
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	key := objectKey(hash, m.Format, m.Compression)
+
+	if m.Encryption != nil {
+		var err error
+		key, err = encryptedObjectKey(m.Format, m.Compression)
+		if err != nil {
+			return err
+		}
+	}
+
+	info.Object = key
+	info.Hash = hash
+	info.Rows = len(rows)
+
+	// Reference the payload before looking for it, so that rm or gc running
+	// meanwhile keeps an existing one; Commit checks it is still there
+	if err := w.addTable(ctx, info); err != nil {
+		return err
+	}
+
+	if m.Encryption == nil {
+		exists, err := w.store.objectExists(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			return nil
+		}
+	}
+
+	return w.writeObject(ctx, key, rows)
+}
+
+// writeObject encodes rows into a new table payload, encrypting it if the snapshot is encrypted
+func (w *SnapshotWriter) writeObject(ctx context.Context, key string, rows []map[string]any) error {
+	m := w.manifest
+
+	out, err := w.store.backend.Put(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+
+	var dst io.WriteCloser = nopWriteCloser{out}
+	if m.Encryption != nil {
+		dst, err = age.Encrypt(out, m.dataKey.Recipient())
+		if err != nil {
+			_ = out.Abort()
+
+			return fmt.Errorf("failed to encrypt snapshot file: %w", err)
+		}
+	}
+
+	if err := encodeTable(dst, rows, m.Format, m.Compression); err != nil {
+		_ = out.Abort()
+
+		return err
+	}
+
+	if err := dst.Close(); err != nil {
+		_ = out.Abort()
+
+		return fmt.Errorf("failed to encrypt snapshot file: %w", err)
+	}
+
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write snapshot file: %w", err)
+	}
+
+	w.written = append(w.written, key)
+
+	return nil
+}
+
+// ReuseTable adds a table stored by the base snapshot without rewriting its
+// payload. The payload of an encrypted table stays encrypted to the key of
+// the snapshot that wrote it, so that key is sealed with this snapshot.
+func (w *SnapshotWriter) ReuseTable(ctx context.Context, base *Manifest, info TableInfo) error {
+	if w.manifest.Encryption != nil {
+		key := base.payloadKey(info.Object)
+		if key == nil {
+			return fmt.Errorf("table %s of snapshot %s is encrypted: %w", info.Name, base.Label, ErrNoIdentity)
+		}
+
+		if w.manifest.reusedKeys == nil {
+			w.manifest.reusedKeys = make(map[string]*age.X25519Identity)
+		}
+		w.manifest.reusedKeys[info.Object] = key
+	}
+
+	return w.addTable(ctx, info)
+}
+
+// CanReuse reports whether the tables of a snapshot can be reused by this
+// one, which requires both to be encrypted to the same recipients or both
+// to be unencrypted
+func (w *SnapshotWriter) CanReuse(base *Manifest) bool {
+	if (base.Encryption == nil) != (w.manifest.Encryption == nil) {
+		return false
+	}
+
+	if base.Encryption == nil {
+		return true
+	}
+
+	return slices.Equal(
+		slices.Sorted(slices.Values(base.Encryption.Recipients)),
+		slices.Sorted(slices.Values(w.manifest.Encryption.Recipients)),
+	)
+}
+
+// addTable records a written table in the staging manifest, which also
+// keeps its payload referenced while the snapshot is being built
+func (w *SnapshotWriter) addTable(ctx context.Context, info TableInfo) error {
+	w.manifest.Tables = append(w.manifest.Tables, info)
+
+	if enc := w.manifest.Encryption; enc != nil && !slices.Contains(enc.Objects, info.Object) {
+		enc.Objects = append(enc.Objects, info.Object)
+	}
+
+	if err := w.store.saveManifest(ctx, stagingManifestKey(w.manifest.Label), w.manifest); err != nil {
+		return fmt.Errorf("failed to update staging manifest: %w", err)
+	}
+
+	return nil
+}
+
+// Commit marks the snapshot complete and moves it into place, replacing
+// any previous snapshot with the same label
+func (w *SnapshotWriter) Commit(ctx context.Context) error {
+	if w.done {
+		return fmt.Errorf("snapshot %s is already finished", w.manifest.Label)
+	}
+
+	label := w.manifest.Label
+	w.manifest.Complete = true
+
+	if w.signingKey != nil {
+		if err := w.manifest.Sign(w.signingKey); err != nil {
+			return err
+		}
+	}
+
+	if w.manifest.Encryption != nil {
+		if err := w.manifest.seal(w.recipients); err != nil {
+			return err
+		}
+	}
+
+	stagingKey := stagingManifestKey(label)
+	if err := w.store.saveManifest(ctx, stagingKey, w.manifest); err != nil {
+		return err
+	}
+
+	// A payload that existed when its table was added may have been removed
+	// by rm or gc before the staging manifest referenced it
+	for _, key := range w.manifest.objectKeys() {
+		exists, err := w.store.objectExists(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			return fmt.Errorf("snapshot file %s was removed while snapshot %s was written, e.g. by gc; take the snapshot again", key, label)
+		}
+	}
+
+	previous, err := w.store.loadManifest(ctx, label)
+	if err != nil {
+		previous = nil
+	}
+
+	if err := w.store.backend.Rename(ctx, stagingKey, manifestKey(label)); err != nil {
+		return fmt.Errorf("failed to move snapshot into place: %w", err)
+	}
+
+	w.done = true
+
+	// Payloads only the replaced snapshot used are no longer needed
+	if previous != nil {
+		if err := w.store.deleteUnreferenced(ctx, previous.objectKeys()); err != nil {
+			_ = w.lock.Release(ctx)
+
+			return err
+		}
+	}
+
+	return w.lock.Release(ctx)
+}
+
+// Abort discards an unfinished snapshot and releases its lock. Payloads it
+// wrote are deleted unless another snapshot references them. It does
+// nothing after Commit, so it is safe to defer.
+func (w *SnapshotWriter) Abort(ctx context.Context) error {
+	if w.done {
+		return nil
+	}
+
+	w.done = true
+
+	if err := w.store.backend.Delete(ctx, stagingManifestKey(w.manifest.Label)); err != nil {
+		_ = w.lock.Release(ctx)
+
+		return err
+	}
+
+	if err := w.store.deleteUnreferenced(ctx, w.written); err != nil {
+		_ = w.lock.Release(ctx)
+
+		return err
+	}
+
+	return w.lock.Release(ctx)
+}
+
+// encodeTable encodes and compresses rows into w
+func encodeTable(w io.Writer, rows []map[string]any, format SnapshotFormat, compression Compression) error {
+	cw, err := compressWriter(w, compression)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case FormatJSON:
+		// Indent regardless of compression, so the decompressed payload matches its hash
+		encoder := json.NewEncoder(cw)
+		encoder.SetIndent("", "  ")
+
+		if err := encoder.Encode(rows); err != nil {
+			return fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+	case FormatYAML:
+		encoder := yaml.NewEncoder(cw)
+		if err := encoder.Encode(rows); err != nil {
+			return fmt.Errorf("failed to marshal YAML: %w", err)
+		}
+
+		if err := encoder.Close(); err != nil {
+			return fmt.Errorf("failed to marshal YAML: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+
+	if err := cw.Close(); err != nil {
+		return fmt.Errorf("failed to compress snapshot: %w", err)
+	}
+
+	return nil
+}
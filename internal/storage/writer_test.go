@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestStorage returns a storage in a temporary directory
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+
+	store, err := NewStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
+
+// writeSnapshot writes and commits a snapshot with the given tables
+func writeSnapshot(t *testing.T, store *Storage, label string, opts WriteOptions, tables map[string][]map[string]any) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	w, err := store.CreateSnapshot(ctx, label, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, rows := range tables {
+		if err := w.WriteTable(ctx, TableInfo{Name: name}, rows); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testRows are rows whose values decode to the same types from JSON and YAML
+var testRows = []map[string]any{
+	{"id": "1", "email": "a@example.com"},
+	{"id": "2", "email": nil},
+}
+
+func TestWriteTableRoundTrip(t *testing.T) {
+	for _, format := range []SnapshotFormat{FormatJSON, FormatYAML} {
+		for _, compression := range []Compression{CompressionNone, CompressionGzip, CompressionZstd} {
+			t.Run(string(format)+"/"+string(compression), func(t *testing.T) {
+				ctx := context.Background()
+				store := newTestStorage(t)
+
+				writeSnapshot(t, store, "a", WriteOptions{Format: format, Compression: compression},
+					map[string][]map[string]any{"users": testRows})
+
+				manifest, err := store.LoadManifest(ctx, "a")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				table, ok := manifest.Table("users")
+				if !ok || table.Hash == "" || table.Rows != len(testRows) {
+					t.Fatalf("table = %+v, want a hash and %d rows", table, len(testRows))
+				}
+
+				// The payload is verified against its hash while it is read
+				rows, err := store.ReadTable(ctx, manifest, "users")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !reflect.DeepEqual(rows, testRows) {
+					t.Errorf("rows = %v, want %v", rows, testRows)
+				}
+			})
+		}
+	}
+}
+
+func TestWriteTableSharesEqualPayloads(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStorage(t)
+
+	writeSnapshot(t, store, "a", WriteOptions{}, map[string][]map[string]any{"users": testRows})
+	writeSnapshot(t, store, "b", WriteOptions{}, map[string][]map[string]any{"users": testRows})
+
+	a, err := store.LoadManifest(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := store.LoadManifest(ctx, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Tables[0].Object != b.Tables[0].Object {
+		t.Errorf("equal tables are stored twice: %s and %s", a.Tables[0].Object, b.Tables[0].Object)
+	}
+
+	// The shared payload outlives the snapshot that wrote it
+	if err := store.DeleteSnapshot(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.ReadTable(ctx, b, "users"); err != nil {
+		t.Errorf("read shared table after deleting a: %v", err)
+	}
+}
+
+func TestWriteTableKeepsExistingPayloadWhileDeleting(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStorage(t)
+
+	writeSnapshot(t, store, "a", WriteOptions{}, map[string][]map[string]any{"users": testRows})
+
+	w, err := store.CreateSnapshot(ctx, "b", WriteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.WriteTable(ctx, TableInfo{Name: "users"}, testRows); err != nil {
+		t.Fatal(err)
+	}
+
+	// The only committed snapshot referencing the payload goes away before b is committed
+	if err := store.DeleteSnapshot(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.LoadRows(ctx, "b", "users"); err != nil {
+		t.Errorf("read b: %v", err)
+	}
+}
+
+func TestCommitFailsWithoutPayload(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStorage(t)
+
+	w, err := store.CreateSnapshot(ctx, "a", WriteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.WriteTable(ctx, TableInfo{Name: "users"}, testRows); err != nil {
+		t.Fatal(err)
+	}
+
+	// Removed by another process between WriteTable and Commit
+	if err := store.backend.Delete(ctx, w.manifest.Tables[0].Object); err != nil {
+		t.Fatal(err)
+	}
+
+	err = w.Commit(ctx)
+	if err == nil || !strings.Contains(err.Error(), "was removed while snapshot a was written") {
+		t.Fatalf("commit error = %v, want a removed payload", err)
+	}
+
+	if err := w.Abort(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if labels, _ := store.ListSnapshots(ctx); len(labels) != 0 {
+		t.Errorf("snapshots = %v, want none", labels)
+	}
+}
+
+func TestAbortDeletesUnsharedPayloads(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStorage(t)
+
+	shared := []map[string]any{{"id": "1"}}
+	writeSnapshot(t, store, "a", WriteOptions{}, map[string][]map[string]any{"users": shared})
+
+	w, err := store.CreateSnapshot(ctx, "b", WriteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.WriteTable(ctx, TableInfo{Name: "users"}, shared); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteTable(ctx, TableInfo{Name: "orders"}, testRows); err != nil {
+		t.Fatal(err)
+	}
+
+	users, orders := w.manifest.Tables[0].Object, w.manifest.Tables[1].Object
+
+	if err := w.Abort(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string]bool{users: true, orders: false} {
+		exists, err := store.objectExists(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if exists != want {
+			t.Errorf("object %s exists = %v, want %v", key, exists, want)
+		}
+	}
+
+	// The label is free again
+	writeSnapshot(t, store, "b", WriteOptions{}, map[string][]map[string]any{"users": shared})
+}
+
+func TestCreateSnapshotRejectsInvalidLabels(t *testing.T) {
+	for _, label := range []string{"", ".", "..", "../escape", "a/b", `a\b`} {
+		t.Run(label, func(t *testing.T) {
+			store := newTestStorage(t)
+
+			if _, err := store.CreateSnapshot(context.Background(), label, WriteOptions{}); err == nil || !strings.Contains(err.Error(), "invalid snapshot label") {
+				t.Errorf("error = %v, want an invalid label", err)
+			}
+
+			// Not even the lock is taken
+			if keys := listKeys(t, store.backend, ""); len(keys) != 0 {
+				t.Errorf("keys = %v, want none", keys)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+// Package snapdiff captures in-memory snapshots of PostgreSQL tables and
+// compares them, for use from Go code such as integration tests.
+//
+//	before, err := snapdiff.Capture(ctx, db, snapdiff.CaptureOptions{})
+//	// ... run the code under test ...
+//	after, err := snapdiff.Capture(ctx, db, snapdiff.CaptureOptions{})
+//	result, err := snapdiff.Diff(before, after)
+package snapdiff
+
+import (
+	"context"
+
+	"github.com/rom8726/snapdiff/internal/db"
+	"github.com/rom8726/snapdiff/internal/diff"
+	"github.com/rom8726/snapdiff/internal/snapshot"
+	"github.com/rom8726/snapdiff/internal/storage"
+)
+
+// Querier is implemented by *sql.DB, *sql.Tx and *sql.Conn
+type Querier = db.Querier
+
+// Rows is a result set returned by a RowsQuerier; it matches pgx.Rows
+type Rows = db.Rows
+
+// RowsQuerier executes queries without going through database/sql,
+// e.g. a thin adapter around pgx.Tx, pgx.Conn or pgxpool.Pool
+type RowsQuerier = db.RowsQuerier
+
+// Snapshot is an in-memory snapshot of database tables
+type Snapshot struct {
+	Tables map[string]TableData
+	Types  map[string]map[string]string // Database type of each column by table, empty if unknown
+	Keys   map[string]*TableKeys        // Key constraints by table, nil if unknown
+}
+
+// TableKeys describes the key constraints of a table
+type TableKeys struct {
+	PrimaryKey []string
+	Unique     [][]string // Unique constraints and indexes, narrowest first
+	Foreign    []ForeignKey
+}
+
+// ForeignKey describes a foreign key of a table
+type ForeignKey struct {
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+}
+
+// TableData represents the rows of a single table
+type TableData = snapshot.TableData
+
+// CaptureOptions contains configuration for Capture
+type CaptureOptions = snapshot.CaptureOptions
+
+// Result contains all table diffs
+type Result = diff.Result
+
+// TableDiff represents the differences between two snapshots of a table
+type TableDiff = diff.TableDiff
+
+// UpdatedRow represents a row that was updated
+type UpdatedRow = diff.UpdatedRow
+
+// RekeyedRow represents a row whose key changed
+type RekeyedRow = diff.RekeyedRow
+
+// Aggregate is a root row with the changed rows that reference it, see Result.GroupByForeignKeys
+type Aggregate = diff.Aggregate
+
+// Summary aggregates the changes of a diff by table and column, see Result.Summarize
+type Summary = diff.Summary
+
+// Change describes a changed column or JSON path of an updated row
+type Change = diff.Change
+
+// CompareRule sets how the values of matching columns are compared, e.g.
+// with a numeric tolerance or as unordered arrays
+type CompareRule = diff.CompareRule
+
+// Capture reads the tables of a PostgreSQL database into an in-memory snapshot.
+// When q is a *sql.Tx or a *sql.Conn inside a transaction, the snapshot
+// sees the transaction's uncommitted changes.
+func Capture(ctx context.Context, q Querier, opts CaptureOptions) (*Snapshot, error) {
+	return CaptureRows(ctx, db.FromSQL(q), opts)
+}
+
+// CaptureRows is like Capture but reads through a RowsQuerier, which
+// allows drivers that don't use database/sql, such as pgx
+func CaptureRows(ctx context.Context, q RowsQuerier, opts CaptureOptions) (*Snapshot, error) {
+	snap, err := snapshot.Capture(ctx, db.NewPostgres(q), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*TableKeys, len(snap.Keys))
+	for tableName, tableKeys := range snap.Keys {
+		keys[tableName] = publicKeys(tableKeys)
+	}
+
+	return &Snapshot{Tables: snap.Tables, Types: snap.Types, Keys: keys}, nil
+}
+
+// Diff compares two snapshots and returns the differences
+func Diff(a, b *Snapshot) (*Result, error) {
+	return DiffWithRules(a, b, nil)
+}
+
+// DiffWithRules is like Diff but compares values according to rules
+func DiffWithRules(a, b *Snapshot, rules []CompareRule) (*Result, error) {
+	return diff.Compare(context.Background(), a.internal(), b.internal(), diff.Options{CompareRules: rules})
+}
+
+// internal returns the snapshot in the form the diff reads
+func (s *Snapshot) internal() *snapshot.Snapshot {
+	keys := make(map[string]*storage.Keys, len(s.Keys))
+	for tableName, tableKeys := range s.Keys {
+		keys[tableName] = tableKeys.internal()
+	}
+
+	return &snapshot.Snapshot{Tables: s.Tables, Types: s.Types, Keys: keys}
+}
+
+// publicKeys converts the key constraints of a captured table
+func publicKeys(keys *storage.Keys) *TableKeys {
+	if keys == nil {
+		return nil
+	}
+
+	var foreign []ForeignKey
+	for _, fk := range keys.Foreign {
+		foreign = append(foreign, ForeignKey(fk))
+	}
+
+	return &TableKeys{PrimaryKey: keys.PrimaryKey, Unique: keys.Unique, Foreign: foreign}
+}
+
+// internal converts the key constraints for the diff
+func (k *TableKeys) internal() *storage.Keys {
+	if k == nil {
+		return nil
+	}
+
+	var foreign []storage.ForeignKey
+	for _, fk := range k.Foreign {
+		foreign = append(foreign, storage.ForeignKey(fk))
+	}
+
+	return &storage.Keys{PrimaryKey: k.PrimaryKey, Unique: k.Unique, Foreign: foreign}
+}
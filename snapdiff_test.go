@@ -0,0 +1,104 @@
+package snapdiff
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeQuerier answers the catalog and data queries of a database with an
+// orders table whose rows can be changed between captures
+type fakeQuerier struct {
+	orders [][]any
+}
+
+func (q *fakeQuerier) Query(_ context.Context, query string, _ ...any) (Rows, error) {
+	results := []struct {
+		fragment string
+		rows     [][]any
+	}{
+		{"information_schema.tables", [][]any{{"orders"}}},
+		{"information_schema.columns", [][]any{{"id"}, {"status"}}},
+		{"format_type", [][]any{{"id", "bigint"}, {"status", "text"}}},
+		{"ORDER BY a.attnum", [][]any{{"id"}}},
+		{`FROM "public"."orders"`, q.orders},
+	}
+
+	for _, result := range results {
+		if strings.Contains(query, result.fragment) {
+			return &fakeRows{rows: result.rows, next: -1}, nil
+		}
+	}
+
+	return &fakeRows{next: -1}, nil
+}
+
+// fakeRows iterates over canned rows
+type fakeRows struct {
+	rows [][]any
+	next int
+}
+
+func (r *fakeRows) Next() bool {
+	r.next++
+
+	return r.next < len(r.rows)
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	for i, value := range r.rows[r.next] {
+		reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(value))
+	}
+
+	return nil
+}
+
+func (r *fakeRows) Err() error {
+	return nil
+}
+
+func (r *fakeRows) Close() {}
+
+func TestCaptureRowsAndDiff(t *testing.T) {
+	ctx := context.Background()
+	q := &fakeQuerier{orders: [][]any{{int64(1), "new"}, {int64(2), "new"}}}
+
+	before, err := CaptureRows(ctx, q, CaptureOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if keys := before.Keys["orders"]; keys == nil || !reflect.DeepEqual(keys.PrimaryKey, []string{"id"}) {
+		t.Errorf("keys = %+v, want primary key [id]", keys)
+	}
+
+	q.orders = [][]any{{int64(1), "paid"}, {int64(3), "draft"}}
+
+	after, err := CaptureRows(ctx, q, CaptureOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Diff(before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orders := result.Tables["orders"]
+	if orders == nil {
+		t.Fatalf("tables = %v, want orders", result.Tables)
+	}
+
+	if !reflect.DeepEqual(orders.Key, []string{"id"}) {
+		t.Errorf("key = %v, want the captured primary key [id]", orders.Key)
+	}
+
+	if len(orders.Inserted) != 1 || len(orders.Deleted) != 1 || len(orders.Updated) != 1 {
+		t.Fatalf("orders = %+v, want one row of each kind", orders)
+	}
+
+	if updated := orders.Updated[0]; updated.Before["status"] != "new" || updated.After["status"] != "paid" {
+		t.Errorf("updated row = %+v, want status new → paid", updated)
+	}
+}
@@ -0,0 +1,109 @@
+// Package snapdifftest provides testing helpers that compare the database
+// changes made by a piece of code with a golden file.
+package snapdifftest
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rom8726/snapdiff"
+	"github.com/rom8726/snapdiff/internal/assert"
+)
+
+// UpdateEnv is the environment variable that makes AssertChanges rewrite
+// golden files with the actual changes instead of comparing them
+const UpdateEnv = "SNAPDIFF_UPDATE"
+
+// Options contains configuration for AssertChangesWithOptions
+type Options struct {
+	Tables        []string               // Specific tables to include
+	IgnoreColumns []string               // Columns to ignore, e.g. timestamps set by the database
+	CompareRules  []snapdiff.CompareRule // Rules to compare values with, e.g. a float tolerance
+}
+
+// AssertChanges snapshots the database before and after fn and compares
+// the changes with the golden file testdata/<golden>.json. q may be a
+// *sql.DB, or the *sql.Tx the test runs in to see its uncommitted changes.
+func AssertChanges(t testing.TB, q snapdiff.Querier, fn func(), golden string) {
+	t.Helper()
+
+	AssertChangesWithOptions(t, q, fn, golden, Options{})
+}
+
+// AssertChangesWithOptions is like AssertChanges but accepts options
+func AssertChangesWithOptions(t testing.TB, q snapdiff.Querier, fn func(), golden string, opts Options) {
+	t.Helper()
+
+	ctx := context.Background()
+	captureOpts := snapdiff.CaptureOptions{
+		Tables:        opts.Tables,
+		IgnoreColumns: opts.IgnoreColumns,
+	}
+
+	before, err := snapdiff.Capture(ctx, q, captureOpts)
+	if err != nil {
+		t.Fatalf("snapdifftest: failed to capture snapshot before changes: %v", err)
+	}
+
+	fn()
+
+	after, err := snapdiff.Capture(ctx, q, captureOpts)
+	if err != nil {
+		t.Fatalf("snapdifftest: failed to capture snapshot after changes: %v", err)
+	}
+
+	result, err := snapdiff.DiffWithRules(before, after, opts.CompareRules)
+	if err != nil {
+		t.Fatalf("snapdifftest: failed to diff snapshots: %v", err)
+	}
+
+	compareGolden(t, goldenPath(golden), result)
+}
+
+// compareGolden compares the result with a golden file, or rewrites it in update mode
+func compareGolden(t testing.TB, path string, result *snapdiff.Result) {
+	t.Helper()
+
+	actualJSON, err := json.MarshalIndent(assert.ResultMap(result), "", "  ")
+	if err != nil {
+		t.Fatalf("snapdifftest: failed to marshal changes: %v", err)
+	}
+
+	if os.Getenv(UpdateEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("snapdifftest: failed to create golden file directory: %v", err)
+		}
+
+		if err := os.WriteFile(path, append(actualJSON, '\n'), 0644); err != nil {
+			t.Fatalf("snapdifftest: failed to write golden file: %v", err)
+		}
+
+		return
+	}
+
+	expectedData, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("snapdifftest: failed to read golden file (run with %s=1 to create it): %v", UpdateEnv, err)
+	}
+
+	var expected map[string]any
+	if err := json.Unmarshal(expectedData, &expected); err != nil {
+		t.Fatalf("snapdifftest: failed to parse golden file %s: %v", path, err)
+	}
+
+	if !assert.Match(expected, result) {
+		t.Errorf("snapdifftest: changes do not match %s\nExpected:\n%s\n\nActual:\n%s", path, expectedData, actualJSON)
+	}
+}
+
+// goldenPath returns the path of a golden file under testdata/
+func goldenPath(golden string) string {
+	if filepath.Ext(golden) == "" {
+		golden += ".json"
+	}
+
+	return filepath.Join("testdata", golden)
+}
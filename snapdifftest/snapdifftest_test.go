@@ -0,0 +1,149 @@
+package snapdifftest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/rom8726/snapdiff"
+)
+
+// recordingTB records the failures of a test instead of failing it
+type recordingTB struct {
+	testing.TB
+	errors []string
+	fatal  string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingTB) Fatalf(format string, args ...any) {
+	r.fatal = fmt.Sprintf(format, args...)
+	runtime.Goexit()
+}
+
+// runCompare runs compareGolden against a recordingTB and returns it
+func runCompare(t *testing.T, path string, result *snapdiff.Result) *recordingTB {
+	t.Helper()
+
+	tb := &recordingTB{TB: t}
+
+	// Fatalf ends the goroutine, like it ends a test
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		compareGolden(tb, path, result)
+	}()
+	<-done
+
+	return tb
+}
+
+// testResult is a diff with an inserted and an updated row
+func testResult(status string) *snapdiff.Result {
+	return &snapdiff.Result{Tables: map[string]*snapdiff.TableDiff{
+		"orders": {
+			TableName: "orders",
+			Inserted:  []map[string]any{{"id": float64(3), "status": "new"}},
+			Updated: []snapdiff.UpdatedRow{{
+				PrimaryKey: map[string]any{"id": float64(1)},
+				Before:     map[string]any{"id": float64(1), "status": "new"},
+				After:      map[string]any{"id": float64(1), "status": status},
+				Changes:    []snapdiff.Change{{Path: "status", Before: "new", After: status}},
+			}},
+		},
+	}}
+}
+
+func TestCompareGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testdata", "orders.json")
+
+	// Update mode writes the golden file, creating its directory
+	t.Setenv(UpdateEnv, "1")
+	if tb := runCompare(t, path, testResult("paid")); tb.fatal != "" || len(tb.errors) > 0 {
+		t.Fatalf("update failed: %s %v", tb.fatal, tb.errors)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"status": "paid"`) {
+		t.Errorf("golden file = %s, want the updated row", data)
+	}
+
+	t.Setenv(UpdateEnv, "")
+
+	tests := []struct {
+		name      string
+		path      string
+		result    *snapdiff.Result
+		wantError string
+		wantFatal string
+	}{
+		{name: "matching", path: path, result: testResult("paid")},
+		{
+			name:      "mismatch",
+			path:      path,
+			result:    testResult("shipped"),
+			wantError: "changes do not match " + path + "\nExpected:\n",
+		},
+		{
+			name:      "missing golden file",
+			path:      filepath.Join(filepath.Dir(path), "missing.json"),
+			result:    testResult("paid"),
+			wantFatal: "run with " + UpdateEnv + "=1 to create it",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb := runCompare(t, tt.path, tt.result)
+
+			if (tb.fatal == "") != (tt.wantFatal == "") || !strings.Contains(tb.fatal, tt.wantFatal) {
+				t.Errorf("fatal = %q, want %q", tb.fatal, tt.wantFatal)
+			}
+
+			if tt.wantError == "" {
+				if len(tb.errors) > 0 {
+					t.Errorf("errors = %q, want none", tb.errors)
+				}
+
+				return
+			}
+
+			if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], tt.wantError) {
+				t.Fatalf("errors = %q, want one containing %q", tb.errors, tt.wantError)
+			}
+
+			// Both the expected and the actual changes are shown
+			if !strings.Contains(tb.errors[0], `"status": "paid"`) || !strings.Contains(tb.errors[0], `"status": "shipped"`) {
+				t.Errorf("error = %s, want the expected and actual rows", tb.errors[0])
+			}
+		})
+	}
+}
+
+func TestGoldenPath(t *testing.T) {
+	tests := []struct {
+		golden string
+		want   string
+	}{
+		{golden: "orders", want: filepath.Join("testdata", "orders.json")},
+		{golden: "orders.json", want: filepath.Join("testdata", "orders.json")},
+		{golden: "billing/orders", want: filepath.Join("testdata", "billing", "orders.json")},
+	}
+
+	for _, tt := range tests {
+		if got := goldenPath(tt.golden); got != tt.want {
+			t.Errorf("goldenPath(%q) = %q, want %q", tt.golden, got, tt.want)
+		}
+	}
+}